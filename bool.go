@@ -0,0 +1,61 @@
+package wasm
+
+// Bool represents a boolean node. A Bool is an I32
+// with the value 1 if true, or 0 if false.
+type Bool interface {
+	I32
+	isBool()
+}
+
+type opsBool ops
+
+func (o opsBool) isI32() {}
+
+func (o opsBool) isBool() {}
+
+func (o opsBool) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+// ConstBool is a constant Bool value.
+type ConstBool bool
+
+func (c ConstBool) isI32() {}
+
+func (c ConstBool) isBool() {}
+
+func (c ConstBool) write(out instCtx) error {
+	if c {
+		return ConstI32(1).write(out)
+	}
+	return ConstI32(0).write(out)
+}
+
+// And returns whether both a and b are true.
+// Both a and b are always evaluated.
+func And(a, b Bool) Bool { return opsBool{a, b, andI32} }
+
+// Or returns whether either of a or b are true.
+// Both a and b are always evaluated.
+func Or(a, b Bool) Bool { return opsBool{a, b, orI32} }
+
+// Not returns whether a is false.
+func Not(a Bool) Bool { return opsBool{a, eqzI32} }
+
+// EqF32 returns whether a == b.
+func EqF32(a, b F32) Bool { return opsBool{a, b, eqf32} }
+
+// NeF32 returns whether a != b.
+func NeF32(a, b F32) Bool { return opsBool{a, b, nef32} }
+
+// LtF32 returns whether a < b.
+func LtF32(a, b F32) Bool { return opsBool{a, b, ltf32} }
+
+// GtF32 returns whether a > b.
+func GtF32(a, b F32) Bool { return opsBool{a, b, gtf32} }
+
+// LeF32 returns whether a <= b.
+func LeF32(a, b F32) Bool { return opsBool{a, b, lef32} }
+
+// GeF32 returns whether a >= b.
+func GeF32(a, b F32) Bool { return opsBool{a, b, gef32} }
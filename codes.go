@@ -42,6 +42,34 @@ const (
 	copysignf32
 )
 
+// f64 boolean operations
+const (
+	eqf64 op = 0x61 + iota
+	nef64
+	ltf64
+	gtf64
+	lef64
+	gef64
+)
+
+// f64 numeric operations
+const (
+	absf64 op = 0x99 + iota
+	negf64
+	ceilf64
+	floorf64
+	truncf64
+	nearestf64
+	sqrtf64
+	addf64
+	subf64
+	mulf64
+	divf64
+	minf64
+	maxf64
+	copysignf64
+)
+
 // vector instructions
 const (
 	// 0
@@ -70,8 +98,76 @@ const (
 	splatf64x2V128
 )
 
+// vec lane operations
+const (
+	extractLanei8x16sV128 vecOp = 21 + iota
+	extractLanei8x16uV128
+	replaceLanei8x16V128
+	extractLanei16x8sV128
+	extractLanei16x8uV128
+	replaceLanei16x8V128
+	extractLanei32x4V128
+	replaceLanei32x4V128
+)
+
 const (
 	extractLanef32x4V128 vecOp = 31 + iota
+	replaceLanef32x4V128
+)
+
+// vec integer boolean operations
+const (
+	eqi8x16V128 vecOp = 35 + iota
+	nei8x16V128
+	ltSi8x16V128
+	ltUi8x16V128
+	gtSi8x16V128
+	gtUi8x16V128
+	leSi8x16V128
+	leUi8x16V128
+	geSi8x16V128
+	geUi8x16V128
+	eqi16x8V128
+	nei16x8V128
+	ltSi16x8V128
+	ltUi16x8V128
+	gtSi16x8V128
+	gtUi16x8V128
+	leSi16x8V128
+	leUi16x8V128
+	geSi16x8V128
+	geUi16x8V128
+	eqi32x4V128
+	nei32x4V128
+	ltSi32x4V128
+	ltUi32x4V128
+	gtSi32x4V128
+	gtUi32x4V128
+	leSi32x4V128
+	leUi32x4V128
+	geSi32x4V128
+	geUi32x4V128
+)
+
+// vec f32 boolean operations
+const (
+	eqf32x4V128 vecOp = 65 + iota
+	nef32x4V128
+	ltf32x4V128
+	gtf32x4V128
+	lef32x4V128
+	gef32x4V128
+)
+
+// v128 bitwise operations
+const (
+	notV128 vecOp = 77 + iota
+	andV128
+	andnotV128
+	orV128
+	xorV128
+	bitselectV128
+	anyTrueV128
 )
 
 const (
@@ -87,6 +183,108 @@ const (
 	load64zeroV128
 )
 
+// vec i8 numeric operations
+const (
+	absi8x16V128 vecOp = 96 + iota
+	negi8x16V128
+	popcnti8x16V128
+	allTruei8x16V128
+	bitmaski8x16V128
+	narrowi16x8sV128
+	narrowi16x8uV128
+)
+
+const (
+	shli8x16V128 vecOp = 107 + iota
+	shrSi8x16V128
+	shrUi8x16V128
+	addi8x16V128
+	addSatSi8x16V128
+	addSatUi8x16V128
+	subi8x16V128
+	subSatSi8x16V128
+	subSatUi8x16V128
+)
+
+const (
+	minSi8x16V128 vecOp = 118 + iota
+	minUi8x16V128
+	maxSi8x16V128
+	maxUi8x16V128
+	_
+	avgrUi8x16V128
+)
+
+// vec i16 numeric operations
+const (
+	absi16x8V128 vecOp = 128 + iota
+	negi16x8V128
+	_
+	allTruei16x8V128
+	bitmaski16x8V128
+	narrowi32x4sV128
+	narrowi32x4uV128
+	extendLowi8x16sV128
+	extendHighi8x16sV128
+	extendLowi8x16uV128
+	extendHighi8x16uV128
+	shli16x8V128
+	shrSi16x8V128
+	shrUi16x8V128
+	addi16x8V128
+	addSatSi16x8V128
+	addSatUi16x8V128
+	subi16x8V128
+	subSatSi16x8V128
+	subSatUi16x8V128
+	_
+	muli16x8V128
+	minSi16x8V128
+	minUi16x8V128
+	maxSi16x8V128
+	maxUi16x8V128
+	_
+	avgrUi16x8V128
+)
+
+// vec i32 numeric operations
+const (
+	absi32x4V128 vecOp = 160 + iota
+	negi32x4V128
+	_
+	allTruei32x4V128
+	bitmaski32x4V128
+	_
+	_
+	extendLowi16x8sV128
+	extendHighi16x8sV128
+	extendLowi16x8uV128
+	extendHighi16x8uV128
+	shli32x4V128
+	shrSi32x4V128
+	shrUi32x4V128
+	addi32x4V128
+	_
+	_
+	subi32x4V128
+	_
+	_
+	_
+	muli32x4V128
+	minSi32x4V128
+	minUi32x4V128
+	maxSi32x4V128
+	maxUi32x4V128
+)
+
+// vec conversion operations
+const (
+	truncSatf32x4sV128 vecOp = 248 + iota
+	truncSatf32x4uV128
+	convertI32x4sV128
+	convertI32x4uV128
+)
+
 // vec f32 numeric operations
 const (
 	ceilf32x4V128 vecOp = 103 + iota
@@ -128,17 +326,100 @@ const (
 	geUI32
 )
 
-// i64 ops
+// i32 numeric ops
 
 const (
-	shiftRightUI64 op = 0x87 + iota
+	clzI32 op = 0x67 + iota
+	ctzI32
+	popcntI32
+	addI32
+	subI32
+	mulI32
+	divSI32
+	divUI32
+	remSI32
+	remUI32
+	andI32
+	orI32
+	xorI32
+	shlI32
+	shrSI32
+	shrUI32
+	rotlI32
+	rotrI32
+)
+
+// i64 boolean ops
+
+const (
+	eqzI64 op = 0x50 + iota
+	eqI64
+	neI64
+	ltSI64
+	ltUI64
+	gtSI64
+	gtUI64
+	leSI64
+	leUI64
+	geSI64
+	geUI64
+)
+
+// i64 numeric ops
+
+const (
+	clzI64 op = 0x79 + iota
+	ctzI64
+	popcntI64
+	addI64
+	subI64
+	mulI64
+	divSI64
+	divUI64
+	remSI64
+	remUI64
+	andI64
+	orI64
+	xorI64
+	shlI64
+	shrSI64
+	shrUI64
+	rotlI64
+	rotrI64
 )
 
 // conversion ops
 
 const (
-	wrapi64I32     op = 0xA7
-	truncf32ui32   op = 0xA9
-	converti32uF32 op = 0xB3
-	converti64uF32 op = 0xB4
+	wrapi64I32        op = 0xA7
+	truncf32ui32      op = 0xA9
+	extendi32sI64     op = 0xAC
+	extendi32uI64     op = 0xAD
+	converti32sF32    op = 0xB2
+	converti32uF32    op = 0xB3
+	converti64sF32    op = 0xB4
+	converti64uF32    op = 0xB5
+	demotef64F32      op = 0xB6
+	converti32sF64    op = 0xB7
+	converti32uF64    op = 0xB8
+	converti64sF64    op = 0xB9
+	converti64uF64    op = 0xBA
+	promotef32F64     op = 0xBB
+	reinterpretf32I32 op = 0xBC
+	reinterpretf64I64 op = 0xBD
+	reinterpreti32F32 op = 0xBE
+	reinterpreti64F64 op = 0xBF
+)
+
+// saturating truncation ops
+
+const (
+	truncSatf32sI32 miscOp = iota
+	truncSatf32uI32
+	truncSatf64sI32
+	truncSatf64uI32
+	truncSatf32sI64
+	truncSatf32uI64
+	truncSatf64sI64
+	truncSatf64uI64
 )
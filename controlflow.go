@@ -20,6 +20,18 @@ const (
 	ifElseCI
 )
 
+// typedBlock is a control instruction that produces
+// a value of type vt.
+type typedBlock struct {
+	ci controlInst
+	vt valuetype
+}
+
+func (tb typedBlock) write(c instCtx) error {
+	c.Write([]byte{byte(tb.ci)})
+	return tb.vt.encode(c)
+}
+
 const (
 	branchCI op = 0x0C + iota
 	branchIfCI
@@ -54,18 +66,233 @@ func (b branchIf) write(c instCtx) error {
 }
 
 // Call calls the given Callable, which must be of type
-// Function or ImportedFunction. args must result in the correct
-// number of arguments being available on the stack.
+// Function or ImportedFunction. args must match the types of
+// the parameters of c. Any results of c are discarded.
 func Call(c Callable, args ...Instruction) Instruction {
-	return ops(append(args, callCI, u32(c.index())))
+	return call{c: c, args: args}
 }
 
+// CallF32 calls c, which must return a single F32 result.
+func CallF32(c Callable, args ...Instruction) F32 {
+	return callF32{call{c: c, args: args, result: TypeF32}}
+}
+
+// CallI32 calls c, which must return a single I32 result.
+func CallI32(c Callable, args ...Instruction) I32 {
+	return callI32{call{c: c, args: args, result: TypeI32}}
+}
+
+// CallF64 calls c, which must return a single F64 result.
+func CallF64(c Callable, args ...Instruction) F64 {
+	return callF64{call{c: c, args: args, result: TypeF64}}
+}
+
+// CallI64 calls c, which must return a single I64 result.
+func CallI64(c Callable, args ...Instruction) I64 {
+	return callI64{call{c: c, args: args, result: TypeI64}}
+}
+
+// CallVec4F32 calls c, which must return a single Vec4F32 result.
+func CallVec4F32(c Callable, args ...Instruction) Vec4F32 {
+	return callVec4F32{call{c: c, args: args, result: TypeVec4F32}}
+}
+
+type call struct {
+	c      Callable
+	args   []Instruction
+	result Type
+}
+
+func (cl call) write(c instCtx) error {
+	if f, ok := cl.c.(*function); ok && f.m != c.m {
+		return fmt.Errorf("call to %s of another module", f)
+	}
+	sig := cl.c.signature()
+	if len(cl.args) != len(sig.Params) {
+		return fmt.Errorf("call to %s with %d arguments", sig, len(cl.args))
+	}
+	for i, arg := range cl.args {
+		t, ok := typeOf(arg)
+		if !ok {
+			return fmt.Errorf("call to %s: argument %d has unknown type", sig, i)
+		}
+		if t != sig.Params[i] {
+			return fmt.Errorf("call to %s: argument %d is %s, not %s", sig, i, t, sig.Params[i])
+		}
+		if err := arg.write(c); err != nil {
+			return err
+		}
+	}
+	if cl.result != 0 {
+		if len(sig.Results) != 1 || sig.Results[0] != cl.result {
+			return fmt.Errorf("call to %s does not return a single %s", sig, cl.result)
+		}
+	}
+	callCI.write(c)
+	writeu32(cl.c.index(), c)
+	if cl.result == 0 {
+		for range sig.Results {
+			dropOp.write(c)
+		}
+	}
+	return nil
+}
+
+type callF32 struct{ call }
+
+func (cl callF32) isF32() {}
+
+type callI32 struct{ call }
+
+func (cl callI32) isI32() {}
+
+type callF64 struct{ call }
+
+func (cl callF64) isF64() {}
+
+type callI64 struct{ call }
+
+func (cl callI64) isI64() {}
+
+type callVec4F32 struct{ call }
+
+func (cl callVec4F32) isVec4F32() {}
+
 // Return unconditionally returns from the current function.
 var Return op = returnCI
 
+// ReturnValue returns v from the current function. v must
+// match the results declared by the function's Signature.
+func ReturnValue(v ...Instruction) Instruction {
+	return returnValue(v)
+}
+
+type returnValue []Instruction
+
+func (r returnValue) write(c instCtx) error {
+	results := c.fn.signature().Results
+	if len(r) != len(results) {
+		return fmt.Errorf("return of %d values from function with results %v", len(r), results)
+	}
+	for i, v := range r {
+		if t, ok := typeOf(v); !ok || t != results[i] {
+			return fmt.Errorf("return value %d is not %s", i, results[i])
+		}
+		if err := v.write(c); err != nil {
+			return err
+		}
+	}
+	return returnCI.write(c)
+}
+
+// If conditionally runs the instructions in Then,
+// if Condition is true. Otherwise, it will run the
+// instructions in Else.
+type If struct {
+	Condition Bool
+	Then      []Instruction
+	Else      []Instruction
+}
+
+func (i If) write(c instCtx) error {
+	if i.Condition == nil {
+		return nil
+	}
+	return ifElse(c, i.Condition, i.Then, i.Else)
+}
+
+// ifElse writes an if block that runs then if the i32
+// condition is non-zero, or els otherwise.
+func ifElse(c instCtx, cond Instruction, then, els []Instruction) error {
+	if err := cond.write(c); err != nil {
+		return err
+	}
+	ifElseCI.write(c)
+	if err := ops(then).write(c.enter(1)); err != nil {
+		return err
+	}
+	elseCI.write(c)
+	if err := ops(els).write(c.enter(1)); err != nil {
+		return err
+	}
+	return endCI.write(c)
+}
+
+// IfExprF32 is an F32 with the value of Then if Condition
+// is true, or the value of Else otherwise. Only one of Then
+// and Else is evaluated.
+type IfExprF32 struct {
+	Condition Bool
+	Then      F32
+	Else      F32
+}
+
+func (i IfExprF32) isF32() {}
+
+func (i IfExprF32) write(c instCtx) error {
+	return ops{
+		i.Condition,
+		typedBlock{ci: ifElseCI, vt: valuetype{numtype: f32}},
+		i.Then,
+		elseCI,
+		i.Else,
+		endCI,
+	}.write(c)
+}
+
+// IfExprVec4F32 is a Vec4F32 with the value of Then if
+// Condition is true, or the value of Else otherwise. Only
+// one of Then and Else is evaluated.
+type IfExprVec4F32 struct {
+	Condition Bool
+	Then      Vec4F32
+	Else      Vec4F32
+}
+
+func (i IfExprVec4F32) isVec4F32() {}
+
+func (i IfExprVec4F32) write(c instCtx) error {
+	return ops{
+		i.Condition,
+		typedBlock{ci: ifElseCI, vt: valuetype{vectype: true}},
+		i.Then,
+		elseCI,
+		i.Else,
+		endCI,
+	}.write(c)
+}
+
+// SelectF32 returns a if cond is true, or b otherwise.
+// Unlike IfExprF32, both a and b are always evaluated,
+// and no branch is taken.
+func SelectF32(cond Bool, a, b F32) F32 { return opsF32{a, b, cond, selectOp} }
+
+// SelectI32 returns a if cond is true, or b otherwise.
+// Both a and b are always evaluated.
+func SelectI32(cond Bool, a, b I32) I32 { return opsI32{a, b, cond, selectOp} }
+
+// SelectF64 returns a if cond is true, or b otherwise.
+// Both a and b are always evaluated.
+func SelectF64(cond Bool, a, b F64) F64 { return opsF64{a, b, cond, selectOp} }
+
+// SelectI64 returns a if cond is true, or b otherwise.
+// Both a and b are always evaluated.
+func SelectI64(cond Bool, a, b I64) I64 { return opsI64{a, b, cond, selectOp} }
+
+// SelectVec4F32 returns the lanes of a where mask is set,
+// and the lanes of b otherwise. Both a and b are always
+// evaluated. Use SplatMask4 to select with a Bool.
+func SelectVec4F32(mask Mask4, a, b Vec4F32) Vec4F32 {
+	return opsVec4F32{a, b, mask, bitselectV128}
+}
+
 // IfF32 conditionally runs the instructions
 // in Then, if Condition is non-zero. Otherwise,
 // it will run the instructions in Else.
+//
+// Condition is truncated to an unsigned integer,
+// which traps if it is negative or NaN. Prefer If,
+// with a Bool Condition.
 type IfF32 struct {
 	Condition F32
 	Then      []Instruction
@@ -76,18 +303,7 @@ func (i IfF32) write(c instCtx) error {
 	if i.Condition == nil {
 		return nil
 	}
-	out := ops{
-		// load condition
-		i.Condition,
-		truncf32ui32,
-		// if s
-		ifElseCI,
-	}
-	out = append(out, i.Then...)
-	out = append(out, elseCI)
-	out = append(out, i.Else...)
-	out = append(out, endCI)
-	return out.write(c)
+	return ifElse(c, castF32I32(i.Condition), i.Then, i.Else)
 }
 
 // ForRangeF32 runs the instructions Do for every index value
@@ -96,7 +312,11 @@ func (i IfF32) write(c instCtx) error {
 // End default to 0. Inc may be negative, in which case the end
 // condition is index <= end. Otherwise the end condition is
 // index >= end.
+//
+// Break and Continue with the loop's Label, or an empty label,
+// exit the loop or skip to the next index.
 type ForRangeF32 struct {
+	Label string
 	Begin F32
 	End   F32
 	Inc   F32
@@ -117,51 +337,258 @@ func (fr ForRangeF32) write(c instCtx) error {
 	idx := c.fn.LocalF32()
 	end := c.fn.LocalF32()
 	inc := c.fn.LocalF32()
-	body := []Instruction{
+	init := ops{
 		// assign locals
 		AssignF32(idx, fr.Begin),
 		AssignF32(end, fr.End),
 		AssignF32(inc, fr.Inc),
+	}
+	if err := init.write(c); err != nil {
+		return fmt.Errorf("failure in for range: %s", err)
+	}
+	err := loopBlock{
+		label: fr.Label,
+		check: []Instruction{
+			// check if we're out of bounds
+			inc,
+			ConstF32(0),
+			gef32,
+
+			ifElseCI,
+			idx,
+			end,
+			gef32, // if inc is positive, check if idx >= end
+			branchIf(2),
+			elseCI,
+			idx,
+			end,
+			lef32, // if inc is negative check if idx <= end
+			branchIf(2),
+			endCI,
+		},
+		body: fr.Do(idx),
+		step: []Instruction{
+			AssignF32(idx, AddF32(idx, inc)),
+		},
+	}.write(c)
+	if err != nil {
+		return fmt.Errorf("failure in for range: %s", err)
+	}
+	return nil
+}
 
-		// ok, time to loop
-		blockCI,
-		loopCI,
+// While runs the instructions in Do for as long as
+// Condition is true. Condition is checked before
+// each iteration.
+//
+// Break and Continue with the loop's Label, or an empty label,
+// exit the loop or skip to the next iteration.
+type While struct {
+	Label     string
+	Condition Bool
+	Do        []Instruction
+}
 
-		// check if we're out of bounds
-		inc,
-		ConstF32(0),
-		gef32,
+func (w While) write(c instCtx) error {
+	return loopBlock{
+		label: w.Label,
+		check: []Instruction{
+			Not(w.Condition),
+			branchIf(1),
+		},
+		body: w.Do,
+	}.write(c)
+}
 
-		ifElseCI,
-		idx,
-		end,
-		gef32, // if inc is positive, check if idx >= end
-		branchIf(2),
-		elseCI,
-		idx,
-		end,
-		lef32, // if inc is negative check if idx <= end
-		branchIf(2),
-		endCI,
+// Loop runs the instructions in Do repeatedly, until
+// the loop is exited with Break or Return.
+//
+// Break and Continue with the loop's Label, or an empty label,
+// exit the loop or skip to the next iteration.
+type Loop struct {
+	Label string
+	Do    []Instruction
+}
+
+func (l Loop) write(c instCtx) error {
+	return loopBlock{
+		label: l.Label,
+		body:  l.Do,
+	}.write(c)
+}
+
+// loopBlock writes a loop of the form:
+//
+//	block
+//	  loop
+//	    check
+//	    block
+//	      body
+//	    end
+//	    step
+//	    br 0
+//	  end
+//	end
+//
+// check may exit the loop by branching to depth 1. Break
+// exits the outer block, and Continue exits the inner block.
+type loopBlock struct {
+	label string
+	check []Instruction
+	body  []Instruction
+	step  []Instruction
+	// outer is the number of blocks enclosing the
+	// loop that are also exited by Break.
+	outer int
+}
+
+func (l loopBlock) write(c instCtx) error {
+	blockCI.write(c)
+	loopCI.write(c)
+	inLoop := c.enter(2)
+	if err := ops(l.check).write(inLoop); err != nil {
+		return err
+	}
+	blockCI.write(c)
+	inBody := c.enter(3)
+	inBody.loops = append(inBody.loops[:len(inBody.loops):len(inBody.loops)], loopTarget{
+		label:      l.label,
+		brk:        c.depth + 1 - l.outer,
+		continueAt: c.depth + 3,
+	})
+	if err := ops(l.body).write(inBody); err != nil {
+		return err
 	}
+	endCI.write(c)
+	if err := ops(l.step).write(inLoop); err != nil {
+		return err
+	}
+	branch(0).write(c)
+	endCI.write(c) // end loop
+	endCI.write(c) // end outer block
+	return nil
+}
 
-	// ok we're still in the loop, call user code now
-	body = append(body, fr.Do(idx)...)
+// Break exits the enclosing loop with the given label, or
+// the innermost loop if label is empty.
+func Break(label string) Instruction {
+	return branchLabel{label: label}
+}
 
-	// user code is done, lets wrap up the loop
-	body = append(body,
-		AssignF32(idx, AddF32(idx, inc)),
-		// return to beginning of loop by default
-		branch(0),
+// Continue skips to the next iteration of the enclosing
+// loop with the given label, or the innermost loop if label
+// is empty.
+func Continue(label string) Instruction {
+	return branchLabel{label: label, cont: true}
+}
 
-		endCI, // end loop
-		endCI, // end outer block
-	)
+type branchLabel struct {
+	label string
+	cont  bool
+}
 
-	for _, inst := range body {
-		if err := inst.write(c); err != nil {
-			return fmt.Errorf("failure in for range: %s", err)
+func (b branchLabel) write(c instCtx) error {
+	for i := len(c.loops) - 1; i >= 0; i-- {
+		l := c.loops[i]
+		if b.label != "" && l.label != b.label {
+			continue
 		}
+		target := l.brk
+		if b.cont {
+			target = l.continueAt
+		}
+		return branch(c.depth - target).write(c)
 	}
-	return nil
+	if b.label == "" {
+		return fmt.Errorf("break or continue outside of a loop")
+	}
+	return fmt.Errorf("no enclosing loop labeled %q", b.label)
+}
+
+// Switch runs the instructions in Cases[Index], or the
+// instructions in Default if Index is out of range. Index
+// is interpreted as an unsigned integer, so negative values
+// run Default. Cases do not fall through.
+type Switch struct {
+	Index   I32
+	Cases   [][]Instruction
+	Default []Instruction
+}
+
+func (s Switch) write(c instCtx) error {
+	if s.Index == nil {
+		return fmt.Errorf("switch has no index")
+	}
+	return switchTable(c, s.Index, s.Cases, s.Default)
+}
+
+// SwitchF32 is a Switch with an F32 Index. Index is
+// truncated towards zero and clamped to the cases, so
+// values below 1 (including -0.5 and NaN) run Cases[0]
+// and values past the end run the last case. Default
+// only runs when there are no Cases. It never traps.
+type SwitchF32 struct {
+	Index   F32
+	Cases   [][]Instruction
+	Default []Instruction
+}
+
+func (s SwitchF32) write(c instCtx) error {
+	if s.Index == nil {
+		return fmt.Errorf("switch has no index")
+	}
+	idx := s.Index
+	if n := len(s.Cases); n > 0 {
+		// the saturating truncation takes NaN to 0
+		idx = MinF32(MaxF32(idx, ConstF32(0)), ConstF32(float32(n-1)))
+	}
+	return switchTable(c, F32ToI32(idx), s.Cases, s.Default)
+}
+
+// switchTable writes a br_table dispatching to cases.
+//
+//	block                 ;; exit
+//	  block               ;; default
+//	    block             ;; case n-1
+//	      ...
+//	        block         ;; case 0
+//	          index
+//	          br_table 0 1 ... n-1 n
+//	        end
+//	        case 0
+//	        br n
+//	      ...
+//	    end
+//	    case n-1
+//	    br 1
+//	  end
+//	  default
+//	end
+func switchTable(c instCtx, index I32, cases [][]Instruction, def []Instruction) error {
+	n := len(cases)
+	for i := 0; i < n+2; i++ {
+		blockCI.write(c)
+	}
+	if err := index.write(c.enter(n + 2)); err != nil {
+		return err
+	}
+	branchTableCI.write(c)
+	writeu32(uint32(n), c)
+	for i := 0; i <= n; i++ {
+		writeu32(uint32(i), c)
+	}
+	for i, cs := range cases {
+		endCI.write(c)
+		inCase := c.enter(n + 1 - i)
+		if err := ops(cs).write(inCase); err != nil {
+			return err
+		}
+		// exit the switch
+		branch(n - i).write(c)
+	}
+	endCI.write(c)
+	if err := ops(def).write(c.enter(1)); err != nil {
+		return err
+	}
+	return endCI.write(c)
 }
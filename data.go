@@ -0,0 +1,158 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// dataAlign is the alignment of automatically placed data segments.
+const dataAlign = 16
+
+type dataSegment struct {
+	offset uint32
+	init   []byte
+}
+
+func (d *dataSegment) end() uint64 {
+	return uint64(d.offset) + uint64(len(d.init))
+}
+
+func (d *dataSegment) encode(out io.Writer) {
+	// active segment in memory 0
+	out.Write([]byte{0x00})
+	constUI32(d.offset).write(instCtx{Writer: out})
+	out.Write([]byte{0x0B}) // end expression
+	writeu32(uint32(len(d.init)), out)
+	out.Write(d.init)
+}
+
+// DataAt places a copy of b in memory at the given byte offset
+// when the module is instantiated.
+func (m *Module) DataAt(offset uint32, b []byte) {
+	m.addData(offset, b)
+}
+
+// Data places a copy of b in memory when the module is
+// instantiated, and returns its byte offset. The offset
+// is chosen after the end of all data placed so far.
+func (m *Module) Data(b []byte) uint32 {
+	return m.addData(m.nextDataOffset(), b).offset
+}
+
+// DataF32At places a copy of values in memory at the given
+// byte offset when the module is instantiated, and returns a
+// read-only SliceF32 referring to them.
+func (m *Module) DataF32At(offset uint32, values []float32) SliceF32 {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, values)
+	seg := m.addData(offset, buf.Bytes())
+	return &dataSliceF32{seg: seg, n: uint32(len(values))}
+}
+
+// DataF32 places a copy of values in memory when the module
+// is instantiated, and returns a read-only SliceF32 referring
+// to them. The offset is chosen after the end of all data placed
+// so far.
+func (m *Module) DataF32(values []float32) SliceF32 {
+	return m.DataF32At(m.nextDataOffset(), values)
+}
+
+func (m *Module) addData(offset uint32, b []byte) *dataSegment {
+	seg := &dataSegment{
+		offset: offset,
+		init:   append([]byte(nil), b...),
+	}
+	m.data = append(m.data, seg)
+	m.doesUseMemory = true
+	return seg
+}
+
+func (m *Module) nextDataOffset() uint32 {
+	var end uint64
+	for _, d := range m.data {
+		if e := d.end(); e > end {
+			end = e
+		}
+	}
+	end = (end + dataAlign - 1) &^ (dataAlign - 1)
+	if end > 1<<32-1 {
+		panic(fmt.Errorf("data segments exceed 32-bit address space"))
+	}
+	return uint32(end)
+}
+
+// dataPages returns the number of pages required to
+// hold all data segments.
+func (m *Module) dataPages() uint32 {
+	var end uint64
+	for _, d := range m.data {
+		if e := d.end(); e > end {
+			end = e
+		}
+	}
+	return uint32((end + PageSize - 1) / PageSize)
+}
+
+func (m *Module) checkData() error {
+	segs := make([]*dataSegment, len(m.data))
+	copy(segs, m.data)
+	sort.SliceStable(segs, func(i, j int) bool {
+		return segs[i].offset < segs[j].offset
+	})
+	for i := 1; i < len(segs); i++ {
+		if segs[i-1].end() > uint64(segs[i].offset) {
+			return fmt.Errorf("data segment at %d overlaps data segment at %d",
+				segs[i].offset, segs[i-1].offset)
+		}
+	}
+	if mem := m.usedMemory(); mem != nil && m.dataPages() > mem.min {
+		return fmt.Errorf("data segments require %d pages, memory has %d",
+			m.dataPages(), mem.min)
+	}
+	return nil
+}
+
+func (m *Module) writeDataSection() error {
+	if len(m.data) == 0 {
+		return nil
+	}
+	if err := m.checkData(); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	writeu32(uint32(len(m.data)), buf)
+	for _, d := range m.data {
+		d.encode(buf)
+	}
+
+	m.buf.WriteByte(11)
+	writeu32(uint32(buf.Len()), &m.buf)
+	m.buf.Write(buf.Bytes())
+	return nil
+}
+
+// dataSliceF32 is a read-only slice placed in memory
+// by a data segment.
+type dataSliceF32 struct {
+	seg *dataSegment
+	n   uint32
+}
+
+func (s *dataSliceF32) LengthF32() F32 {
+	return ConstF32(s.n)
+}
+
+func (s *dataSliceF32) offsetI32() I32 {
+	return constUI32(s.seg.offset)
+}
+
+func (s *dataSliceF32) IndexF32(i F32) F32 {
+	return indexSliceF32(s, castF32I32(i))
+}
+
+func (s *dataSliceF32) IndexVec4F32(i F32) Vec4F32 {
+	return indexSliceVec4F32(s, castF32I32(i))
+}
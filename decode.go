@@ -0,0 +1,820 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+// Binary is the decoded contents of a binary module. Functions,
+// globals and memories are numbered as in the binary, with
+// imports before the module's own definitions.
+type Binary struct {
+	Types   []Signature
+	Imports []BinaryImport
+	// Functions holds the type index of each function
+	// defined by the module.
+	Functions []uint32
+	Memories  []Limits
+	Globals   []BinaryGlobal
+	Exports   []BinaryExport
+	// Start is the index of the start function, or -1.
+	Start  int
+	Code   []BinaryCode
+	Data   []BinaryData
+	Custom []BinaryCustom
+}
+
+// ExternKind is the kind of an import or export.
+type ExternKind byte
+
+const (
+	ExternFunction ExternKind = iota
+	ExternTable
+	ExternMemory
+	ExternGlobal
+)
+
+func (k ExternKind) String() string {
+	switch k {
+	case ExternFunction:
+		return "func"
+	case ExternTable:
+		return "table"
+	case ExternMemory:
+		return "memory"
+	case ExternGlobal:
+		return "global"
+	default:
+		return fmt.Sprintf("ExternKind(%d)", byte(k))
+	}
+}
+
+// Limits are the size limits of a memory, in pages.
+type Limits struct {
+	Min, Max uint32
+	HasMax   bool
+}
+
+// BinaryImport is an entry of the import section. Type is set
+// for functions, Memory for memories, and Global and Mutable
+// for globals.
+type BinaryImport struct {
+	Module, Name string
+	Kind         ExternKind
+
+	Type    uint32
+	Memory  Limits
+	Global  Type
+	Mutable bool
+}
+
+// BinaryGlobal is a global defined by the module.
+type BinaryGlobal struct {
+	Type    Type
+	Mutable bool
+	Init    []Instr
+}
+
+// BinaryExport is an entry of the export section.
+type BinaryExport struct {
+	Name  string
+	Kind  ExternKind
+	Index uint32
+}
+
+// BinaryCode is the body of a function defined by the module.
+type BinaryCode struct {
+	// Locals are the types of the locals declared by the
+	// function, following its params.
+	Locals []Type
+	// Body holds the instructions of the function, without
+	// the final end.
+	Body []Instr
+}
+
+// BinaryData is a data segment. Offset is set for active
+// segments, which are copied into memory at instantiation.
+type BinaryData struct {
+	Active bool
+	Offset []Instr
+	Init   []byte
+}
+
+// BinaryCustom is a custom section.
+type BinaryCustom struct {
+	Name string
+	Data []byte
+}
+
+// Instr is a decoded instruction. Only the fields used by
+// the immediates of Op are set.
+type Instr struct {
+	Op Opcode
+	// Block is the type of a block, loop or if, or holds the
+	// result of a typed select.
+	Block Signature
+	// Index is the function, local or global index, or the
+	// label depth of a branch.
+	Index uint32
+	// Labels holds the label depths of br_table, with the
+	// default last.
+	Labels []uint32
+	// Align and Offset are the memory argument of loads
+	// and stores.
+	Align, Offset uint32
+	// Lane is the lane of lane instructions.
+	Lane byte
+	// Bits holds the bits of a scalar constant, with i32
+	// constants zero-extended.
+	Bits uint64
+	// V128 holds a v128.const, or the lanes of i8x16.shuffle.
+	V128 [16]byte
+}
+
+func (in Instr) String() string {
+	switch opcodes[in.Op].imm {
+	case immLabel, immFunc, immLocal, immGlobal:
+		return fmt.Sprintf("%v %d", in.Op, in.Index)
+	case immLane:
+		return fmt.Sprintf("%v %d", in.Op, in.Lane)
+	case immI32:
+		return fmt.Sprintf("%v %d", in.Op, int32(in.Bits))
+	case immI64:
+		return fmt.Sprintf("%v %d", in.Op, int64(in.Bits))
+	case immF32:
+		return fmt.Sprintf("%v %v", in.Op, math.Float32frombits(uint32(in.Bits)))
+	case immF64:
+		return fmt.Sprintf("%v %v", in.Op, math.Float64frombits(in.Bits))
+	default:
+		return in.Op.String()
+	}
+}
+
+// Parse reads a binary module from r and decodes it.
+func Parse(r io.Reader) (*Binary, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(b)
+}
+
+// decodeError is the panic value used to abort decoding.
+type decodeError struct {
+	err error
+}
+
+func fail(format string, args ...interface{}) {
+	panic(decodeError{fmt.Errorf(format, args...)})
+}
+
+// reader reads the binary format, and aborts decoding
+// with fail on malformed input. Offsets in errors are
+// relative to the start of the module.
+type reader struct {
+	b    []byte
+	pos  int
+	base int
+}
+
+func (r *reader) done() bool { return r.pos >= len(r.b) }
+
+func (r *reader) offset() int { return r.base + r.pos }
+
+func (r *reader) byte() byte {
+	if r.pos >= len(r.b) {
+		fail("unexpected end at offset %d", r.offset())
+	}
+	b := r.b[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n uint32) []byte {
+	if uint64(r.pos)+uint64(n) > uint64(len(r.b)) {
+		fail("unexpected end at offset %d", r.offset())
+	}
+	b := r.b[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+// sub returns a reader for the next n bytes.
+func (r *reader) sub(n uint32) *reader {
+	base := r.offset()
+	return &reader{b: r.bytes(n), base: base}
+}
+
+// uleb reads an unsigned LEB128 value of at most bits bits.
+func (r *reader) uleb(bits uint) uint64 {
+	var v uint64
+	last := (bits+6)/7 - 1
+	for i := uint(0); ; i++ {
+		b := r.byte()
+		if i == last && b&0x7F>>(bits-7*i) != 0 {
+			fail("integer too large at offset %d", r.offset()-1)
+		}
+		v |= uint64(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return v
+		}
+		if i == last {
+			fail("integer too long at offset %d", r.offset()-1)
+		}
+	}
+}
+
+// sleb reads a signed LEB128 value of at most bits bits.
+func (r *reader) sleb(bits uint) int64 {
+	var v int64
+	last := (bits+6)/7 - 1
+	for i := uint(0); ; i++ {
+		b := r.byte()
+		if i == last {
+			// the unused bits must match the sign bit
+			used := bits - 7*i
+			if hi := b & 0x7F >> (used - 1); hi != 0 && hi != 0x7F>>(used-1) {
+				fail("integer too large at offset %d", r.offset()-1)
+			}
+		}
+		v |= int64(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			if shift := 7 * (i + 1); shift < 64 && b&0x40 != 0 {
+				v |= -1 << shift
+			}
+			return v
+		}
+		if i == last {
+			fail("integer too long at offset %d", r.offset()-1)
+		}
+	}
+}
+
+func (r *reader) u32() uint32 { return uint32(r.uleb(32)) }
+
+func (r *reader) name() string {
+	b := r.bytes(r.u32())
+	if !utf8.Valid(b) {
+		fail("invalid UTF-8 name at offset %d", r.offset()-len(b))
+	}
+	return string(b)
+}
+
+func (r *reader) valueType() Type {
+	switch b := r.byte(); b {
+	case 0x7F:
+		return TypeI32
+	case 0x7E:
+		return TypeI64
+	case 0x7D:
+		return TypeF32
+	case 0x7C:
+		return TypeF64
+	case 0x7B:
+		return TypeVec4F32
+	default:
+		fail("invalid value type %#x at offset %d", b, r.offset()-1)
+		return 0
+	}
+}
+
+func (r *reader) limits() Limits {
+	var l Limits
+	switch flag := r.byte(); flag {
+	case 0x00:
+	case 0x01:
+		l.HasMax = true
+	default:
+		fail("invalid limits flag %#x at offset %d", flag, r.offset()-1)
+	}
+	l.Min = r.u32()
+	if l.HasMax {
+		l.Max = r.u32()
+		if l.Max < l.Min {
+			fail("limits maximum %d is less than minimum %d", l.Max, l.Min)
+		}
+	}
+	if l.Min > maxPages || l.HasMax && l.Max > maxPages {
+		fail("memory size exceeds %d pages", maxPages)
+	}
+	return l
+}
+
+func (r *reader) mutable() bool {
+	switch m := r.byte(); m {
+	case 0x00:
+		return false
+	case 0x01:
+		return true
+	default:
+		fail("invalid global mutability %#x at offset %d", m, r.offset()-1)
+		return false
+	}
+}
+
+// maxPages is the largest number of 64KiB pages a memory
+// can have.
+const maxPages = 65536
+
+var magic = []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}
+
+// decoder holds the state of Decode.
+type decoder struct {
+	bin *Binary
+
+	// index spaces, including imports
+	numFuncs   int
+	numGlobals int
+	numMems    int
+}
+
+// Decode decodes the binary module b. It returns an error if b
+// is malformed, or uses instructions or sections that are not
+// supported by this package.
+func Decode(b []byte) (bin *Binary, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			de, ok := r.(decodeError)
+			if !ok {
+				panic(r)
+			}
+			bin, err = nil, de.err
+		}
+	}()
+	if len(b) < len(magic) || !bytes.Equal(b[:len(magic)], magic) {
+		return nil, fmt.Errorf("not a version 1 WebAssembly module")
+	}
+	d := &decoder{bin: &Binary{Start: -1}}
+	r := &reader{b: b, pos: len(magic)}
+	var last int
+	var codeCount int
+	for !r.done() {
+		id := r.byte()
+		size := r.u32()
+		sr := r.sub(size)
+		if id != 0 {
+			if order := sectionOrder(id); order <= last {
+				fail("section %d out of order at offset %d", id, sr.base)
+			} else {
+				last = order
+			}
+		}
+		switch id {
+		case 0:
+			name := sr.name()
+			d.bin.Custom = append(d.bin.Custom, BinaryCustom{
+				Name: name,
+				Data: sr.bytes(uint32(len(sr.b) - sr.pos)),
+			})
+		case 1:
+			d.types(sr)
+		case 2:
+			d.imports(sr)
+		case 3:
+			n := sr.u32()
+			for i := uint32(0); i < n; i++ {
+				ti := sr.u32()
+				if int(ti) >= len(d.bin.Types) {
+					fail("function %d has undefined type %d", i, ti)
+				}
+				d.bin.Functions = append(d.bin.Functions, ti)
+			}
+			d.numFuncs += int(n)
+		case 5:
+			n := sr.u32()
+			for i := uint32(0); i < n; i++ {
+				d.bin.Memories = append(d.bin.Memories, sr.limits())
+			}
+			d.numMems += int(n)
+			if d.numMems > 1 {
+				fail("multiple memories")
+			}
+		case 6:
+			n := sr.u32()
+			for i := uint32(0); i < n; i++ {
+				g := BinaryGlobal{Type: sr.valueType(), Mutable: sr.mutable()}
+				g.Init = d.constExpr(sr, g.Type)
+				d.bin.Globals = append(d.bin.Globals, g)
+				d.numGlobals++
+			}
+		case 7:
+			d.exports(sr)
+		case 8:
+			d.bin.Start = int(sr.u32())
+			if d.bin.Start >= d.numFuncs {
+				fail("start function %d is not defined", d.bin.Start)
+			}
+		case 10:
+			n := int(sr.u32())
+			if n != len(d.bin.Functions) {
+				fail("code section has %d entries, expected %d", n, len(d.bin.Functions))
+			}
+			for i := 0; i < n; i++ {
+				cr := sr.sub(sr.u32())
+				d.bin.Code = append(d.bin.Code, d.code(cr, i))
+			}
+			codeCount = n
+		case 11:
+			d.data(sr)
+		case 12:
+			// data count
+			sr.u32()
+		default:
+			fail("unsupported section %d at offset %d", id, sr.base)
+		}
+		if !sr.done() {
+			fail("section %d has %d trailing bytes", id, len(sr.b)-sr.pos)
+		}
+	}
+	if codeCount != len(d.bin.Functions) {
+		fail("missing code section")
+	}
+	return d.bin, nil
+}
+
+// sectionOrder returns the position of the section id in a
+// module, since the data count section precedes the code section.
+func sectionOrder(id byte) int {
+	if id == 12 {
+		return 2*10 - 1
+	}
+	return 2 * int(id)
+}
+
+func (d *decoder) types(r *reader) {
+	n := r.u32()
+	for i := uint32(0); i < n; i++ {
+		if b := r.byte(); b != 0x60 {
+			fail("invalid function type %#x at offset %d", b, r.offset()-1)
+		}
+		var sig Signature
+		np := r.u32()
+		for j := uint32(0); j < np; j++ {
+			sig.Params = append(sig.Params, r.valueType())
+		}
+		nr := r.u32()
+		for j := uint32(0); j < nr; j++ {
+			sig.Results = append(sig.Results, r.valueType())
+		}
+		d.bin.Types = append(d.bin.Types, sig)
+	}
+}
+
+func (d *decoder) imports(r *reader) {
+	n := r.u32()
+	for i := uint32(0); i < n; i++ {
+		imp := BinaryImport{Module: r.name(), Name: r.name(), Kind: ExternKind(r.byte())}
+		switch imp.Kind {
+		case ExternFunction:
+			imp.Type = r.u32()
+			if int(imp.Type) >= len(d.bin.Types) {
+				fail("import %s.%s has undefined type %d", imp.Module, imp.Name, imp.Type)
+			}
+			d.numFuncs++
+		case ExternMemory:
+			imp.Memory = r.limits()
+			d.numMems++
+			if d.numMems > 1 {
+				fail("multiple memories")
+			}
+		case ExternGlobal:
+			imp.Global = r.valueType()
+			imp.Mutable = r.mutable()
+			d.numGlobals++
+		default:
+			fail("unsupported import kind %v for %s.%s", imp.Kind, imp.Module, imp.Name)
+		}
+		d.bin.Imports = append(d.bin.Imports, imp)
+	}
+}
+
+func (d *decoder) exports(r *reader) {
+	n := r.u32()
+	names := make(map[string]bool)
+	for i := uint32(0); i < n; i++ {
+		e := BinaryExport{Name: r.name(), Kind: ExternKind(r.byte()), Index: r.u32()}
+		if names[e.Name] {
+			fail("duplicate export %q", e.Name)
+		}
+		names[e.Name] = true
+		var limit int
+		switch e.Kind {
+		case ExternFunction:
+			limit = d.numFuncs
+		case ExternMemory:
+			limit = d.numMems
+		case ExternGlobal:
+			limit = d.numGlobals
+		default:
+			fail("unsupported export kind %v for %q", e.Kind, e.Name)
+		}
+		if int(e.Index) >= limit {
+			fail("export %q refers to undefined %v %d", e.Name, e.Kind, e.Index)
+		}
+		d.bin.Exports = append(d.bin.Exports, e)
+	}
+}
+
+func (d *decoder) data(r *reader) {
+	n := r.u32()
+	for i := uint32(0); i < n; i++ {
+		var seg BinaryData
+		switch flag := r.u32(); flag {
+		case 0:
+			seg.Active = true
+		case 1:
+		case 2:
+			seg.Active = true
+			if mem := r.u32(); mem != 0 {
+				fail("data segment %d refers to memory %d", i, mem)
+			}
+		default:
+			fail("invalid data segment flag %d", flag)
+		}
+		if seg.Active {
+			if d.numMems == 0 {
+				fail("data segment %d without memory", i)
+			}
+			seg.Offset = d.constExpr(r, TypeI32)
+		}
+		seg.Init = r.bytes(r.u32())
+		d.bin.Data = append(d.bin.Data, seg)
+	}
+}
+
+// code decodes the body of the i-th defined function.
+func (d *decoder) code(r *reader, i int) BinaryCode {
+	sig := d.bin.Types[d.bin.Functions[i]]
+	var c BinaryCode
+	n := r.u32()
+	total := uint64(len(sig.Params))
+	for j := uint32(0); j < n; j++ {
+		cnt := r.u32()
+		t := r.valueType()
+		total += uint64(cnt)
+		if total > math.MaxUint16 {
+			fail("function %d has too many locals", i)
+		}
+		for k := uint32(0); k < cnt; k++ {
+			c.Locals = append(c.Locals, t)
+		}
+	}
+	body, err := d.expr(r, int(total))
+	if err != nil {
+		fail("function %d: %s", i, err)
+	}
+	c.Body = body
+	if !r.done() {
+		fail("function %d has trailing bytes", i)
+	}
+	return c
+}
+
+// constExpr decodes a constant expression producing a value
+// of type t.
+func (d *decoder) constExpr(r *reader, t Type) []Instr {
+	body, err := d.expr(r, -1)
+	if err != nil {
+		fail("constant expression: %s", err)
+	}
+	if len(body) != 1 {
+		fail("constant expression at offset %d must be a single instruction", r.offset())
+	}
+	var got Type
+	switch in := body[0]; in.Op {
+	case 0x41:
+		got = TypeI32
+	case 0x42:
+		got = TypeI64
+	case 0x43:
+		got = TypeF32
+	case 0x44:
+		got = TypeF64
+	case prefixSIMD | 12:
+		got = TypeVec4F32
+	case 0x23:
+		if int(in.Index) >= len(d.bin.Imports) || d.importedGlobal(in.Index) == nil {
+			fail("constant expression refers to global %d, which is not imported", in.Index)
+		}
+		got = d.importedGlobal(in.Index).Global
+	default:
+		fail("unsupported constant expression instruction %v", in.Op)
+	}
+	if got != t {
+		fail("constant expression has type %v, expected %v", got, t)
+	}
+	return body
+}
+
+// importedGlobal returns the import of global idx, or nil if
+// it is not imported.
+func (d *decoder) importedGlobal(idx uint32) *BinaryImport {
+	for i := range d.bin.Imports {
+		imp := &d.bin.Imports[i]
+		if imp.Kind != ExternGlobal {
+			continue
+		}
+		if idx == 0 {
+			return imp
+		}
+		idx--
+	}
+	return nil
+}
+
+// expr decodes instructions up to and including the final
+// end, which is not returned. nlocals is the number of locals
+// of the enclosing function, or -1 for a constant expression.
+func (d *decoder) expr(r *reader, nlocals int) (body []Instr, err error) {
+	// ifs records whether each open block is an if.
+	var ifs []bool
+	for {
+		at := r.offset()
+		in := Instr{Op: Opcode(r.byte())}
+		switch in.Op {
+		case 0xFC:
+			in.Op = prefixMisc | Opcode(r.u32())
+		case 0xFD:
+			sub := r.u32()
+			if sub > 0xFF {
+				return nil, fmt.Errorf("unsupported instruction 0xfd %d at offset %d", sub, at)
+			}
+			in.Op = prefixSIMD | Opcode(sub)
+		}
+		info, ok := opcodes[in.Op]
+		if !ok {
+			return nil, fmt.Errorf("unsupported instruction %v at offset %d", in.Op, at)
+		}
+		if nlocals < 0 && info.imm != immI32 && info.imm != immI64 && info.imm != immF32 &&
+			info.imm != immF64 && info.imm != immV128 && in.Op != 0x23 && in.Op != 0x0B {
+			return nil, fmt.Errorf("unsupported constant expression instruction %v", in.Op)
+		}
+		switch in.Op {
+		case 0x05:
+			if len(ifs) == 0 || !ifs[len(ifs)-1] {
+				return nil, fmt.Errorf("else outside of if at offset %d", at)
+			}
+			ifs[len(ifs)-1] = false
+		case 0x0B:
+			if len(ifs) == 0 {
+				return body, nil
+			}
+			ifs = ifs[:len(ifs)-1]
+		}
+		switch info.imm {
+		case immBlock:
+			in.Block = d.blockType(r)
+			ifs = append(ifs, in.Op == 0x04)
+		case immLabel:
+			in.Index = r.u32()
+			if int(in.Index) > len(ifs) {
+				return nil, fmt.Errorf("branch to undefined label %d at offset %d", in.Index, at)
+			}
+		case immLabels:
+			n := r.u32()
+			for i := uint32(0); i <= n; i++ {
+				l := r.u32()
+				if int(l) > len(ifs) {
+					return nil, fmt.Errorf("branch to undefined label %d at offset %d", l, at)
+				}
+				in.Labels = append(in.Labels, l)
+			}
+		case immFunc:
+			in.Index = r.u32()
+			if int(in.Index) >= d.numFuncs {
+				return nil, fmt.Errorf("call to undefined function %d at offset %d", in.Index, at)
+			}
+		case immLocal:
+			in.Index = r.u32()
+			if int(in.Index) >= nlocals {
+				return nil, fmt.Errorf("local %d is not defined at offset %d", in.Index, at)
+			}
+		case immGlobal:
+			in.Index = r.u32()
+			if int(in.Index) >= d.numGlobals {
+				return nil, fmt.Errorf("global %d is not defined at offset %d", in.Index, at)
+			}
+		case immSelect:
+			if n := r.u32(); n != 1 {
+				return nil, fmt.Errorf("select must have one type at offset %d", at)
+			}
+			in.Block.Results = []Type{r.valueType()}
+		case immMemory:
+			if r.byte() != 0 {
+				return nil, fmt.Errorf("memory index must be zero at offset %d", at)
+			}
+		case immMemarg, immMemargLane:
+			in.Align = r.u32()
+			in.Offset = r.u32()
+		case immI32:
+			in.Bits = uint64(uint32(r.sleb(32)))
+		case immI64:
+			in.Bits = uint64(r.sleb(64))
+		case immF32:
+			in.Bits = uint64(binary.LittleEndian.Uint32(r.bytes(4)))
+		case immF64:
+			in.Bits = binary.LittleEndian.Uint64(r.bytes(8))
+		case immV128:
+			copy(in.V128[:], r.bytes(16))
+		case immShuffle:
+			copy(in.V128[:], r.bytes(16))
+			for _, l := range in.V128 {
+				if l >= 32 {
+					return nil, fmt.Errorf("shuffle lane %d out of range at offset %d", l, at)
+				}
+			}
+		}
+		switch info.imm {
+		case immMemory, immMemarg, immMemargLane:
+			if d.numMems == 0 {
+				return nil, fmt.Errorf("%v without memory at offset %d", in.Op, at)
+			}
+		}
+		if info.imm == immLane || info.imm == immMemargLane {
+			in.Lane = r.byte()
+			if int(in.Lane) >= laneCount(in.Op) {
+				return nil, fmt.Errorf("lane %d out of range at offset %d", in.Lane, at)
+			}
+		}
+		body = append(body, in)
+	}
+}
+
+// blockType reads the type of a block, loop or if.
+func (d *decoder) blockType(r *reader) Signature {
+	if !r.done() {
+		switch b := r.b[r.pos]; b {
+		case 0x40:
+			r.byte()
+			return Signature{}
+		case 0x7F, 0x7E, 0x7D, 0x7C, 0x7B:
+			return Signature{Results: []Type{r.valueType()}}
+		}
+	}
+	ti := r.sleb(33)
+	if ti < 0 || ti >= int64(len(d.bin.Types)) {
+		fail("invalid block type %d at offset %d", ti, r.offset())
+	}
+	return d.bin.Types[ti]
+}
+
+// nameSection holds the contents of a name section.
+type nameSection struct {
+	module  string
+	funcs   nameMap
+	locals  map[uint32]nameMap
+	globals nameMap
+}
+
+// decodeNames decodes the contents of a name section,
+// ignoring unknown subsections.
+func decodeNames(b []byte) (names nameSection, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			de, ok := r.(decodeError)
+			if !ok {
+				panic(r)
+			}
+			err = de.err
+		}
+	}()
+	names.locals = make(map[uint32]nameMap)
+	readMap := func(r *reader) nameMap {
+		nm := make(nameMap)
+		n := r.u32()
+		for i := uint32(0); i < n; i++ {
+			idx := r.u32()
+			nm[idx] = r.name()
+		}
+		return nm
+	}
+	r := &reader{b: b}
+	for !r.done() {
+		id := r.byte()
+		sr := r.sub(r.u32())
+		switch id {
+		case 0:
+			names.module = sr.name()
+		case 1:
+			names.funcs = readMap(sr)
+		case 2:
+			n := sr.u32()
+			for i := uint32(0); i < n; i++ {
+				idx := sr.u32()
+				names.locals[idx] = readMap(sr)
+			}
+		case 7:
+			names.globals = readMap(sr)
+		default:
+			continue
+		}
+		if !sr.done() {
+			fail("name subsection %d has %d trailing bytes", id, len(sr.b)-sr.pos)
+		}
+	}
+	return names, nil
+}
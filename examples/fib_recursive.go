@@ -1,3 +1,6 @@
+//go:build ignore
+// +build ignore
+
 package main
 
 import (
@@ -9,39 +12,30 @@ import (
 func main() {
 	m := new(wasm.Module)
 
-	end := m.GlobalF32(0)
-	v := m.GlobalF32(0)
-	vn := m.GlobalF32(1)
-	res := m.GlobalF32(0)
-
-	f := m.Function()
-	lv := f.LocalF32()
-	f.Body(
-		wasm.AssignF32(lv, wasm.ConstF32(0)),
-		wasm.AssignF32(v, wasm.ConstF32(0)),
-		wasm.AssignF32(vn, wasm.ConstF32(1)),
-		wasm.ForRangeF32{
-			End: end,
-			Do: func(i wasm.F32) []wasm.Instruction {
-				return []wasm.Instruction{
-					wasm.AssignF32(lv, wasm.AddF32(v, vn)),
-					wasm.AssignF32(v, vn),
-					wasm.AssignF32(vn, lv),
-				}
-			},
+	fib := m.TypedFunction(wasm.Signature{
+		Params:  []wasm.Type{wasm.TypeF32},
+		Results: []wasm.Type{wasm.TypeF32},
+	})
+	n := fib.ParamF32(0)
+	fib.Body(
+		// if n < 2, return n
+		wasm.If{
+			Condition: wasm.LtF32(n, wasm.ConstF32(2)),
+			Then:      []wasm.Instruction{wasm.ReturnValue(n)},
 		},
-		wasm.AssignF32(res, v),
+		wasm.AddF32(
+			wasm.CallF32(fib, wasm.SubF32(n, wasm.ConstF32(1))),
+			wasm.CallF32(fib, wasm.SubF32(n, wasm.ConstF32(2))),
+		),
 	)
 
-	m.Export("main", f)
-	m.Export("end", end)
-	m.Export("res", res)
+	m.Export("fib", fib)
 
 	b, err := m.Compile()
 	if err != nil {
 		panic(err)
 	}
-	if err := os.WriteFile("fib.wasm", b, 0666); err != nil {
+	if err := os.WriteFile("fib_recursive.wasm", b, 0666); err != nil {
 		panic(err)
 	}
 }
@@ -64,18 +64,24 @@ func (v *varF32) writeImportDesc(m *Module, out io.Writer) error {
 	}.encode(out)
 }
 
-type localF32 uint32
+type localF32 struct{ local }
 
 func (l localF32) isF32() {}
 
-func (l localF32) write(out instCtx) error {
-	out.Write([]byte{0x20}) // local.get x
-	writeu32(uint32(l), out)
-	return nil
+// letF32 stores x in a temporary local so that it can
+// be used more than once without being evaluated again.
+type letF32 struct {
+	x  F32
+	in func(t F32) Instruction
 }
 
-func (l localF32) set(out io.Writer) error {
-	out.Write([]byte{0x21}) // local.set x
-	writeu32(uint32(l), out)
-	return nil
+func (l letF32) write(c instCtx) error {
+	t := c.fn.LocalF32()
+	if err := l.x.write(c); err != nil {
+		return err
+	}
+	if err := t.set(c); err != nil {
+		return err
+	}
+	return l.in(t).write(c)
 }
@@ -0,0 +1,191 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// F64 represents a float64 node
+type F64 interface {
+	Instruction
+	isF64()
+}
+
+// MutableF64 represents a mutable float64 node
+type MutableF64 interface {
+	F64
+	set(out io.Writer) error
+}
+
+// GlobalF64 represents a mutable float64 defined
+// in the global scope of the WASM module.
+type GlobalF64 interface {
+	MutableF64
+	Exportable
+}
+
+type varF64 struct {
+	init float64
+	idx  uint32
+}
+
+func (v *varF64) isF64() {}
+
+func (v *varF64) incGlobalIndex() {
+	v.idx++
+}
+
+func (v *varF64) setGlobalIndex(i uint32) {
+	v.idx = i
+}
+
+func (v *varF64) globalIndex() uint32 {
+	return v.idx
+}
+
+func (v *varF64) write(out instCtx) error {
+	out.Write([]byte{0x23}) // global.get x
+	writeu32(v.idx, out)
+	return nil
+}
+
+func (v *varF64) set(out io.Writer) error {
+	out.Write([]byte{0x24}) // global.set x
+	writeu32(v.idx, out)
+	return nil
+}
+
+func (v *varF64) isExportable() {}
+
+func (v *varF64) writeImportDesc(m *Module, out io.Writer) error {
+	out.Write([]byte{0x03})
+	return globaltype{
+		mutable: true,
+		valuetype: valuetype{
+			numtype: f64,
+		},
+	}.encode(out)
+}
+
+type localF64 struct{ local }
+
+func (l localF64) isF64() {}
+
+type opsF64 ops
+
+func (o opsF64) isF64() {}
+
+func (o opsF64) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+// ConstF64 is a constant F64 value.
+type ConstF64 float64
+
+func (c ConstF64) isF64() {}
+
+func (c ConstF64) write(out instCtx) error {
+	constF64.write(out)
+	binary.Write(out, binary.LittleEndian, c)
+	return nil
+}
+
+// AssignF64 assigns the value of v to dst.
+func AssignF64(dst MutableF64, v F64) Instruction {
+	return assignF64{dst: dst, v: v}
+}
+
+type assignF64 struct {
+	dst MutableF64
+	v   F64
+}
+
+func (a assignF64) write(out instCtx) error {
+	if err := a.v.write(out); err != nil {
+		return err
+	}
+	if err := a.dst.set(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AbsF64 returns the absolute value of a.
+func AbsF64(a F64) F64 { return opsF64{a, absf64} }
+
+// NegF64 returns the result of negating a.
+func NegF64(a F64) F64 { return opsF64{a, negf64} }
+
+// CeilF64 returns a rounded up.
+func CeilF64(a F64) F64 { return opsF64{a, ceilf64} }
+
+// FloorF64 returns a rounded down.
+func FloorF64(a F64) F64 { return opsF64{a, floorf64} }
+
+// TruncF64 returns a rounded towards zero.
+func TruncF64(a F64) F64 { return opsF64{a, truncf64} }
+
+// NearestF64 returns the nearest integral value to a.
+func NearestF64(a F64) F64 { return opsF64{a, nearestf64} }
+
+// SqrtF64 returns the square root of a.
+func SqrtF64(a F64) F64 { return opsF64{a, sqrtf64} }
+
+// AddF64 returns the sum of a and b.
+func AddF64(a, b F64) F64 { return opsF64{a, b, addf64} }
+
+// SubF64 returns the difference of a and b.
+func SubF64(a, b F64) F64 { return opsF64{a, b, subf64} }
+
+// MulF64 returns the product of a and b.
+func MulF64(a, b F64) F64 { return opsF64{a, b, mulf64} }
+
+// DivF64 returns the quotient of a and b.
+func DivF64(a, b F64) F64 { return opsF64{a, b, divf64} }
+
+// MinF64 returns the minimum of a and b.
+func MinF64(a, b F64) F64 { return opsF64{a, b, minf64} }
+
+// MaxF64 returns the maximum of a and b.
+func MaxF64(a, b F64) F64 { return opsF64{a, b, maxf64} }
+
+// CopysignF64 returns a with the sign of b.
+func CopysignF64(a, b F64) F64 { return opsF64{a, b, copysignf64} }
+
+// EqF64 returns whether a == b.
+func EqF64(a, b F64) Bool { return opsBool{a, b, eqf64} }
+
+// NeF64 returns whether a != b.
+func NeF64(a, b F64) Bool { return opsBool{a, b, nef64} }
+
+// LtF64 returns whether a < b.
+func LtF64(a, b F64) Bool { return opsBool{a, b, ltf64} }
+
+// GtF64 returns whether a > b.
+func GtF64(a, b F64) Bool { return opsBool{a, b, gtf64} }
+
+// LeF64 returns whether a <= b.
+func LeF64(a, b F64) Bool { return opsBool{a, b, lef64} }
+
+// GeF64 returns whether a >= b.
+func GeF64(a, b F64) Bool { return opsBool{a, b, gef64} }
+
+// F32ToF64 converts a to an F64 without loss of precision.
+func F32ToF64(a F32) F64 { return opsF64{a, promotef32F64} }
+
+// F64ToF32 converts a to the nearest F32.
+func F64ToF32(a F64) F32 { return opsF32{a, demotef64F32} }
+
+// I32ToF64 converts the signed integer a to an F64.
+func I32ToF64(a I32) F64 { return opsF64{a, converti32sF64} }
+
+// UI32ToF64 converts the unsigned integer a to an F64.
+func UI32ToF64(a I32) F64 { return opsF64{a, converti32uF64} }
+
+// F64ToI32 converts a to a signed integer, truncating towards
+// zero. Values out of range saturate, and NaN converts to 0.
+func F64ToI32(a F64) I32 { return opsI32{a, truncSatf64sI32} }
+
+// F64ToUI32 converts a to an unsigned integer, truncating towards
+// zero. Values out of range saturate, and NaN converts to 0.
+func F64ToUI32(a F64) I32 { return opsI32{a, truncSatf64uI32} }
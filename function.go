@@ -2,6 +2,7 @@ package wasm
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 )
 
@@ -9,6 +10,7 @@ import (
 type Callable interface {
 	isFunction()
 	index() uint32
+	signature() Signature
 }
 
 // Function represents a callable wasm function.
@@ -18,65 +20,258 @@ type Function interface {
 	Exportable
 
 	// Body sets the body of the Function to inst.
+	// If the Function has results, they are the values
+	// left on the stack by inst, or the values passed
+	// to ReturnValue.
 	Body(inst ...Instruction)
 
 	// LocalF32 returns a local MutableF32 that can
 	// be used inside the function. Using the returned
 	// value in Instructions provided to a different
-	// Function will result in
+	// Function will result in an error from Compile.
 	LocalF32() MutableF32
 
-	localI32() localI32
+	// LocalI32 returns a local MutableI32 that can
+	// be used inside the function.
+	LocalI32() MutableI32
+
+	// LocalF64 returns a local MutableF64 that can
+	// be used inside the function.
+	LocalF64() MutableF64
+
+	// LocalI64 returns a local MutableI64 that can
+	// be used inside the function.
+	LocalI64() MutableI64
+
+	// LocalVec4F32 returns a local MutableVec4F32 that can
+	// be used inside the function.
+	LocalVec4F32() MutableVec4F32
+
+	// LocalVec4I32 returns a local MutableVec4I32 that can
+	// be used inside the function.
+	LocalVec4I32() MutableVec4I32
+
+	// LocalVec8I16 returns a local MutableVec8I16 that can
+	// be used inside the function.
+	LocalVec8I16() MutableVec8I16
+
+	// LocalVec16I8 returns a local MutableVec16I8 that can
+	// be used inside the function.
+	LocalVec16I8() MutableVec16I8
+
+	// LocalMat4F32 returns a local MutableMat4F32 that can
+	// be used inside the function.
+	LocalMat4F32() MutableMat4F32
+
+	// ParamF32 returns the i'th parameter of the Function,
+	// which must have been declared as TypeF32.
+	ParamF32(i int) MutableF32
+
+	// ParamI32 returns the i'th parameter of the Function,
+	// which must have been declared as TypeI32.
+	ParamI32(i int) MutableI32
+
+	// ParamF64 returns the i'th parameter of the Function,
+	// which must have been declared as TypeF64.
+	ParamF64(i int) MutableF64
+
+	// ParamI64 returns the i'th parameter of the Function,
+	// which must have been declared as TypeI64.
+	ParamI64(i int) MutableI64
+
+	// ParamVec4F32 returns the i'th parameter of the Function,
+	// which must have been declared as TypeVec4F32.
+	ParamVec4F32(i int) MutableVec4F32
+
+	// NameLocal names the local or parameter v in the name
+	// section written when Module.EmitNames has been called.
+	// v must have been returned by one of the Function's
+	// Local or Param methods. The columns of a Mat4F32 are
+	// named name.0 to name.3.
+	NameLocal(name string, v Instruction)
 }
 
-// ImportedFunction is created by a call to Module.ImportedFunction.
-// It represents a host-provided function.
+// ImportedFunction is created by a call to Module.ImportFunction
+// or Module.ImportTypedFunction. It represents a host-provided
+// function.
 type ImportedFunction interface {
 	Callable
 	importable
 }
 
 type function struct {
+	m            *Module
 	idx          uint32
 	instructions []Instruction
-	localF32Cnt  uint32
-	localI32Cnt  uint32
-	ft           functype
+	locals       []valuetype
+	sig          Signature
+	localNames   nameMap
 }
 
 func (f *function) isFunction() {}
 
 func (f *function) isExportable() {}
 
+func (f *function) signature() Signature {
+	return f.sig
+}
+
 func (f *function) functype() functype {
-	return f.ft
+	return f.sig.functype()
 }
 
 func (f *function) Body(inst ...Instruction) {
 	f.instructions = inst
 }
 
+// local is a param or local of fn, which may only be used
+// in the body of fn.
+type local struct {
+	fn  *function
+	idx uint32
+}
+
+func (l local) localRef() local { return l }
+
+// localRef is implemented by the locals and params
+// returned by a Function.
+type localRef interface {
+	localRef() local
+}
+
+// check returns an error if l is used by op outside of
+// its function.
+func (l local) check(out io.Writer, op string) error {
+	c, ok := out.(instCtx)
+	if !ok || c.fn == Function(l.fn) {
+		return nil
+	}
+	return fmt.Errorf("%s %d: local of %s used in another function",
+		op, l.idx, c.m.describe(l.fn))
+}
+
+func (l local) write(out instCtx) error {
+	if err := l.check(out, "local.get"); err != nil {
+		return err
+	}
+	out.Write([]byte{0x20}) // local.get x
+	writeu32(l.idx, out)
+	return nil
+}
+
+func (l local) set(out io.Writer) error {
+	if err := l.check(out, "local.set"); err != nil {
+		return err
+	}
+	out.Write([]byte{0x21}) // local.set x
+	writeu32(l.idx, out)
+	return nil
+}
+
+// addLocal declares a new local of type vt.
+// Locals are indexed after the function's parameters.
+func (f *function) addLocal(vt valuetype) local {
+	i := uint32(len(f.sig.Params) + len(f.locals))
+	f.locals = append(f.locals, vt)
+	return local{f, i}
+}
+
 func (f *function) LocalF32() MutableF32 {
-	l := localF32(f.localF32Cnt)
-	f.localF32Cnt++
-	return l
+	return localF32{f.addLocal(valuetype{numtype: f32})}
+}
+
+func (f *function) LocalI32() MutableI32 {
+	return localI32{f.addLocal(valuetype{numtype: i32})}
+}
+
+func (f *function) LocalF64() MutableF64 {
+	return localF64{f.addLocal(valuetype{numtype: f64})}
 }
 
-func (f *function) localI32() localI32 {
-	l := localI32(f.localI32Cnt)
-	f.localI32Cnt++
-	return l
+func (f *function) LocalI64() MutableI64 {
+	return localI64{f.addLocal(valuetype{numtype: i64})}
+}
+
+func (f *function) LocalVec4F32() MutableVec4F32 {
+	return localVec4F32{f.addLocal(valuetype{vectype: true})}
+}
+
+func (f *function) LocalVec4I32() MutableVec4I32 {
+	return localVec4I32{f.addLocal(valuetype{vectype: true})}
+}
+
+func (f *function) LocalVec8I16() MutableVec8I16 {
+	return localVec8I16{f.addLocal(valuetype{vectype: true})}
+}
+
+func (f *function) LocalVec16I8() MutableVec16I8 {
+	return localVec16I8{f.addLocal(valuetype{vectype: true})}
+}
+
+func (f *function) param(i int, t Type) local {
+	if i < 0 || i >= len(f.sig.Params) {
+		panic(fmt.Errorf("parameter %d out of range for %s", i, f.sig))
+	}
+	if f.sig.Params[i] != t {
+		panic(fmt.Errorf("parameter %d is %s, not %s", i, f.sig.Params[i], t))
+	}
+	return local{f, uint32(i)}
+}
+
+func (f *function) ParamF32(i int) MutableF32 {
+	return localF32{f.param(i, TypeF32)}
+}
+
+func (f *function) ParamI32(i int) MutableI32 {
+	return localI32{f.param(i, TypeI32)}
+}
+
+func (f *function) ParamF64(i int) MutableF64 {
+	return localF64{f.param(i, TypeF64)}
+}
+
+func (f *function) ParamI64(i int) MutableI64 {
+	return localI64{f.param(i, TypeI64)}
+}
+
+func (f *function) ParamVec4F32(i int) MutableVec4F32 {
+	return localVec4F32{f.param(i, TypeVec4F32)}
+}
+
+func (f *function) NameLocal(name string, v Instruction) {
+	switch v := v.(type) {
+	case localRef:
+		l := v.localRef()
+		if l.fn != f {
+			panic(fmt.Errorf("local %d belongs to another function", l.idx))
+		}
+		if f.localNames == nil {
+			f.localNames = make(nameMap)
+		}
+		f.localNames[l.idx] = name
+	case mat4F32:
+		for i, col := range v {
+			f.NameLocal(fmt.Sprintf("%s.%d", name, i), col)
+		}
+	default:
+		panic(fmt.Errorf("%v is not a local", v))
+	}
 }
 
 func (f *function) String() string {
 	return f.functype().String()
 }
 
-func (f *function) encode(out io.Writer) error {
+func (f *function) encode(m *Module, out io.Writer) error {
+	// locals added while writing the body are only valid
+	// for this encoding.
+	n := len(f.locals)
+	defer func() { f.locals = f.locals[:n] }()
+
 	// write body first to collect additional locals
 	body := new(bytes.Buffer)
 	for _, inst := range f.instructions {
-		if err := inst.write(instCtx{Writer: body, fn: f}); err != nil {
+		if err := inst.write(instCtx{Writer: body, fn: f, m: m}); err != nil {
 			return err
 		}
 	}
@@ -84,14 +279,27 @@ func (f *function) encode(out io.Writer) error {
 
 	// write complete function definition
 	buf := new(bytes.Buffer)
-	// vec(locals)
-	writeu32(2, buf)
-	// f32 locals
-	writeu32(f.localF32Cnt, buf)
-	valuetype{numtype: f32}.encode(buf)
-	// i32 locals
-	writeu32(f.localI32Cnt, buf)
-	valuetype{numtype: i32}.encode(buf)
+	// vec(locals), consecutive locals of the same type
+	// are grouped together.
+	type group struct {
+		n  uint32
+		vt valuetype
+	}
+	var groups []group
+	for _, vt := range f.locals {
+		if len(groups) > 0 && groups[len(groups)-1].vt == vt {
+			groups[len(groups)-1].n++
+			continue
+		}
+		groups = append(groups, group{n: 1, vt: vt})
+	}
+	writeu32(uint32(len(groups)), buf)
+	for _, g := range groups {
+		writeu32(g.n, buf)
+		if err := g.vt.encode(buf); err != nil {
+			return err
+		}
+	}
 	// expr
 	buf.Write(body.Bytes())
 
@@ -103,8 +311,12 @@ func (f *function) encode(out io.Writer) error {
 }
 
 func (f *function) writeImportDesc(m *Module, out io.Writer) error {
+	i, ok := m.functionTypeMap[f]
+	if !ok {
+		return fmt.Errorf("no type for imported function %s", f)
+	}
 	out.Write([]byte{0x0})
-	writeu32(f.idx, out)
+	writeu32(uint32(i), out)
 	return nil
 }
 
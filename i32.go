@@ -2,12 +2,67 @@ package wasm
 
 import "io"
 
+// I32 represents an int32 node
 type I32 interface {
 	Instruction
 	isI32()
 }
 
-func isI32() {}
+// MutableI32 represents a mutable int32 node
+type MutableI32 interface {
+	I32
+	set(out io.Writer) error
+}
+
+// GlobalI32 represents a mutable int32 defined
+// in the global scope of the WASM module.
+type GlobalI32 interface {
+	MutableI32
+	Exportable
+}
+
+type varI32 struct {
+	init int32
+	idx  uint32
+}
+
+func (v *varI32) isI32() {}
+
+func (v *varI32) incGlobalIndex() {
+	v.idx++
+}
+
+func (v *varI32) setGlobalIndex(i uint32) {
+	v.idx = i
+}
+
+func (v *varI32) globalIndex() uint32 {
+	return v.idx
+}
+
+func (v *varI32) write(out instCtx) error {
+	out.Write([]byte{0x23}) // global.get x
+	writeu32(v.idx, out)
+	return nil
+}
+
+func (v *varI32) set(out io.Writer) error {
+	out.Write([]byte{0x24}) // global.set x
+	writeu32(v.idx, out)
+	return nil
+}
+
+func (v *varI32) isExportable() {}
+
+func (v *varI32) writeImportDesc(m *Module, out io.Writer) error {
+	out.Write([]byte{0x03})
+	return globaltype{
+		mutable: true,
+		valuetype: valuetype{
+			numtype: i32,
+		},
+	}.encode(out)
+}
 
 type opsI32 ops
 
@@ -17,19 +72,18 @@ func (o opsI32) write(out instCtx) error {
 	return ops(o).write(out)
 }
 
-type localI32 uint32
+type localI32 struct{ local }
 
 func (l localI32) isI32() {}
 
-func (l localI32) write(out instCtx) error {
-	out.Write([]byte{0x20}) // local.get x
-	writeu32(uint32(l), out)
-	return nil
-}
+// ConstI32 is a constant I32 value.
+type ConstI32 int32
+
+func (c ConstI32) isI32() {}
 
-func (l localI32) set(out io.Writer) error {
-	out.Write([]byte{0x21}) // local.set x
-	writeu32(uint32(l), out)
+func (c ConstI32) write(out instCtx) error {
+	constI32.write(out)
+	writes32(int32(c), out)
 	return nil
 }
 
@@ -38,13 +92,16 @@ type constUI32 uint32
 func (c constUI32) isI32() {}
 
 func (c constUI32) write(out instCtx) error {
-	out.Write([]byte{0x41}) // i32.cosnt
-	writeu32(uint32(c), out)
-	return nil
+	return ConstI32(c).write(out)
+}
+
+// AssignI32 assigns the value of v to dst.
+func AssignI32(dst MutableI32, v I32) Instruction {
+	return assignI32{dst: dst, v: v}
 }
 
 type assignI32 struct {
-	dst localI32
+	dst MutableI32
 	v   I32
 }
 
@@ -58,22 +115,120 @@ func (a assignI32) write(out instCtx) error {
 	return nil
 }
 
-func addi32(a, b I32) I32 {
-	return opsI32{
-		a,
-		b,
-		op(0x6A),
-	}
-}
+// ClzI32 returns the number of leading zero bits of a.
+func ClzI32(a I32) I32 { return opsI32{a, clzI32} }
 
-func muli32(a, b I32) I32 {
-	return opsI32{
-		a,
-		b,
-		op(0x6C),
-	}
-}
+// CtzI32 returns the number of trailing zero bits of a.
+func CtzI32(a I32) I32 { return opsI32{a, ctzI32} }
+
+// PopcntI32 returns the number of one bits of a.
+func PopcntI32(a I32) I32 { return opsI32{a, popcntI32} }
+
+// AddI32 returns the sum of a and b.
+func AddI32(a, b I32) I32 { return opsI32{a, b, addI32} }
+
+// SubI32 returns the difference of a and b.
+func SubI32(a, b I32) I32 { return opsI32{a, b, subI32} }
+
+// MulI32 returns the product of a and b.
+func MulI32(a, b I32) I32 { return opsI32{a, b, mulI32} }
+
+// DivSI32 returns the signed quotient of a and b.
+// Division by zero or overflow traps.
+func DivSI32(a, b I32) I32 { return opsI32{a, b, divSI32} }
+
+// DivUI32 returns the unsigned quotient of a and b.
+// Division by zero traps.
+func DivUI32(a, b I32) I32 { return opsI32{a, b, divUI32} }
+
+// RemSI32 returns the signed remainder of a divided by b.
+// Division by zero traps.
+func RemSI32(a, b I32) I32 { return opsI32{a, b, remSI32} }
+
+// RemUI32 returns the unsigned remainder of a divided by b.
+// Division by zero traps.
+func RemUI32(a, b I32) I32 { return opsI32{a, b, remUI32} }
+
+// AndI32 returns the bitwise and of a and b.
+func AndI32(a, b I32) I32 { return opsI32{a, b, andI32} }
+
+// OrI32 returns the bitwise or of a and b.
+func OrI32(a, b I32) I32 { return opsI32{a, b, orI32} }
+
+// XorI32 returns the bitwise exclusive or of a and b.
+func XorI32(a, b I32) I32 { return opsI32{a, b, xorI32} }
+
+// ShlI32 returns a shifted left by b modulo 32 bits.
+func ShlI32(a, b I32) I32 { return opsI32{a, b, shlI32} }
+
+// ShrSI32 returns a arithmetically shifted right by b modulo 32 bits.
+func ShrSI32(a, b I32) I32 { return opsI32{a, b, shrSI32} }
+
+// ShrUI32 returns a logically shifted right by b modulo 32 bits.
+func ShrUI32(a, b I32) I32 { return opsI32{a, b, shrUI32} }
+
+// RotlI32 returns a rotated left by b bits.
+func RotlI32(a, b I32) I32 { return opsI32{a, b, rotlI32} }
+
+// RotrI32 returns a rotated right by b bits.
+func RotrI32(a, b I32) I32 { return opsI32{a, b, rotrI32} }
+
+// EqzI32 returns whether a is zero.
+func EqzI32(a I32) Bool { return opsBool{a, eqzI32} }
+
+// EqI32 returns whether a == b.
+func EqI32(a, b I32) Bool { return opsBool{a, b, eqI32} }
+
+// NeI32 returns whether a != b.
+func NeI32(a, b I32) Bool { return opsBool{a, b, neI32} }
+
+// LtI32 returns whether a < b as signed integers.
+func LtI32(a, b I32) Bool { return opsBool{a, b, ltSI32} }
+
+// LtUI32 returns whether a < b as unsigned integers.
+func LtUI32(a, b I32) Bool { return opsBool{a, b, ltUI32} }
+
+// GtI32 returns whether a > b as signed integers.
+func GtI32(a, b I32) Bool { return opsBool{a, b, gtSI32} }
+
+// GtUI32 returns whether a > b as unsigned integers.
+func GtUI32(a, b I32) Bool { return opsBool{a, b, gtUI32} }
+
+// LeI32 returns whether a <= b as signed integers.
+func LeI32(a, b I32) Bool { return opsBool{a, b, leSI32} }
+
+// LeUI32 returns whether a <= b as unsigned integers.
+func LeUI32(a, b I32) Bool { return opsBool{a, b, leUI32} }
+
+// GeI32 returns whether a >= b as signed integers.
+func GeI32(a, b I32) Bool { return opsBool{a, b, geSI32} }
+
+// GeUI32 returns whether a >= b as unsigned integers.
+func GeUI32(a, b I32) Bool { return opsBool{a, b, geUI32} }
+
+// I32ToF32 converts the signed integer a to an F32.
+func I32ToF32(a I32) F32 { return opsF32{a, converti32sF32} }
+
+// UI32ToF32 converts the unsigned integer a to an F32.
+func UI32ToF32(a I32) F32 { return opsF32{a, converti32uF32} }
+
+// F32ToI32 converts a to a signed integer, truncating towards
+// zero. Values out of range saturate, and NaN converts to 0.
+func F32ToI32(a F32) I32 { return opsI32{a, truncSatf32sI32} }
+
+// F32ToUI32 converts a to an unsigned integer, truncating towards
+// zero. Values out of range saturate, and NaN converts to 0.
+func F32ToUI32(a F32) I32 { return opsI32{a, truncSatf32uI32} }
+
+// F32Bits returns the IEEE 754 binary representation of a.
+func F32Bits(a F32) I32 { return opsI32{a, reinterpretf32I32} }
+
+// F32FromBits returns the F32 with the IEEE 754 binary
+// representation a.
+func F32FromBits(a I32) F32 { return opsF32{a, reinterpreti32F32} }
 
+// castF32I32 converts a to an unsigned integer, trapping
+// if a is out of range.
 func castF32I32(a F32) I32 {
 	return opsI32{
 		a,
@@ -2,34 +2,242 @@ package wasm
 
 import "io"
 
+// I64 represents an int64 node
 type I64 interface {
+	Instruction
 	isI64()
 }
 
-func isI64() {}
+// MutableI64 represents a mutable int64 node
+type MutableI64 interface {
+	I64
+	set(out io.Writer) error
+}
 
-type localI64 uint64
+// GlobalI64 represents a mutable int64 defined
+// in the global scope of the WASM module.
+type GlobalI64 interface {
+	MutableI64
+	Exportable
+}
 
-func (l localI64) isI64() {}
+type varI64 struct {
+	init int64
+	idx  uint32
+}
+
+func (v *varI64) isI64() {}
+
+func (v *varI64) incGlobalIndex() {
+	v.idx++
+}
+
+func (v *varI64) setGlobalIndex(i uint32) {
+	v.idx = i
+}
 
-func (l localI64) write(out instCtx) error {
-	out.Write([]byte{0x20}) // local.get x
-	writeu64(uint64(l), out)
+func (v *varI64) globalIndex() uint32 {
+	return v.idx
+}
+
+func (v *varI64) write(out instCtx) error {
+	out.Write([]byte{0x23}) // global.get x
+	writeu32(v.idx, out)
 	return nil
 }
 
-func (l localI64) set(out io.Writer) error {
-	out.Write([]byte{0x21}) // local.set x
-	writeu64(uint64(l), out)
+func (v *varI64) set(out io.Writer) error {
+	out.Write([]byte{0x24}) // global.set x
+	writeu32(v.idx, out)
 	return nil
 }
 
-type constUI64 uint64
+func (v *varI64) isExportable() {}
+
+func (v *varI64) writeImportDesc(m *Module, out io.Writer) error {
+	out.Write([]byte{0x03})
+	return globaltype{
+		mutable: true,
+		valuetype: valuetype{
+			numtype: i64,
+		},
+	}.encode(out)
+}
+
+type opsI64 ops
 
-func (c constUI64) isI64() {}
+func (o opsI64) isI64() {}
 
-func (c constUI64) write(out instCtx) error {
+func (o opsI64) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+type localI64 struct{ local }
+
+func (l localI64) isI64() {}
+
+// ConstI64 is a constant I64 value.
+type ConstI64 int64
+
+func (c ConstI64) isI64() {}
+
+func (c ConstI64) write(out instCtx) error {
 	constI64.write(out)
-	writeu64(uint64(c), out)
+	writes64(int64(c), out)
 	return nil
 }
+
+// AssignI64 assigns the value of v to dst.
+func AssignI64(dst MutableI64, v I64) Instruction {
+	return assignI64{dst: dst, v: v}
+}
+
+type assignI64 struct {
+	dst MutableI64
+	v   I64
+}
+
+func (a assignI64) write(out instCtx) error {
+	if err := a.v.write(out); err != nil {
+		return err
+	}
+	if err := a.dst.set(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ClzI64 returns the number of leading zero bits of a.
+func ClzI64(a I64) I64 { return opsI64{a, clzI64} }
+
+// CtzI64 returns the number of trailing zero bits of a.
+func CtzI64(a I64) I64 { return opsI64{a, ctzI64} }
+
+// PopcntI64 returns the number of one bits of a.
+func PopcntI64(a I64) I64 { return opsI64{a, popcntI64} }
+
+// AddI64 returns the sum of a and b.
+func AddI64(a, b I64) I64 { return opsI64{a, b, addI64} }
+
+// SubI64 returns the difference of a and b.
+func SubI64(a, b I64) I64 { return opsI64{a, b, subI64} }
+
+// MulI64 returns the product of a and b.
+func MulI64(a, b I64) I64 { return opsI64{a, b, mulI64} }
+
+// DivSI64 returns the signed quotient of a and b.
+// Division by zero or overflow traps.
+func DivSI64(a, b I64) I64 { return opsI64{a, b, divSI64} }
+
+// DivUI64 returns the unsigned quotient of a and b.
+// Division by zero traps.
+func DivUI64(a, b I64) I64 { return opsI64{a, b, divUI64} }
+
+// RemSI64 returns the signed remainder of a divided by b.
+// Division by zero traps.
+func RemSI64(a, b I64) I64 { return opsI64{a, b, remSI64} }
+
+// RemUI64 returns the unsigned remainder of a divided by b.
+// Division by zero traps.
+func RemUI64(a, b I64) I64 { return opsI64{a, b, remUI64} }
+
+// AndI64 returns the bitwise and of a and b.
+func AndI64(a, b I64) I64 { return opsI64{a, b, andI64} }
+
+// OrI64 returns the bitwise or of a and b.
+func OrI64(a, b I64) I64 { return opsI64{a, b, orI64} }
+
+// XorI64 returns the bitwise exclusive or of a and b.
+func XorI64(a, b I64) I64 { return opsI64{a, b, xorI64} }
+
+// ShlI64 returns a shifted left by b modulo 64 bits.
+func ShlI64(a, b I64) I64 { return opsI64{a, b, shlI64} }
+
+// ShrSI64 returns a arithmetically shifted right by b modulo 64 bits.
+func ShrSI64(a, b I64) I64 { return opsI64{a, b, shrSI64} }
+
+// ShrUI64 returns a logically shifted right by b modulo 64 bits.
+func ShrUI64(a, b I64) I64 { return opsI64{a, b, shrUI64} }
+
+// RotlI64 returns a rotated left by b bits.
+func RotlI64(a, b I64) I64 { return opsI64{a, b, rotlI64} }
+
+// RotrI64 returns a rotated right by b bits.
+func RotrI64(a, b I64) I64 { return opsI64{a, b, rotrI64} }
+
+// EqzI64 returns whether a is zero.
+func EqzI64(a I64) Bool { return opsBool{a, eqzI64} }
+
+// EqI64 returns whether a == b.
+func EqI64(a, b I64) Bool { return opsBool{a, b, eqI64} }
+
+// NeI64 returns whether a != b.
+func NeI64(a, b I64) Bool { return opsBool{a, b, neI64} }
+
+// LtI64 returns whether a < b as signed integers.
+func LtI64(a, b I64) Bool { return opsBool{a, b, ltSI64} }
+
+// LtUI64 returns whether a < b as unsigned integers.
+func LtUI64(a, b I64) Bool { return opsBool{a, b, ltUI64} }
+
+// GtI64 returns whether a > b as signed integers.
+func GtI64(a, b I64) Bool { return opsBool{a, b, gtSI64} }
+
+// GtUI64 returns whether a > b as unsigned integers.
+func GtUI64(a, b I64) Bool { return opsBool{a, b, gtUI64} }
+
+// LeI64 returns whether a <= b as signed integers.
+func LeI64(a, b I64) Bool { return opsBool{a, b, leSI64} }
+
+// LeUI64 returns whether a <= b as unsigned integers.
+func LeUI64(a, b I64) Bool { return opsBool{a, b, leUI64} }
+
+// GeI64 returns whether a >= b as signed integers.
+func GeI64(a, b I64) Bool { return opsBool{a, b, geSI64} }
+
+// GeUI64 returns whether a >= b as unsigned integers.
+func GeUI64(a, b I64) Bool { return opsBool{a, b, geUI64} }
+
+// I32ToI64 sign-extends a to an I64.
+func I32ToI64(a I32) I64 { return opsI64{a, extendi32sI64} }
+
+// UI32ToI64 zero-extends a to an I64.
+func UI32ToI64(a I32) I64 { return opsI64{a, extendi32uI64} }
+
+// I64ToI32 returns the low 32 bits of a.
+func I64ToI32(a I64) I32 { return opsI32{a, wrapi64I32} }
+
+// I64ToF32 converts the signed integer a to an F32.
+func I64ToF32(a I64) F32 { return opsF32{a, converti64sF32} }
+
+// UI64ToF32 converts the unsigned integer a to an F32.
+func UI64ToF32(a I64) F32 { return opsF32{a, converti64uF32} }
+
+// I64ToF64 converts the signed integer a to an F64.
+func I64ToF64(a I64) F64 { return opsF64{a, converti64sF64} }
+
+// UI64ToF64 converts the unsigned integer a to an F64.
+func UI64ToF64(a I64) F64 { return opsF64{a, converti64uF64} }
+
+// F32ToI64 converts a to a signed integer, truncating towards
+// zero. Values out of range saturate, and NaN converts to 0.
+func F32ToI64(a F32) I64 { return opsI64{a, truncSatf32sI64} }
+
+// F32ToUI64 converts a to an unsigned integer, truncating towards
+// zero. Values out of range saturate, and NaN converts to 0.
+func F32ToUI64(a F32) I64 { return opsI64{a, truncSatf32uI64} }
+
+// F64ToI64 converts a to a signed integer, truncating towards
+// zero. Values out of range saturate, and NaN converts to 0.
+func F64ToI64(a F64) I64 { return opsI64{a, truncSatf64sI64} }
+
+// F64ToUI64 converts a to an unsigned integer, truncating towards
+// zero. Values out of range saturate, and NaN converts to 0.
+func F64ToUI64(a F64) I64 { return opsI64{a, truncSatf64uI64} }
+
+// F64Bits returns the IEEE 754 binary representation of a.
+func F64Bits(a F64) I64 { return opsI64{a, reinterpretf64I64} }
+
+// F64FromBits returns the F64 with the IEEE 754 binary
+// representation a.
+func F64FromBits(a I64) F64 { return opsF64{a, reinterpreti64F64} }
@@ -8,6 +8,27 @@ import (
 type instCtx struct {
 	io.Writer
 	fn Function
+	m  *Module
+
+	// depth is the number of blocks enclosing
+	// the instruction.
+	depth int
+	// loops are the enclosing loops, innermost last.
+	loops []loopTarget
+}
+
+// loopTarget records the depths of the blocks targeted
+// by Break and Continue for a loop.
+type loopTarget struct {
+	label           string
+	brk, continueAt int
+}
+
+// enter returns a context for instructions
+// nested inside n more blocks.
+func (c instCtx) enter(n int) instCtx {
+	c.depth += n
+	return c
 }
 
 type Instruction interface {
@@ -40,6 +61,14 @@ func (o vecOp) write(out instCtx) error {
 	return nil
 }
 
+type miscOp uint32
+
+func (o miscOp) write(out instCtx) error {
+	out.Write([]byte{0xFC})
+	writeu32(uint32(o), out)
+	return nil
+}
+
 // AssignF32 assigns the value of v to dst.
 func AssignF32(dst MutableF32, v F32) Instruction {
 	return assignF32{dst: dst, v: v}
@@ -0,0 +1,106 @@
+package interp
+
+import (
+	"strings"
+
+	wasm "github.com/chriscraws/gowasm"
+)
+
+type funcType struct {
+	params  []ValueType
+	results []ValueType
+}
+
+func (ft funcType) equals(other funcType) bool {
+	if len(ft.params) != len(other.params) || len(ft.results) != len(other.results) {
+		return false
+	}
+	for i, p := range ft.params {
+		if p != other.params[i] {
+			return false
+		}
+	}
+	for i, r := range ft.results {
+		if r != other.results[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (ft funcType) String() string {
+	list := func(ts []ValueType) string {
+		s := make([]string, len(ts))
+		for i, t := range ts {
+			s[i] = t.String()
+		}
+		return "(" + strings.Join(s, ", ") + ")"
+	}
+	return "func " + list(ft.params) + " -> " + list(ft.results)
+}
+
+// valueType returns the ValueType representing t.
+func valueType(t wasm.Type) ValueType {
+	switch t {
+	case wasm.TypeI32:
+		return I32
+	case wasm.TypeI64:
+		return I64
+	case wasm.TypeF32:
+		return F32
+	case wasm.TypeF64:
+		return F64
+	default:
+		return V128
+	}
+}
+
+func valueTypes(ts []wasm.Type) []ValueType {
+	out := make([]ValueType, len(ts))
+	for i, t := range ts {
+		out[i] = valueType(t)
+	}
+	return out
+}
+
+type code struct {
+	// locals holds the types of the params, followed by
+	// the declared locals.
+	locals []ValueType
+	body   []instr
+}
+
+// module is a decoded binary module, with its function
+// bodies prepared for execution.
+type module struct {
+	*wasm.Binary
+	types []funcType
+	code  []code
+}
+
+// decode decodes and validates the binary module b.
+func decode(b []byte) (*module, error) {
+	bin, err := wasm.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := bin.Validate(); err != nil {
+		return nil, err
+	}
+	mod := &module{Binary: bin}
+	for _, sig := range bin.Types {
+		mod.types = append(mod.types, funcType{
+			params:  valueTypes(sig.Params),
+			results: valueTypes(sig.Results),
+		})
+	}
+	for i, c := range bin.Code {
+		ft := mod.types[bin.Functions[i]]
+		locals := append([]ValueType(nil), ft.params...)
+		mod.code = append(mod.code, code{
+			locals: append(locals, valueTypes(c.Locals)...),
+			body:   prepare(c.Body),
+		})
+	}
+	return mod, nil
+}
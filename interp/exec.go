@@ -0,0 +1,695 @@
+package interp
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// stack is the operand stack of a function call.
+type stack []value
+
+func (s *stack) push(v value) { *s = append(*s, v) }
+
+func (s *stack) pop() value {
+	v := (*s)[len(*s)-1]
+	*s = (*s)[:len(*s)-1]
+	return v
+}
+
+func (s *stack) pushU32(x uint32)  { s.push(value{lo: uint64(x)}) }
+func (s *stack) pushU64(x uint64)  { s.push(value{lo: x}) }
+func (s *stack) pushF32(x float32) { s.pushU32(math.Float32bits(x)) }
+func (s *stack) pushF64(x float64) { s.pushU64(math.Float64bits(x)) }
+
+func (s *stack) pushBool(b bool) {
+	if b {
+		s.pushU32(1)
+	} else {
+		s.pushU32(0)
+	}
+}
+
+func (s *stack) popU32() uint32  { return uint32(s.pop().lo) }
+func (s *stack) popU64() uint64  { return s.pop().lo }
+func (s *stack) popF32() float32 { return math.Float32frombits(s.popU32()) }
+func (s *stack) popF64() float64 { return math.Float64frombits(s.popU64()) }
+
+// label is the target of a branch.
+type label struct {
+	// height is the stack height below the values of the
+	// block, and arity the number of values a branch passes.
+	height int
+	arity  int
+
+	// cont is the pc preceding the next instruction to
+	// execute after a branch.
+	cont int
+	loop bool
+}
+
+// invoke executes the function f, defined by inst.
+func (inst *Instance) invoke(f *Function, args []value) []value {
+	if inst.depth >= maxCallDepth {
+		trap("call stack exhausted")
+	}
+	inst.depth++
+	defer func() { inst.depth-- }()
+
+	body := f.code.body
+	locals := make([]value, len(f.code.locals))
+	copy(locals, args)
+	var s stack
+	labels := []label{{arity: len(f.typ.results)}}
+
+	// results returns the values returned by the function.
+	results := func() []value {
+		n := len(f.typ.results)
+		return append([]value(nil), s[len(s)-n:]...)
+	}
+	// branch branches to the label at depth, and returns whether
+	// the branch returns from the function.
+	var pc int
+	branch := func(depth uint32) bool {
+		i := len(labels) - 1 - int(depth)
+		if i == 0 {
+			return true
+		}
+		l := labels[i]
+		copy(s[l.height:], s[len(s)-l.arity:])
+		s = s[:l.height+l.arity]
+		if l.loop {
+			labels = labels[:i+1]
+		} else {
+			labels = labels[:i]
+		}
+		pc = l.cont
+		return false
+	}
+
+	for ; ; pc++ {
+		in := &body[pc]
+		switch in.op {
+		case opUnreachable:
+			trap("unreachable")
+		case 0x01: // nop
+		case opBlock:
+			labels = append(labels, label{
+				height: len(s) - in.params(),
+				arity:  in.results(),
+				cont:   int(in.a),
+			})
+		case opLoop:
+			labels = append(labels, label{
+				height: len(s) - in.params(),
+				arity:  in.params(),
+				cont:   pc,
+				loop:   true,
+			})
+		case opIf:
+			cond := s.popU32()
+			labels = append(labels, label{
+				height: len(s) - in.params(),
+				arity:  in.results(),
+				cont:   int(in.a),
+			})
+			if cond == 0 {
+				if in.b != 0 {
+					pc = int(in.b)
+				} else {
+					// the end pops the label
+					pc = int(in.a) - 1
+				}
+			}
+		case opElse:
+			// the then branch is complete
+			pc = labels[len(labels)-1].cont - 1
+		case opEnd:
+			if len(labels) == 1 {
+				return results()
+			}
+			labels = labels[:len(labels)-1]
+		case opBr:
+			if branch(in.a) {
+				return results()
+			}
+		case opBrIf:
+			if s.popU32() != 0 && branch(in.a) {
+				return results()
+			}
+		case opBrTable:
+			i := s.popU32()
+			if int(i) >= len(in.table)-1 {
+				i = uint32(len(in.table) - 1)
+			}
+			if branch(in.table[i]) {
+				return results()
+			}
+		case 0x0F: // return
+			return results()
+		case opCall:
+			callee := inst.funcs[in.a]
+			n := len(callee.typ.params)
+			args := append([]value(nil), s[len(s)-n:]...)
+			s = s[:len(s)-n]
+			s = append(s, callee.call(args)...)
+		case 0x1A: // drop
+			s.pop()
+		case 0x1B: // select
+			cond := s.popU32()
+			b := s.pop()
+			a := s.pop()
+			if cond != 0 {
+				s.push(a)
+			} else {
+				s.push(b)
+			}
+		case 0x20: // local.get
+			s.push(locals[in.a])
+		case 0x21: // local.set
+			locals[in.a] = s.pop()
+		case 0x22: // local.tee
+			locals[in.a] = s[len(s)-1]
+		case opGlobalGet:
+			s.push(inst.globals[in.a].v)
+		case 0x24: // global.set
+			inst.globals[in.a].v = s.pop()
+		case 0x3F: // memory.size
+			s.pushU32(inst.memory.Pages())
+		case 0x40: // memory.grow
+			s.pushU32(uint32(inst.memory.grow(s.popU32())))
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			s.pushU64(in.k)
+		default:
+			switch {
+			case in.op >= 0x28 && in.op <= 0x3E:
+				inst.execMemory(in, &s)
+			case in.op&0xFF00 == simd:
+				inst.execSIMD(in, &s)
+			default:
+				execNumeric(in.op, &s)
+			}
+		}
+	}
+}
+
+// mem returns the n bytes of memory at the effective
+// address of addr and offset, or traps if they are out of
+// bounds.
+func (inst *Instance) mem(addr, offset uint32, n int) []byte {
+	ea := uint64(addr) + uint64(offset)
+	if ea+uint64(n) > uint64(len(inst.memory.data)) {
+		trap("out of bounds memory access")
+	}
+	return inst.memory.data[ea : ea+uint64(n)]
+}
+
+func (inst *Instance) execMemory(in *instr, s *stack) {
+	le := binary.LittleEndian
+	if in.op >= 0x36 {
+		v := s.popU64()
+		addr := s.popU32()
+		switch in.op {
+		case 0x36, 0x38: // i32.store, f32.store
+			le.PutUint32(inst.mem(addr, in.a, 4), uint32(v))
+		case 0x37, 0x39: // i64.store, f64.store
+			le.PutUint64(inst.mem(addr, in.a, 8), v)
+		case 0x3A, 0x3C: // i32.store8, i64.store8
+			inst.mem(addr, in.a, 1)[0] = byte(v)
+		case 0x3B, 0x3D: // i32.store16, i64.store16
+			le.PutUint16(inst.mem(addr, in.a, 2), uint16(v))
+		case 0x3E: // i64.store32
+			le.PutUint32(inst.mem(addr, in.a, 4), uint32(v))
+		}
+		return
+	}
+	addr := s.popU32()
+	switch in.op {
+	case 0x28, 0x2A: // i32.load, f32.load
+		s.pushU32(le.Uint32(inst.mem(addr, in.a, 4)))
+	case 0x29, 0x2B: // i64.load, f64.load
+		s.pushU64(le.Uint64(inst.mem(addr, in.a, 8)))
+	case 0x2C: // i32.load8_s
+		s.pushU32(uint32(int8(inst.mem(addr, in.a, 1)[0])))
+	case 0x2D: // i32.load8_u
+		s.pushU32(uint32(inst.mem(addr, in.a, 1)[0]))
+	case 0x2E: // i32.load16_s
+		s.pushU32(uint32(int16(le.Uint16(inst.mem(addr, in.a, 2)))))
+	case 0x2F: // i32.load16_u
+		s.pushU32(uint32(le.Uint16(inst.mem(addr, in.a, 2))))
+	case 0x30: // i64.load8_s
+		s.pushU64(uint64(int8(inst.mem(addr, in.a, 1)[0])))
+	case 0x31: // i64.load8_u
+		s.pushU64(uint64(inst.mem(addr, in.a, 1)[0]))
+	case 0x32: // i64.load16_s
+		s.pushU64(uint64(int16(le.Uint16(inst.mem(addr, in.a, 2)))))
+	case 0x33: // i64.load16_u
+		s.pushU64(uint64(le.Uint16(inst.mem(addr, in.a, 2))))
+	case 0x34: // i64.load32_s
+		s.pushU64(uint64(int32(le.Uint32(inst.mem(addr, in.a, 4)))))
+	case 0x35: // i64.load32_u
+		s.pushU64(uint64(le.Uint32(inst.mem(addr, in.a, 4))))
+	}
+}
+
+// truncation bounds, exclusive
+const (
+	twoTo31 = 1 << 31
+	twoTo32 = 1 << 32
+	twoTo63 = 1 << 63
+	twoTo64 = 1 << 64
+)
+
+// truncChecked truncates x, trapping if the result is not in
+// the range [lo, hi).
+func truncChecked(x, lo, hi float64) float64 {
+	if math.IsNaN(x) {
+		trap("invalid conversion to integer")
+	}
+	t := math.Trunc(x)
+	if t < lo || t >= hi {
+		trap("integer overflow")
+	}
+	return t
+}
+
+// truncSatS32 truncates x to a signed 32-bit integer,
+// saturating at the bounds, and returning 0 for NaN.
+func truncSatS32(x float64) uint32 {
+	switch {
+	case math.IsNaN(x):
+		return 0
+	case x <= -twoTo31:
+		return 1 << 31
+	case x >= twoTo31:
+		return math.MaxInt32
+	}
+	return uint32(int32(x))
+}
+
+// truncSatU32, truncSatS64 and truncSatU64 are the unsigned
+// and 64-bit variants of truncSatS32.
+func truncSatU32(x float64) uint32 {
+	switch {
+	case math.IsNaN(x), x <= 0:
+		return 0
+	case x >= twoTo32:
+		return math.MaxUint32
+	}
+	return uint32(x)
+}
+
+func truncSatS64(x float64) uint64 {
+	switch {
+	case math.IsNaN(x):
+		return 0
+	case x <= -twoTo63:
+		return 1 << 63
+	case x >= twoTo63:
+		return math.MaxInt64
+	}
+	return uint64(int64(x))
+}
+
+func truncSatU64(x float64) uint64 {
+	switch {
+	case math.IsNaN(x), x <= 0:
+		return 0
+	case x >= twoTo64:
+		return math.MaxUint64
+	}
+	return uint64(x)
+}
+
+// execNumeric executes the numeric instruction op.
+func execNumeric(op opcode, s *stack) {
+	switch op {
+	// i32 comparisons
+	case 0x45:
+		s.pushBool(s.popU32() == 0)
+	case 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F:
+		b := s.popU32()
+		a := s.popU32()
+		sa, sb := int32(a), int32(b)
+		s.pushBool([...]bool{
+			a == b, a != b,
+			sa < sb, a < b,
+			sa > sb, a > b,
+			sa <= sb, a <= b,
+			sa >= sb, a >= b,
+		}[op-0x46])
+
+	// i64 comparisons
+	case 0x50:
+		s.pushBool(s.popU64() == 0)
+	case 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A:
+		b := s.popU64()
+		a := s.popU64()
+		sa, sb := int64(a), int64(b)
+		s.pushBool([...]bool{
+			a == b, a != b,
+			sa < sb, a < b,
+			sa > sb, a > b,
+			sa <= sb, a <= b,
+			sa >= sb, a >= b,
+		}[op-0x51])
+
+	// f32 comparisons
+	case 0x5B, 0x5C, 0x5D, 0x5E, 0x5F, 0x60:
+		b := s.popF32()
+		a := s.popF32()
+		s.pushBool([...]bool{a == b, a != b, a < b, a > b, a <= b, a >= b}[op-0x5B])
+
+	// f64 comparisons
+	case 0x61, 0x62, 0x63, 0x64, 0x65, 0x66:
+		b := s.popF64()
+		a := s.popF64()
+		s.pushBool([...]bool{a == b, a != b, a < b, a > b, a <= b, a >= b}[op-0x61])
+
+	// i32 arithmetic
+	case 0x67:
+		s.pushU32(uint32(bits.LeadingZeros32(s.popU32())))
+	case 0x68:
+		s.pushU32(uint32(bits.TrailingZeros32(s.popU32())))
+	case 0x69:
+		s.pushU32(uint32(bits.OnesCount32(s.popU32())))
+	case 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F, 0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78:
+		b := s.popU32()
+		a := s.popU32()
+		s.pushU32(binaryI32(op, a, b))
+
+	// i64 arithmetic
+	case 0x79:
+		s.pushU64(uint64(bits.LeadingZeros64(s.popU64())))
+	case 0x7A:
+		s.pushU64(uint64(bits.TrailingZeros64(s.popU64())))
+	case 0x7B:
+		s.pushU64(uint64(bits.OnesCount64(s.popU64())))
+	case 0x7C, 0x7D, 0x7E, 0x7F, 0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89, 0x8A:
+		b := s.popU64()
+		a := s.popU64()
+		s.pushU64(binaryI64(op, a, b))
+
+	// f32 arithmetic, computed in float64 where the result
+	// rounds to the same float32
+	case 0x8B: // f32.abs
+		s.pushU32(s.popU32() &^ (1 << 31))
+	case 0x8C: // f32.neg
+		s.pushU32(s.popU32() ^ (1 << 31))
+	case 0x8D, 0x8E, 0x8F, 0x90, 0x91:
+		s.pushF32(float32(unaryFloat(op-0x8D, float64(s.popF32()))))
+	case 0x98: // f32.copysign
+		b := s.popU32()
+		a := s.popU32()
+		s.pushU32(a&^(1<<31) | b&(1<<31))
+	case 0x92, 0x93, 0x94, 0x95, 0x96, 0x97:
+		b := s.popF32()
+		a := s.popF32()
+		s.pushF32(binaryF32(op, a, b))
+
+	// f64 arithmetic
+	case 0x99: // f64.abs
+		s.pushU64(s.popU64() &^ (1 << 63))
+	case 0x9A: // f64.neg
+		s.pushU64(s.popU64() ^ (1 << 63))
+	case 0x9B, 0x9C, 0x9D, 0x9E, 0x9F:
+		s.pushF64(unaryFloat(op-0x9B, s.popF64()))
+	case 0xA6: // f64.copysign
+		b := s.popU64()
+		a := s.popU64()
+		s.pushU64(a&^(1<<63) | b&(1<<63))
+	case 0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5:
+		b := s.popF64()
+		a := s.popF64()
+		s.pushF64(binaryF64(op, a, b))
+
+	// conversions
+	case 0xA7: // i32.wrap_i64
+		s.pushU32(uint32(s.popU64()))
+	case 0xA8: // i32.trunc_f32_s
+		s.pushU32(uint32(int32(truncChecked(float64(s.popF32()), -twoTo31, twoTo31))))
+	case 0xA9: // i32.trunc_f32_u
+		s.pushU32(uint32(truncChecked(float64(s.popF32()), 0, twoTo32)))
+	case 0xAA: // i32.trunc_f64_s
+		s.pushU32(uint32(int32(truncChecked(s.popF64(), -twoTo31, twoTo31))))
+	case 0xAB: // i32.trunc_f64_u
+		s.pushU32(uint32(truncChecked(s.popF64(), 0, twoTo32)))
+	case 0xAC: // i64.extend_i32_s
+		s.pushU64(uint64(int32(s.popU32())))
+	case 0xAD: // i64.extend_i32_u
+		s.pushU64(uint64(s.popU32()))
+	case 0xAE: // i64.trunc_f32_s
+		s.pushU64(uint64(int64(truncChecked(float64(s.popF32()), -twoTo63, twoTo63))))
+	case 0xAF: // i64.trunc_f32_u
+		s.pushU64(uint64(truncChecked(float64(s.popF32()), 0, twoTo64)))
+	case 0xB0: // i64.trunc_f64_s
+		s.pushU64(uint64(int64(truncChecked(s.popF64(), -twoTo63, twoTo63))))
+	case 0xB1: // i64.trunc_f64_u
+		s.pushU64(uint64(truncChecked(s.popF64(), 0, twoTo64)))
+	case 0xB2: // f32.convert_i32_s
+		s.pushF32(float32(int32(s.popU32())))
+	case 0xB3: // f32.convert_i32_u
+		s.pushF32(float32(s.popU32()))
+	case 0xB4: // f32.convert_i64_s
+		s.pushF32(float32(int64(s.popU64())))
+	case 0xB5: // f32.convert_i64_u
+		s.pushF32(float32(s.popU64()))
+	case 0xB6: // f32.demote_f64
+		s.pushF32(float32(s.popF64()))
+	case 0xB7: // f64.convert_i32_s
+		s.pushF64(float64(int32(s.popU32())))
+	case 0xB8: // f64.convert_i32_u
+		s.pushF64(float64(s.popU32()))
+	case 0xB9: // f64.convert_i64_s
+		s.pushF64(float64(int64(s.popU64())))
+	case 0xBA: // f64.convert_i64_u
+		s.pushF64(float64(s.popU64()))
+	case 0xBB: // f64.promote_f32
+		s.pushF64(float64(s.popF32()))
+	case 0xBC, 0xBD, 0xBE, 0xBF:
+		// reinterpretations leave the bits unchanged
+	case 0xC0: // i32.extend8_s
+		s.pushU32(uint32(int8(s.popU32())))
+	case 0xC1: // i32.extend16_s
+		s.pushU32(uint32(int16(s.popU32())))
+	case 0xC2: // i64.extend8_s
+		s.pushU64(uint64(int8(s.popU64())))
+	case 0xC3: // i64.extend16_s
+		s.pushU64(uint64(int16(s.popU64())))
+	case 0xC4: // i64.extend32_s
+		s.pushU64(uint64(int32(s.popU64())))
+
+	// saturating truncation
+	case misc | 0:
+		s.pushU32(truncSatS32(float64(s.popF32())))
+	case misc | 1:
+		s.pushU32(truncSatU32(float64(s.popF32())))
+	case misc | 2:
+		s.pushU32(truncSatS32(s.popF64()))
+	case misc | 3:
+		s.pushU32(truncSatU32(s.popF64()))
+	case misc | 4:
+		s.pushU64(truncSatS64(float64(s.popF32())))
+	case misc | 5:
+		s.pushU64(truncSatU64(float64(s.popF32())))
+	case misc | 6:
+		s.pushU64(truncSatS64(s.popF64()))
+	case misc | 7:
+		s.pushU64(truncSatU64(s.popF64()))
+	default:
+		trap("unsupported instruction %v", op)
+	}
+}
+
+func binaryI32(op opcode, a, b uint32) uint32 {
+	switch op {
+	case 0x6A:
+		return a + b
+	case 0x6B:
+		return a - b
+	case 0x6C:
+		return a * b
+	case 0x6D:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(a) == math.MinInt32 && int32(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint32(int32(a) / int32(b))
+	case 0x6E:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a / b
+	case 0x6F:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(b) == -1 {
+			return 0
+		}
+		return uint32(int32(a) % int32(b))
+	case 0x70:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a % b
+	case 0x71:
+		return a & b
+	case 0x72:
+		return a | b
+	case 0x73:
+		return a ^ b
+	case 0x74:
+		return a << (b % 32)
+	case 0x75:
+		return uint32(int32(a) >> (b % 32))
+	case 0x76:
+		return a >> (b % 32)
+	case 0x77:
+		return bits.RotateLeft32(a, int(b%32))
+	default: // 0x78
+		return bits.RotateLeft32(a, -int(b%32))
+	}
+}
+
+func binaryI64(op opcode, a, b uint64) uint64 {
+	switch op {
+	case 0x7C:
+		return a + b
+	case 0x7D:
+		return a - b
+	case 0x7E:
+		return a * b
+	case 0x7F:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(int64(a) / int64(b))
+	case 0x80:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a / b
+	case 0x81:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(b) == -1 {
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	case 0x82:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a % b
+	case 0x83:
+		return a & b
+	case 0x84:
+		return a | b
+	case 0x85:
+		return a ^ b
+	case 0x86:
+		return a << (b % 64)
+	case 0x87:
+		return uint64(int64(a) >> (b % 64))
+	case 0x88:
+		return a >> (b % 64)
+	case 0x89:
+		return bits.RotateLeft64(a, int(b%64))
+	default: // 0x8A
+		return bits.RotateLeft64(a, -int(b%64))
+	}
+}
+
+// unaryFloat applies ceil, floor, trunc, nearest or sqrt to x,
+// selected by i in that order. Each is exact, or correctly
+// rounded when converted to float32 for float32 arguments.
+func unaryFloat(i opcode, x float64) float64 {
+	switch i {
+	case 0:
+		return math.Ceil(x)
+	case 1:
+		return math.Floor(x)
+	case 2:
+		return math.Trunc(x)
+	case 3:
+		return math.RoundToEven(x)
+	default:
+		return math.Sqrt(x)
+	}
+}
+
+func binaryF32(op opcode, a, b float32) float32 {
+	switch op {
+	case 0x92:
+		return a + b
+	case 0x93:
+		return a - b
+	case 0x94:
+		return a * b
+	case 0x95:
+		return a / b
+	case 0x96:
+		return float32(fmin(float64(a), float64(b)))
+	default: // 0x97
+		return float32(fmax(float64(a), float64(b)))
+	}
+}
+
+func binaryF64(op opcode, a, b float64) float64 {
+	switch op {
+	case 0xA0:
+		return a + b
+	case 0xA1:
+		return a - b
+	case 0xA2:
+		return a * b
+	case 0xA3:
+		return a / b
+	case 0xA4:
+		return fmin(a, b)
+	default: // 0xA5
+		return fmax(a, b)
+	}
+}
+
+// fmin returns the minimum of a and b as defined by
+// WebAssembly: NaN if either is NaN, and -0 for -0 and +0.
+// Unlike math.Min, NaN takes precedence over infinities.
+func fmin(a, b float64) float64 {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return math.NaN()
+	case a == 0 && b == 0:
+		if math.Signbit(a) {
+			return a
+		}
+		return b
+	case a < b:
+		return a
+	}
+	return b
+}
+
+// fmax returns the maximum of a and b as defined by
+// WebAssembly: NaN if either is NaN, and +0 for -0 and +0.
+func fmax(a, b float64) float64 {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return math.NaN()
+	case a == 0 && b == 0:
+		if math.Signbit(a) {
+			return b
+		}
+		return a
+	case a > b:
+		return a
+	}
+	return b
+}
@@ -0,0 +1,115 @@
+package interp
+
+import wasm "github.com/chriscraws/gowasm"
+
+// opcode is a single byte opcode, or a prefix byte followed by
+// the sub-opcode for prefixed instructions, as in wasm.Opcode.
+type opcode uint16
+
+const (
+	opUnreachable opcode = 0x00
+	opBlock       opcode = 0x02
+	opLoop        opcode = 0x03
+	opIf          opcode = 0x04
+	opElse        opcode = 0x05
+	opEnd         opcode = 0x0B
+	opBr          opcode = 0x0C
+	opBrIf        opcode = 0x0D
+	opBrTable     opcode = 0x0E
+	opCall        opcode = 0x10
+	opSelect      opcode = 0x1B
+	opSelectT     opcode = 0x1C
+	opGlobalGet   opcode = 0x23
+	opI32Const    opcode = 0x41
+	opI64Const    opcode = 0x42
+	opF32Const    opcode = 0x43
+	opF64Const    opcode = 0x44
+
+	// prefixes
+	misc opcode = 0xFC00
+	simd opcode = 0xFD00
+
+	opV128Const = simd | 12
+	opShuffle   = simd | 13
+)
+
+func (op opcode) String() string {
+	return wasm.Opcode(op).String()
+}
+
+// instr is an instruction prepared for execution.
+type instr struct {
+	op opcode
+
+	// a is the index, label depth or memory offset immediate,
+	// and b is the lane immediate. For block, loop and if, a is
+	// the pc of the matching end, and b is the pc of the else,
+	// if any.
+	a, b uint32
+
+	// k holds the bits of a scalar constant. For block, loop
+	// and if, k holds the number of params and results.
+	k uint64
+
+	// v holds the v128.const or i8x16.shuffle immediate.
+	v *value
+
+	// table holds the br_table labels, with the default last.
+	table []uint32
+}
+
+func blockArity(params, results int) uint64 {
+	return uint64(params)<<32 | uint64(results)
+}
+
+func (in instr) params() int  { return int(in.k >> 32) }
+func (in instr) results() int { return int(uint32(in.k)) }
+
+// prepare converts a decoded function body or constant
+// expression into instructions for execution, ending with
+// the final end.
+func prepare(body []wasm.Instr) []instr {
+	out := make([]instr, 0, len(body)+1)
+	// pcs of the enclosing block, loop and if instructions
+	var blocks []int
+	for _, d := range body {
+		pc := len(out)
+		in := instr{op: opcode(d.Op)}
+		switch in.op {
+		case opBlock, opLoop, opIf:
+			in.k = blockArity(len(d.Block.Params), len(d.Block.Results))
+			blocks = append(blocks, pc)
+		case opElse:
+			out[blocks[len(blocks)-1]].b = uint32(pc)
+		case opEnd:
+			out[blocks[len(blocks)-1]].a = uint32(pc)
+			blocks = blocks[:len(blocks)-1]
+		case opBrTable:
+			in.table = d.Labels
+		case opSelectT:
+			in.op = opSelect
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			in.k = d.Bits
+		case opV128Const, opShuffle:
+			in.v = new(value)
+			*in.v = v128(d.V128)
+		default:
+			in.a = d.Index
+			if hasMemarg(in.op) {
+				in.a = d.Offset
+			}
+			in.b = uint32(d.Lane)
+		}
+		out = append(out, in)
+	}
+	return append(out, instr{op: opEnd})
+}
+
+// hasMemarg returns whether op is a load or store.
+func hasMemarg(op opcode) bool {
+	if op&0xFF00 == simd {
+		sub := op &^ simd
+		return sub <= 11 || sub >= 84 && sub <= 93
+	}
+	return op >= 0x28 && op <= 0x3E
+}
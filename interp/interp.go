@@ -0,0 +1,477 @@
+// Package interp executes binary WebAssembly modules, such as
+// those produced by wasm.Module.Compile, without cgo. It
+// supports the instructions package wasm emits, including the
+// SIMD subset, and is intended for testing generated code where
+// a native runtime is unavailable.
+//
+// Values are passed to and from the interpreter as int32, int64,
+// float32, float64 and [16]byte, for the I32, I64, F32, F64 and
+// V128 types respectively.
+package interp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	wasm "github.com/chriscraws/gowasm"
+)
+
+// ValueType is the type of a WebAssembly value.
+type ValueType byte
+
+const (
+	I32  ValueType = 0x7F
+	I64  ValueType = 0x7E
+	F32  ValueType = 0x7D
+	F64  ValueType = 0x7C
+	V128 ValueType = 0x7B
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	case V128:
+		return "v128"
+	default:
+		return fmt.Sprintf("ValueType(%#x)", byte(t))
+	}
+}
+
+// Trap is the error returned when execution traps.
+type Trap struct {
+	Reason string
+}
+
+func (t *Trap) Error() string {
+	return "trap: " + t.Reason
+}
+
+func trap(format string, args ...interface{}) {
+	panic(&Trap{Reason: fmt.Sprintf(format, args...)})
+}
+
+// value holds any WebAssembly value. Scalars are stored in
+// lo, and v128 values are stored little-endian in lo and hi.
+type value struct {
+	lo, hi uint64
+}
+
+// toValue converts the Go value v to a value of type t. V128
+// values may also be given as [4]float32 or [4]int32.
+func toValue(t ValueType, v interface{}) (value, error) {
+	switch t {
+	case I32:
+		if x, ok := v.(int32); ok {
+			return value{lo: uint64(uint32(x))}, nil
+		}
+	case I64:
+		if x, ok := v.(int64); ok {
+			return value{lo: uint64(x)}, nil
+		}
+	case F32:
+		if x, ok := v.(float32); ok {
+			return value{lo: uint64(math.Float32bits(x))}, nil
+		}
+	case F64:
+		if x, ok := v.(float64); ok {
+			return value{lo: math.Float64bits(x)}, nil
+		}
+	case V128:
+		var b [16]byte
+		switch x := v.(type) {
+		case [16]byte:
+			b = x
+		case [4]float32:
+			for i, f := range x {
+				binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(f))
+			}
+		case [4]int32:
+			for i, n := range x {
+				binary.LittleEndian.PutUint32(b[i*4:], uint32(n))
+			}
+		default:
+			return value{}, fmt.Errorf("%T is not a valid %s value", v, t)
+		}
+		return v128(b), nil
+	}
+	return value{}, fmt.Errorf("%T is not a valid %s value", v, t)
+}
+
+// fromValue converts v of type t to a Go value.
+func fromValue(t ValueType, v value) interface{} {
+	switch t {
+	case I32:
+		return int32(v.lo)
+	case I64:
+		return int64(v.lo)
+	case F32:
+		return math.Float32frombits(uint32(v.lo))
+	case F64:
+		return math.Float64frombits(v.lo)
+	case V128:
+		return v.bytes()
+	default:
+		panic(fmt.Errorf("%v is not a valid value type", t))
+	}
+}
+
+// Global is a global variable, either defined by an Instance
+// or created with NewGlobal to be imported.
+type Global struct {
+	typ     ValueType
+	mutable bool
+	v       value
+}
+
+// NewGlobal returns a Global of type t with the initial value v.
+func NewGlobal(t ValueType, mutable bool, v interface{}) (*Global, error) {
+	val, err := toValue(t, v)
+	if err != nil {
+		return nil, err
+	}
+	return &Global{typ: t, mutable: mutable, v: val}, nil
+}
+
+// Type returns the type of the value held by g.
+func (g *Global) Type() ValueType { return g.typ }
+
+// Mutable returns whether g may be assigned.
+func (g *Global) Mutable() bool { return g.mutable }
+
+// Get returns the value of g.
+func (g *Global) Get() interface{} {
+	return fromValue(g.typ, g.v)
+}
+
+// Set assigns v to g. Set returns an error if g is immutable,
+// or if v does not match the type of g.
+func (g *Global) Set(v interface{}) error {
+	if !g.mutable {
+		return fmt.Errorf("global is immutable")
+	}
+	val, err := toValue(g.typ, v)
+	if err != nil {
+		return err
+	}
+	g.v = val
+	return nil
+}
+
+// maxPages is the largest number of pages a memory may hold.
+const maxPages = 65536
+
+// pageSize is the size in bytes of a page of linear memory.
+const pageSize = 65536
+
+// Memory is a linear memory, either defined by an Instance or
+// created with NewMemory to be imported.
+type Memory struct {
+	data   []byte
+	max    uint32
+	hasMax bool
+}
+
+// NewMemory returns a Memory of minPages pages, which may grow
+// to at most maxPages pages. If maxPages is zero, the memory has
+// no maximum.
+func NewMemory(minPages, maxPages uint32) *Memory {
+	if maxPages != 0 && maxPages < minPages {
+		panic(fmt.Errorf("memory maximum %d is less than minimum %d", maxPages, minPages))
+	}
+	return &Memory{
+		data:   make([]byte, int(minPages)*pageSize),
+		max:    maxPages,
+		hasMax: maxPages != 0,
+	}
+}
+
+// Data returns the contents of the memory. The slice is only
+// valid until the memory grows.
+func (mem *Memory) Data() []byte { return mem.data }
+
+// Pages returns the current size of the memory in pages.
+func (mem *Memory) Pages() uint32 { return uint32(len(mem.data) / pageSize) }
+
+// grow grows the memory by n pages, and returns the previous
+// size in pages, or -1 if the memory cannot grow.
+func (mem *Memory) grow(n uint32) int32 {
+	old := mem.Pages()
+	limit := uint64(maxPages)
+	if mem.hasMax {
+		limit = uint64(mem.max)
+	}
+	if uint64(old)+uint64(n) > limit {
+		return -1
+	}
+	mem.data = append(mem.data, make([]byte, int(n)*pageSize)...)
+	return int32(old)
+}
+
+// HostFunc implements a Function in Go. args holds a value for
+// each parameter, and the returned slice must hold a value for
+// each result. A non-nil error stops execution, and is returned
+// by the Call that reached the function.
+type HostFunc func(args []interface{}) ([]interface{}, error)
+
+// Function is a function, either defined by an Instance or
+// created with NewFunction to be imported.
+type Function struct {
+	typ funcType
+
+	host HostFunc
+
+	inst *Instance
+	code *code
+}
+
+// NewFunction returns a Function with the given parameter and
+// result types, implemented by fn.
+func NewFunction(params, results []ValueType, fn HostFunc) *Function {
+	return &Function{
+		typ:  funcType{params: params, results: results},
+		host: fn,
+	}
+}
+
+// Params returns the parameter types of f.
+func (f *Function) Params() []ValueType { return f.typ.params }
+
+// Results returns the result types of f.
+func (f *Function) Results() []ValueType { return f.typ.results }
+
+// hostError carries an error returned by a HostFunc out
+// of the interpreter.
+type hostError struct {
+	err error
+}
+
+// Call calls f with args, and returns its results. If execution
+// traps, the error is a *Trap.
+func (f *Function) Call(args ...interface{}) (results []interface{}, err error) {
+	if len(args) != len(f.typ.params) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(f.typ.params), len(args))
+	}
+	in := make([]value, len(args))
+	for i, a := range args {
+		v, err := toValue(f.typ.params[i], a)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %s", i, err)
+		}
+		in[i] = v
+	}
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *Trap:
+			err = r
+		case hostError:
+			err = r.err
+		default:
+			panic(r)
+		}
+	}()
+	out := f.call(in)
+	results = make([]interface{}, len(out))
+	for i, v := range out {
+		results[i] = fromValue(f.typ.results[i], v)
+	}
+	return results, nil
+}
+
+// call calls f, and panics with a *Trap or hostError if
+// execution does not complete.
+func (f *Function) call(args []value) []value {
+	if f.host == nil {
+		return f.inst.invoke(f, args)
+	}
+	in := make([]interface{}, len(args))
+	for i, a := range args {
+		in[i] = fromValue(f.typ.params[i], a)
+	}
+	res, err := f.host(in)
+	if err != nil {
+		panic(hostError{err})
+	}
+	if len(res) != len(f.typ.results) {
+		panic(hostError{fmt.Errorf("host function returned %d results, expected %d", len(res), len(f.typ.results))})
+	}
+	out := make([]value, len(res))
+	for i, r := range res {
+		v, err := toValue(f.typ.results[i], r)
+		if err != nil {
+			panic(hostError{fmt.Errorf("host function result %d: %s", i, err)})
+		}
+		out[i] = v
+	}
+	return out
+}
+
+// Imports holds the values imported by a module, by module
+// and field name. Each value is a *Global, *Function or *Memory.
+type Imports map[string]map[string]interface{}
+
+// Add adds v to imp as mod.name.
+func (imp Imports) Add(mod, name string, v interface{}) {
+	if imp[mod] == nil {
+		imp[mod] = make(map[string]interface{})
+	}
+	imp[mod][name] = v
+}
+
+// maxCallDepth is the depth of nested calls at which execution
+// traps.
+const maxCallDepth = 10000
+
+// Instance is an instantiated module.
+type Instance struct {
+	funcs   []*Function
+	globals []*Global
+	memory  *Memory
+	exports map[string]interface{}
+
+	depth int
+}
+
+// Instantiate decodes the binary module bin, and instantiates it
+// with imports. Data segments are copied into memory, and the
+// start function, if any, is called.
+func Instantiate(bin []byte, imports Imports) (*Instance, error) {
+	mod, err := decode(bin)
+	if err != nil {
+		return nil, err
+	}
+	inst := &Instance{exports: make(map[string]interface{})}
+	for _, imp := range mod.Imports {
+		v, ok := imports[imp.Module][imp.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing import %s.%s", imp.Module, imp.Name)
+		}
+		if err := inst.addImport(mod, imp, v); err != nil {
+			return nil, fmt.Errorf("import %s.%s: %s", imp.Module, imp.Name, err)
+		}
+	}
+	for i, ti := range mod.Functions {
+		inst.funcs = append(inst.funcs, &Function{
+			typ:  mod.types[ti],
+			inst: inst,
+			code: &mod.code[i],
+		})
+	}
+	for _, mem := range mod.Memories {
+		inst.memory = NewMemory(mem.Min, mem.Max)
+		inst.memory.hasMax = mem.HasMax
+	}
+	for _, g := range mod.Globals {
+		inst.globals = append(inst.globals, &Global{
+			typ:     valueType(g.Type),
+			mutable: g.Mutable,
+			v:       inst.constExpr(g.Init),
+		})
+	}
+	for _, e := range mod.Exports {
+		switch e.Kind {
+		case wasm.ExternFunction:
+			inst.exports[e.Name] = inst.funcs[e.Index]
+		case wasm.ExternMemory:
+			inst.exports[e.Name] = inst.memory
+		case wasm.ExternGlobal:
+			inst.exports[e.Name] = inst.globals[e.Index]
+		}
+	}
+	for _, d := range mod.Data {
+		if !d.Active {
+			continue
+		}
+		off := uint32(inst.constExpr(d.Offset).lo)
+		if end := uint64(off) + uint64(len(d.Init)); end > uint64(len(inst.memory.data)) {
+			return nil, fmt.Errorf("data segment at %d does not fit in memory", off)
+		}
+		copy(inst.memory.data[off:], d.Init)
+	}
+	if mod.Start >= 0 {
+		if _, err := inst.funcs[mod.Start].Call(); err != nil {
+			return nil, err
+		}
+	}
+	return inst, nil
+}
+
+func (inst *Instance) addImport(mod *module, imp wasm.BinaryImport, v interface{}) error {
+	switch imp.Kind {
+	case wasm.ExternFunction:
+		f, ok := v.(*Function)
+		if !ok {
+			return fmt.Errorf("expected *Function, got %T", v)
+		}
+		if !f.typ.equals(mod.types[imp.Type]) {
+			return fmt.Errorf("function type %s does not match %s", f.typ, mod.types[imp.Type])
+		}
+		inst.funcs = append(inst.funcs, f)
+	case wasm.ExternMemory:
+		mem, ok := v.(*Memory)
+		if !ok {
+			return fmt.Errorf("expected *Memory, got %T", v)
+		}
+		if mem.Pages() < imp.Memory.Min {
+			return fmt.Errorf("memory has %d pages, expected at least %d", mem.Pages(), imp.Memory.Min)
+		}
+		inst.memory = mem
+	case wasm.ExternGlobal:
+		g, ok := v.(*Global)
+		if !ok {
+			return fmt.Errorf("expected *Global, got %T", v)
+		}
+		if g.typ != valueType(imp.Global) || g.mutable != imp.Mutable {
+			return fmt.Errorf("global type does not match")
+		}
+		inst.globals = append(inst.globals, g)
+	default:
+		return fmt.Errorf("unsupported import kind %v", imp.Kind)
+	}
+	return nil
+}
+
+// constExpr evaluates a constant expression, which has been
+// checked by wasm.Decode.
+func (inst *Instance) constExpr(expr []wasm.Instr) value {
+	switch in := expr[0]; opcode(in.Op) {
+	case opGlobalGet:
+		return inst.globals[in.Index].v
+	case opV128Const:
+		return v128(in.V128)
+	default:
+		return value{lo: in.Bits}
+	}
+}
+
+// Function returns the function exported as name.
+func (inst *Instance) Function(name string) (*Function, error) {
+	if f, ok := inst.exports[name].(*Function); ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("no function exported as %q", name)
+}
+
+// Global returns the global exported as name.
+func (inst *Instance) Global(name string) (*Global, error) {
+	if g, ok := inst.exports[name].(*Global); ok {
+		return g, nil
+	}
+	return nil, fmt.Errorf("no global exported as %q", name)
+}
+
+// Memory returns the memory exported as name.
+func (inst *Instance) Memory(name string) (*Memory, error) {
+	if mem, ok := inst.exports[name].(*Memory); ok {
+		return mem, nil
+	}
+	return nil, fmt.Errorf("no memory exported as %q", name)
+}
@@ -0,0 +1,607 @@
+package interp_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	wasm "github.com/chriscraws/gowasm"
+	"github.com/chriscraws/gowasm/interp"
+)
+
+func instantiate(t *testing.T, m *wasm.Module, imports interp.Imports) *interp.Instance {
+	t.Helper()
+	buf, err := m.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := interp.Instantiate(buf, imports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inst
+}
+
+func call(t *testing.T, inst *interp.Instance, name string, args ...interface{}) []interface{} {
+	t.Helper()
+	f, err := inst.Function(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := f.Call(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func global(t *testing.T, inst *interp.Instance, name string) interface{} {
+	t.Helper()
+	g, err := inst.Global(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g.Get()
+}
+
+func f32s(b [16]byte) [4]float32 {
+	var v [4]float32
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+func writeF32s(b []byte, v ...float32) {
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+}
+
+func readF32s(b []byte, n int) []float32 {
+	v := make([]float32, n)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+func slicePtr(offset, length uint32) *interp.Global {
+	g, err := interp.NewGlobal(interp.I64, false, int64(length)<<32|int64(offset))
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+func TestGlobals(t *testing.T) {
+	m := new(wasm.Module)
+	o := m.GlobalF32(38.89)
+	m.Export("o", o)
+	m.Export("v", m.GlobalVec4F32([4]float32{12, -14, 2, 1000}))
+	f := m.Function()
+	f.Body(wasm.AssignF32(o, wasm.AddF32(o, wasm.ConstF32(1))))
+	m.Export("main", f)
+	inst := instantiate(t, m, nil)
+
+	if v := global(t, inst, "o"); v != float32(38.89) {
+		t.Errorf("expected 38.89, got %v", v)
+	}
+	g, _ := inst.Global("o")
+	if err := g.Set(float32(1)); err != nil {
+		t.Fatal(err)
+	}
+	call(t, inst, "main")
+	if v := global(t, inst, "o"); v != float32(2) {
+		t.Errorf("expected 2, got %v", v)
+	}
+	if err := g.Set(int32(1)); err == nil {
+		t.Errorf("expected error setting f32 global to int32")
+	}
+	if v := f32s(global(t, inst, "v").([16]byte)); v != [4]float32{12, -14, 2, 1000} {
+		t.Errorf("unexpected vec4f32 global %v", v)
+	}
+	if err := slicePtr(0, 0).Set(int64(1)); err == nil {
+		t.Errorf("expected error setting immutable global")
+	}
+}
+
+func TestTypedFunctions(t *testing.T) {
+	m := new(wasm.Module)
+	fib := m.TypedFunction(wasm.Signature{
+		Params:  []wasm.Type{wasm.TypeF32},
+		Results: []wasm.Type{wasm.TypeF32},
+	})
+	n := fib.ParamF32(0)
+	fib.Body(
+		wasm.IfF32{
+			Condition: wasm.MaxF32(
+				wasm.SubF32(wasm.ConstF32(2), n),
+				wasm.ConstF32(0),
+			),
+			Then: []wasm.Instruction{wasm.ReturnValue(n)},
+		},
+		wasm.AddF32(
+			wasm.CallF32(fib, wasm.SubF32(n, wasm.ConstF32(1))),
+			wasm.CallF32(fib, wasm.SubF32(n, wasm.ConstF32(2))),
+		),
+	)
+	m.Export("fib", fib)
+	scale := m.TypedFunction(wasm.Signature{
+		Params:  []wasm.Type{wasm.TypeVec4F32, wasm.TypeF32},
+		Results: []wasm.Type{wasm.TypeVec4F32},
+	})
+	scale.Body(wasm.ReturnValue(wasm.MulVec4F32(
+		scale.ParamVec4F32(0),
+		wasm.SplatVec4F32(scale.ParamF32(1)),
+	)))
+	m.Export("scale", scale)
+	inst := instantiate(t, m, nil)
+
+	if res := call(t, inst, "fib", float32(20)); res[0] != float32(6765) {
+		t.Errorf("expected 6765, got %v", res[0])
+	}
+	res := call(t, inst, "scale", [4]float32{1, 2, 3, 4}, float32(-2))
+	if v := f32s(res[0].([16]byte)); v != [4]float32{-2, -4, -6, -8} {
+		t.Errorf("unexpected scaled vector %v", v)
+	}
+
+	f, _ := inst.Function("fib")
+	if _, err := f.Call(); err == nil {
+		t.Errorf("expected error calling with too few arguments")
+	}
+	if _, err := f.Call(int32(1)); err == nil {
+		t.Errorf("expected error calling with an argument of the wrong type")
+	}
+}
+
+func TestImports(t *testing.T) {
+	m := new(wasm.Module)
+	x := m.ImportF32("root", "x")
+	log := m.ImportTypedFunction("env", "log", wasm.Signature{
+		Params: []wasm.Type{wasm.TypeF32},
+	})
+	noise := m.ImportTypedFunction("env", "noise", wasm.Signature{
+		Params:  []wasm.Type{wasm.TypeF32, wasm.TypeF32},
+		Results: []wasm.Type{wasm.TypeF32},
+	})
+	f := m.Function()
+	f.Body(
+		wasm.AssignF32(x, wasm.CallF32(noise, x, wasm.ConstF32(4))),
+		wasm.Call(log, x),
+	)
+	m.Export("main", f)
+
+	xg, err := interp.NewGlobal(interp.F32, true, float32(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged []float32
+	imports := make(interp.Imports)
+	imports.Add("root", "x", xg)
+	imports.Add("env", "log", interp.NewFunction(
+		[]interp.ValueType{interp.F32}, nil,
+		func(args []interface{}) ([]interface{}, error) {
+			logged = append(logged, args[0].(float32))
+			return nil, nil
+		},
+	))
+	imports.Add("env", "noise", interp.NewFunction(
+		[]interp.ValueType{interp.F32, interp.F32}, []interp.ValueType{interp.F32},
+		func(args []interface{}) ([]interface{}, error) {
+			return []interface{}{args[0].(float32) * args[1].(float32)}, nil
+		},
+	))
+	inst := instantiate(t, m, imports)
+	call(t, inst, "main")
+	if v := xg.Get(); v != float32(12) {
+		t.Errorf("expected 12, got %v", v)
+	}
+	if len(logged) != 1 || logged[0] != 12 {
+		t.Errorf("expected [12] to be logged, got %v", logged)
+	}
+
+	// errors returned by host functions are returned by Call
+	errNoise := errors.New("no noise")
+	imports.Add("env", "noise", interp.NewFunction(
+		[]interp.ValueType{interp.F32, interp.F32}, []interp.ValueType{interp.F32},
+		func(args []interface{}) ([]interface{}, error) {
+			return nil, errNoise
+		},
+	))
+	inst = instantiate(t, m, imports)
+	main, _ := inst.Function("main")
+	if _, err := main.Call(); err != errNoise {
+		t.Errorf("expected host error, got %v", err)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	m := new(wasm.Module)
+	x := m.ImportF32("root", "x")
+	f := m.Function()
+	f.Body(wasm.AssignF32(x, wasm.ConstF32(1)))
+	m.Export("main", f)
+	buf, err := m.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	immutable, _ := interp.NewGlobal(interp.F32, false, float32(0))
+	i32, _ := interp.NewGlobal(interp.I32, true, int32(0))
+	for _, v := range []interface{}{nil, immutable, i32, interp.NewMemory(1, 0)} {
+		imports := make(interp.Imports)
+		if v != nil {
+			imports.Add("root", "x", v)
+		}
+		if _, err := interp.Instantiate(buf, imports); err == nil {
+			t.Errorf("expected error importing %v", v)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := new(wasm.Module)
+	m.ImportMemory("host", "heap", 1, 2)
+	o := m.GlobalF32(0)
+	m.Export("o", o)
+	vec := m.ImportSliceF32("wowee")
+	sum := m.Function()
+	sum.Body(
+		wasm.AssignF32(o, vec.LengthF32()),
+		wasm.SliceF32RangeF32{
+			Slice: vec,
+			Begin: wasm.ConstF32(3),
+			End:   wasm.ConstF32(6),
+			Do: func(v wasm.F32) []wasm.Instruction {
+				return []wasm.Instruction{
+					wasm.AssignF32(o, wasm.AddF32(o, v)),
+				}
+			},
+		},
+	)
+	m.Export("sum", sum)
+	double := m.Function()
+	double.Body(
+		wasm.MutableSliceF32RangeVec4F32{
+			Slice: vec,
+			Begin: wasm.ConstF32(1),
+			Do: func(v wasm.MutableVec4F32) []wasm.Instruction {
+				return []wasm.Instruction{
+					wasm.AssignVec4F32(v, wasm.MulVec4F32(v, wasm.SplatVec4F32(wasm.ConstF32(2)))),
+				}
+			},
+			Tail: func(v wasm.MutableF32) []wasm.Instruction {
+				return []wasm.Instruction{
+					wasm.AssignF32(v, wasm.AddF32(v, wasm.ConstF32(100))),
+				}
+			},
+		},
+	)
+	m.Export("double", double)
+
+	mem := interp.NewMemory(1, 2)
+	writeF32s(mem.Data(), 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	imports := make(interp.Imports)
+	imports.Add("host", "heap", mem)
+	imports.Add("_sf32", "wowee", slicePtr(0, 10))
+	inst := instantiate(t, m, imports)
+
+	call(t, inst, "sum")
+	if v := global(t, inst, "o"); v != float32(10+3+4+5) {
+		t.Errorf("expected 22, got %v", v)
+	}
+	call(t, inst, "double")
+	exp := []float32{0, 2, 4, 6, 8, 10, 12, 14, 16, 109}
+	got := readF32s(mem.Data(), len(exp))
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("[%d] expected %f, got %f", i, exp[i], got[i])
+		}
+	}
+}
+
+func TestPixels(t *testing.T) {
+	// RGBA8 pixels are loaded as the bits of a Vec4F32 and
+	// processed as a Vec16I8.
+	m := new(wasm.Module)
+	m.ImportMemory("host", "heap", 1, 2)
+	pixels := m.ImportSliceF32("pixels")
+	brighten := func(v wasm.Vec4F32) wasm.Vec4F32 {
+		px := wasm.Vec16I8FromBits(wasm.Vec4F32Bits(v))
+		px = wasm.AddSatUVec16I8(px, wasm.SplatVec16I8(wasm.ConstI32(100)))
+		return wasm.Vec4F32FromBits(wasm.Vec16I8Bits(px))
+	}
+	f := m.Function()
+	f.Body(
+		wasm.MutableSliceF32RangeVec4F32{
+			Slice: pixels,
+			Do: func(v wasm.MutableVec4F32) []wasm.Instruction {
+				return []wasm.Instruction{
+					wasm.AssignVec4F32(v, brighten(v)),
+				}
+			},
+			// the last pixel is brightened on its own
+			Tail: func(v wasm.MutableF32) []wasm.Instruction {
+				return []wasm.Instruction{
+					wasm.AssignF32(v, wasm.ExtractLaneVec4F32(brighten(wasm.SplatVec4F32(v)), 0)),
+				}
+			},
+		},
+	)
+	m.Export("brighten", f)
+
+	mem := interp.NewMemory(1, 2)
+	for i := 0; i < 36; i++ {
+		mem.Data()[i] = byte(i * 7)
+	}
+	imports := make(interp.Imports)
+	imports.Add("host", "heap", mem)
+	imports.Add("_sf32", "pixels", slicePtr(0, 9))
+	inst := instantiate(t, m, imports)
+
+	call(t, inst, "brighten")
+	for i, b := range mem.Data()[:37] {
+		exp := byte(0)
+		if i < 36 {
+			exp = 255
+			if i*7+100 < 255 {
+				exp = byte(i*7 + 100)
+			}
+		}
+		if b != exp {
+			t.Errorf("[%d] expected %d, got %d", i, exp, b)
+		}
+	}
+}
+
+func TestDataSegments(t *testing.T) {
+	m := new(wasm.Module)
+	m.Export("memory", m.Memory(1, 0))
+	m.DataAt(4, []byte{1, 2, 3})
+	coef := m.DataF32([]float32{0.5, 1.5, 2.5, 3.5})
+	o := m.GlobalF32(0)
+	m.Export("o", o)
+	f := m.Function()
+	f.Body(wasm.AssignF32(o, wasm.SumVec4F32(coef.IndexVec4F32(wasm.ConstF32(0)))))
+	m.Export("main", f)
+	inst := instantiate(t, m, nil)
+
+	mem, err := inst.Memory("memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem.Pages() != 1 {
+		t.Errorf("expected 1 page, got %d", mem.Pages())
+	}
+	if b := mem.Data()[4:7]; b[0] != 1 || b[1] != 2 || b[2] != 3 {
+		t.Errorf("unexpected data %v", b)
+	}
+	call(t, inst, "main")
+	if v := global(t, inst, "o"); v != float32(8) {
+		t.Errorf("expected 8, got %v", v)
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	m := new(wasm.Module)
+	o := m.GlobalF32(0)
+	m.Export("o", o)
+	add := func(v float32) []wasm.Instruction {
+		return []wasm.Instruction{
+			wasm.AssignF32(o, wasm.AddF32(o, wasm.ConstF32(v))),
+		}
+	}
+	f := m.Function()
+	f.Body(wasm.ForRangeF32{
+		End: wasm.ConstF32(6),
+		Do: func(i wasm.F32) []wasm.Instruction {
+			return []wasm.Instruction{
+				wasm.Switch{
+					Index: wasm.F32ToI32(i),
+					Cases: [][]wasm.Instruction{
+						add(1),
+						add(10),
+						add(100),
+						{wasm.Continue("")},
+						{wasm.Break("")},
+					},
+					Default: add(1000),
+				},
+				wasm.AssignF32(o, wasm.AddF32(o, wasm.ConstF32(0.5))),
+			}
+		},
+	})
+	m.Export("switch", f)
+	count := m.GlobalI32(0)
+	m.Export("count", count)
+	loop := m.Function()
+	loop.Body(
+		wasm.Loop{
+			Do: []wasm.Instruction{
+				wasm.AssignI32(count, wasm.AddI32(count, wasm.ConstI32(1))),
+				wasm.If{
+					Condition: wasm.GeI32(count, wasm.ConstI32(5)),
+					Then:      []wasm.Instruction{wasm.Break("")},
+				},
+			},
+		},
+	)
+	m.Export("loop", loop)
+	inst := instantiate(t, m, nil)
+
+	call(t, inst, "switch")
+	if v := global(t, inst, "o"); v != float32(112.5) {
+		t.Errorf("expected 112.5, got %v", v)
+	}
+	call(t, inst, "loop")
+	if v := global(t, inst, "count"); v != int32(5) {
+		t.Errorf("expected 5, got %v", v)
+	}
+}
+
+func TestVec4Math(t *testing.T) {
+	m := new(wasm.Module)
+	v := m.GlobalVec4F32([4]float32{})
+	m.Export("v", v)
+	mat := m.GlobalMat4F32([16]float32{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		1, 2, 3, 1,
+	})
+	f := m.Function()
+	f.Body(wasm.AssignVec4F32(v, wasm.AddVec4F32(
+		wasm.MulMat4Vec4(mat, wasm.ConstVec4F32{1, 1, 1, 1}),
+		wasm.CosVec4F32(wasm.ConstVec4F32{0, 0, 0, 0}),
+	)))
+	m.Export("main", f)
+	inst := instantiate(t, m, nil)
+
+	call(t, inst, "main")
+	if got := f32s(global(t, inst, "v").([16]byte)); got != [4]float32{3, 4, 5, 2} {
+		t.Errorf("unexpected result %v", got)
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	m := new(wasm.Module)
+	export := func(name string, typ wasm.Type, op func(f wasm.Function) wasm.Instruction) {
+		f := m.TypedFunction(wasm.Signature{
+			Params:  []wasm.Type{typ, typ},
+			Results: []wasm.Type{typ},
+		})
+		f.Body(wasm.ReturnValue(op(f)))
+		m.Export(name, f)
+	}
+	export("minF32", wasm.TypeF32, func(f wasm.Function) wasm.Instruction {
+		return wasm.MinF32(f.ParamF32(0), f.ParamF32(1))
+	})
+	export("maxF32", wasm.TypeF32, func(f wasm.Function) wasm.Instruction {
+		return wasm.MaxF32(f.ParamF32(0), f.ParamF32(1))
+	})
+	export("minF64", wasm.TypeF64, func(f wasm.Function) wasm.Instruction {
+		return wasm.MinF64(f.ParamF64(0), f.ParamF64(1))
+	})
+	export("maxF64", wasm.TypeF64, func(f wasm.Function) wasm.Instruction {
+		return wasm.MaxF64(f.ParamF64(0), f.ParamF64(1))
+	})
+	export("minVec4F32", wasm.TypeVec4F32, func(f wasm.Function) wasm.Instruction {
+		return wasm.MinVec4F32(f.ParamVec4F32(0), f.ParamVec4F32(1))
+	})
+	export("maxVec4F32", wasm.TypeVec4F32, func(f wasm.Function) wasm.Instruction {
+		return wasm.MaxVec4F32(f.ParamVec4F32(0), f.ParamVec4F32(1))
+	})
+	inst := instantiate(t, m, nil)
+
+	nan, inf, negZero := math.NaN(), math.Inf(1), math.Copysign(0, -1)
+	for _, tc := range []struct {
+		a, b, min, max float64
+	}{
+		{1, 2, 1, 2},
+		{-inf, inf, -inf, inf},
+		{nan, 1, nan, nan},
+		{1, nan, nan, nan},
+		{nan, -inf, nan, nan},
+		{nan, inf, nan, nan},
+		{-inf, nan, nan, nan},
+		{inf, nan, nan, nan},
+		{negZero, 0, negZero, 0},
+		{0, negZero, negZero, 0},
+	} {
+		same := func(got, exp float64) bool {
+			if math.IsNaN(exp) {
+				return math.IsNaN(got)
+			}
+			return math.Float64bits(got) == math.Float64bits(exp)
+		}
+		a32, b32 := float32(tc.a), float32(tc.b)
+		for _, r := range []struct {
+			name     string
+			got, exp float64
+		}{
+			{"minF32", float64(call(t, inst, "minF32", a32, b32)[0].(float32)), tc.min},
+			{"maxF32", float64(call(t, inst, "maxF32", a32, b32)[0].(float32)), tc.max},
+			{"minF64", call(t, inst, "minF64", tc.a, tc.b)[0].(float64), tc.min},
+			{"maxF64", call(t, inst, "maxF64", tc.a, tc.b)[0].(float64), tc.max},
+			{"minVec4F32", float64(f32s(call(t, inst, "minVec4F32",
+				[4]float32{a32}, [4]float32{b32})[0].([16]byte))[0]), tc.min},
+			{"maxVec4F32", float64(f32s(call(t, inst, "maxVec4F32",
+				[4]float32{a32}, [4]float32{b32})[0].([16]byte))[0]), tc.max},
+		} {
+			if !same(r.got, r.exp) {
+				t.Errorf("%s(%v, %v): expected %v, got %v", r.name, tc.a, tc.b, r.exp, r.got)
+			}
+		}
+	}
+}
+
+func TestTraps(t *testing.T) {
+	for _, tc := range []struct {
+		what   string
+		body   func(m *wasm.Module, f wasm.Function) []wasm.Instruction
+		reason string
+	}{
+		{
+			what: "divide by zero",
+			body: func(m *wasm.Module, f wasm.Function) []wasm.Instruction {
+				o := m.GlobalI32(0)
+				return []wasm.Instruction{
+					wasm.AssignI32(o, wasm.DivSI32(wasm.ConstI32(1), o)),
+				}
+			},
+			reason: "integer divide by zero",
+		},
+		{
+			what: "out of bounds",
+			body: func(m *wasm.Module, f wasm.Function) []wasm.Instruction {
+				o := m.GlobalF32(0)
+				m.Memory(1, 1)
+				vec := m.DataF32At(65532, []float32{1})
+				return []wasm.Instruction{
+					wasm.AssignF32(o, vec.IndexF32(wasm.ConstF32(1))),
+				}
+			},
+			reason: "out of bounds memory access",
+		},
+		{
+			what: "infinite recursion",
+			body: func(m *wasm.Module, f wasm.Function) []wasm.Instruction {
+				return []wasm.Instruction{wasm.Call(f)}
+			},
+			reason: "call stack exhausted",
+		},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			m := new(wasm.Module)
+			f := m.Function()
+			f.Body(tc.body(m, f)...)
+			m.Export("main", f)
+			inst := instantiate(t, m, nil)
+			main, _ := inst.Function("main")
+			_, err := main.Call()
+			trap, ok := err.(*interp.Trap)
+			if !ok {
+				t.Fatalf("expected trap, got %v", err)
+			}
+			if trap.Reason != tc.reason {
+				t.Errorf("expected %q, got %q", tc.reason, trap.Reason)
+			}
+			// the instance remains usable after a trap
+			if _, err := main.Call(); err == nil {
+				t.Errorf("expected second call to trap")
+			}
+		})
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	// decoding is tested by package wasm, so only check that
+	// its errors are returned.
+	bin := []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00, 0x0D, 0x00}
+	_, err := interp.Instantiate(bin, nil)
+	if err == nil || !strings.Contains(err.Error(), "unsupported section") {
+		t.Errorf("expected unsupported section error, got %v", err)
+	}
+}
@@ -0,0 +1,755 @@
+package interp
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// laneCount returns the number of lanes accessed by the lane
+// instruction op.
+func laneCount(op opcode) int {
+	switch op &^ simd {
+	case 21, 22, 23, 84, 88:
+		return 16
+	case 24, 25, 26, 85, 89:
+		return 8
+	case 27, 28, 31, 32, 86, 90:
+		return 4
+	default:
+		return 2
+	}
+}
+
+func v128(b [16]byte) value {
+	return value{
+		lo: binary.LittleEndian.Uint64(b[:8]),
+		hi: binary.LittleEndian.Uint64(b[8:]),
+	}
+}
+
+func (v value) bytes() [16]byte {
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[:8], v.lo)
+	binary.LittleEndian.PutUint64(b[8:], v.hi)
+	return b
+}
+
+func (v value) u8() [16]uint8 { return v.bytes() }
+
+func (v value) u16() (l [8]uint16) {
+	for i := range l {
+		l[i] = uint16(v.half(i/4) >> (16 * (i % 4)))
+	}
+	return l
+}
+
+func (v value) u32() (l [4]uint32) {
+	for i := range l {
+		l[i] = uint32(v.half(i/2) >> (32 * (i % 2)))
+	}
+	return l
+}
+
+// half returns the lower or upper 64 bits of v.
+func (v value) half(i int) uint64 {
+	if i == 0 {
+		return v.lo
+	}
+	return v.hi
+}
+
+func fromU8(l [16]uint8) value { return v128(l) }
+
+func fromU16(l [8]uint16) value {
+	var v value
+	for i, x := range l {
+		if i < 4 {
+			v.lo |= uint64(x) << (16 * i)
+		} else {
+			v.hi |= uint64(x) << (16 * (i - 4))
+		}
+	}
+	return v
+}
+
+func fromU32(l [4]uint32) value {
+	return value{
+		lo: uint64(l[0]) | uint64(l[1])<<32,
+		hi: uint64(l[2]) | uint64(l[3])<<32,
+	}
+}
+
+func map8(a, b value, f func(x, y uint8) uint8) value {
+	x, y := a.u8(), b.u8()
+	var r [16]uint8
+	for i := range r {
+		r[i] = f(x[i], y[i])
+	}
+	return fromU8(r)
+}
+
+func map16(a, b value, f func(x, y uint16) uint16) value {
+	x, y := a.u16(), b.u16()
+	var r [8]uint16
+	for i := range r {
+		r[i] = f(x[i], y[i])
+	}
+	return fromU16(r)
+}
+
+func map32(a, b value, f func(x, y uint32) uint32) value {
+	x, y := a.u32(), b.u32()
+	var r [4]uint32
+	for i := range r {
+		r[i] = f(x[i], y[i])
+	}
+	return fromU32(r)
+}
+
+func mapF32(a, b value, f func(x, y float32) float32) value {
+	return map32(a, b, func(x, y uint32) uint32 {
+		return math.Float32bits(f(math.Float32frombits(x), math.Float32frombits(y)))
+	})
+}
+
+// mask returns a lane with all bits set if b is true.
+func mask(b bool) uint64 {
+	if b {
+		return math.MaxUint64
+	}
+	return 0
+}
+
+func cmp8(a, b value, f func(x, y uint8) bool) value {
+	return map8(a, b, func(x, y uint8) uint8 { return uint8(mask(f(x, y))) })
+}
+
+func cmp16(a, b value, f func(x, y uint16) bool) value {
+	return map16(a, b, func(x, y uint16) uint16 { return uint16(mask(f(x, y))) })
+}
+
+func cmp32(a, b value, f func(x, y uint32) bool) value {
+	return map32(a, b, func(x, y uint32) uint32 { return uint32(mask(f(x, y))) })
+}
+
+func cmpF32(a, b value, f func(x, y float32) bool) value {
+	return map32(a, b, func(x, y uint32) uint32 {
+		return uint32(mask(f(math.Float32frombits(x), math.Float32frombits(y))))
+	})
+}
+
+func cmpF64(a, b value, f func(x, y float64) bool) value {
+	return value{
+		lo: mask(f(math.Float64frombits(a.lo), math.Float64frombits(b.lo))),
+		hi: mask(f(math.Float64frombits(a.hi), math.Float64frombits(b.hi))),
+	}
+}
+
+// integer comparisons, in the order of their opcodes
+var (
+	intCompares8 = [...]func(x, y uint8) bool{
+		func(x, y uint8) bool { return x == y },
+		func(x, y uint8) bool { return x != y },
+		func(x, y uint8) bool { return int8(x) < int8(y) },
+		func(x, y uint8) bool { return x < y },
+		func(x, y uint8) bool { return int8(x) > int8(y) },
+		func(x, y uint8) bool { return x > y },
+		func(x, y uint8) bool { return int8(x) <= int8(y) },
+		func(x, y uint8) bool { return x <= y },
+		func(x, y uint8) bool { return int8(x) >= int8(y) },
+		func(x, y uint8) bool { return x >= y },
+	}
+	intCompares16 = [...]func(x, y uint16) bool{
+		func(x, y uint16) bool { return x == y },
+		func(x, y uint16) bool { return x != y },
+		func(x, y uint16) bool { return int16(x) < int16(y) },
+		func(x, y uint16) bool { return x < y },
+		func(x, y uint16) bool { return int16(x) > int16(y) },
+		func(x, y uint16) bool { return x > y },
+		func(x, y uint16) bool { return int16(x) <= int16(y) },
+		func(x, y uint16) bool { return x <= y },
+		func(x, y uint16) bool { return int16(x) >= int16(y) },
+		func(x, y uint16) bool { return x >= y },
+	}
+	intCompares32 = [...]func(x, y uint32) bool{
+		func(x, y uint32) bool { return x == y },
+		func(x, y uint32) bool { return x != y },
+		func(x, y uint32) bool { return int32(x) < int32(y) },
+		func(x, y uint32) bool { return x < y },
+		func(x, y uint32) bool { return int32(x) > int32(y) },
+		func(x, y uint32) bool { return x > y },
+		func(x, y uint32) bool { return int32(x) <= int32(y) },
+		func(x, y uint32) bool { return x <= y },
+		func(x, y uint32) bool { return int32(x) >= int32(y) },
+		func(x, y uint32) bool { return x >= y },
+	}
+)
+
+func satS8(x int32) uint8 {
+	if x < math.MinInt8 {
+		x = math.MinInt8
+	} else if x > math.MaxInt8 {
+		x = math.MaxInt8
+	}
+	return uint8(x)
+}
+
+func satU8(x int32) uint8 {
+	if x < 0 {
+		x = 0
+	} else if x > math.MaxUint8 {
+		x = math.MaxUint8
+	}
+	return uint8(x)
+}
+
+func satS16(x int32) uint16 {
+	if x < math.MinInt16 {
+		x = math.MinInt16
+	} else if x > math.MaxInt16 {
+		x = math.MaxInt16
+	}
+	return uint16(x)
+}
+
+func satU16(x int32) uint16 {
+	if x < 0 {
+		x = 0
+	} else if x > math.MaxUint16 {
+		x = math.MaxUint16
+	}
+	return uint16(x)
+}
+
+// bitmask returns the top bit of each of the n lanes of width
+// bits in l.
+func bitmask(l []uint64, width int) uint32 {
+	var m uint32
+	for i, x := range l {
+		if x>>(width-1)&1 != 0 {
+			m |= 1 << i
+		}
+	}
+	return m
+}
+
+func (inst *Instance) execSIMD(in *instr, s *stack) {
+	op := in.op &^ simd
+	switch {
+	case op <= 10 || op >= 92 && op <= 93:
+		inst.loadSIMD(op, in.a, s)
+		return
+	case op == 11:
+		v := s.pop()
+		b := v.bytes()
+		copy(inst.mem(s.popU32(), in.a, 16), b[:])
+		return
+	case op >= 84 && op <= 91:
+		v := s.pop()
+		b := v.bytes()
+		n := 16 / laneCount(in.op)
+		lane := b[int(in.b)*n : int(in.b+1)*n]
+		mem := inst.mem(s.popU32(), in.a, n)
+		if op <= 87 {
+			copy(lane, mem)
+			s.push(v128(b))
+		} else {
+			copy(mem, lane)
+		}
+		return
+	case op >= 35 && op <= 44:
+		b, a := s.pop(), s.pop()
+		s.push(cmp8(a, b, intCompares8[op-35]))
+		return
+	case op >= 45 && op <= 54:
+		b, a := s.pop(), s.pop()
+		s.push(cmp16(a, b, intCompares16[op-45]))
+		return
+	case op >= 55 && op <= 64:
+		b, a := s.pop(), s.pop()
+		s.push(cmp32(a, b, intCompares32[op-55]))
+		return
+	}
+
+	switch op {
+	case 12: // v128.const
+		s.push(*in.v)
+	case 13: // i8x16.shuffle
+		b, a := s.pop().u8(), s.pop().u8()
+		both := append(a[:], b[:]...)
+		var r [16]uint8
+		for i, l := range in.v.u8() {
+			r[i] = both[l]
+		}
+		s.push(fromU8(r))
+	case 14: // i8x16.swizzle
+		idx, a := s.pop().u8(), s.pop().u8()
+		var r [16]uint8
+		for i, l := range idx {
+			if l < 16 {
+				r[i] = a[l]
+			}
+		}
+		s.push(fromU8(r))
+
+	// splats
+	case 15:
+		x := uint8(s.popU32())
+		s.push(map8(value{}, value{}, func(_, _ uint8) uint8 { return x }))
+	case 16:
+		x := uint16(s.popU32())
+		s.push(map16(value{}, value{}, func(_, _ uint16) uint16 { return x }))
+	case 17, 19:
+		x := s.popU32()
+		s.push(fromU32([4]uint32{x, x, x, x}))
+	case 18, 20:
+		x := s.popU64()
+		s.push(value{lo: x, hi: x})
+
+	// lanes
+	case 21:
+		s.pushU32(uint32(int8(s.pop().u8()[in.b])))
+	case 22:
+		s.pushU32(uint32(s.pop().u8()[in.b]))
+	case 23:
+		x := uint8(s.popU32())
+		l := s.pop().u8()
+		l[in.b] = x
+		s.push(fromU8(l))
+	case 24:
+		s.pushU32(uint32(int16(s.pop().u16()[in.b])))
+	case 25:
+		s.pushU32(uint32(s.pop().u16()[in.b]))
+	case 26:
+		x := uint16(s.popU32())
+		l := s.pop().u16()
+		l[in.b] = x
+		s.push(fromU16(l))
+	case 27, 31:
+		s.pushU32(s.pop().u32()[in.b])
+	case 28, 32:
+		x := s.popU32()
+		l := s.pop().u32()
+		l[in.b] = x
+		s.push(fromU32(l))
+	case 29, 33:
+		s.pushU64(s.pop().half(int(in.b)))
+	case 30, 34:
+		x := s.popU64()
+		v := s.pop()
+		if in.b == 0 {
+			v.lo = x
+		} else {
+			v.hi = x
+		}
+		s.push(v)
+
+	// float comparisons
+	case 65, 66, 67, 68, 69, 70:
+		b, a := s.pop(), s.pop()
+		s.push(cmpF32(a, b, [...]func(x, y float32) bool{
+			func(x, y float32) bool { return x == y },
+			func(x, y float32) bool { return x != y },
+			func(x, y float32) bool { return x < y },
+			func(x, y float32) bool { return x > y },
+			func(x, y float32) bool { return x <= y },
+			func(x, y float32) bool { return x >= y },
+		}[op-65]))
+	case 71, 72, 73, 74, 75, 76:
+		b, a := s.pop(), s.pop()
+		s.push(cmpF64(a, b, [...]func(x, y float64) bool{
+			func(x, y float64) bool { return x == y },
+			func(x, y float64) bool { return x != y },
+			func(x, y float64) bool { return x < y },
+			func(x, y float64) bool { return x > y },
+			func(x, y float64) bool { return x <= y },
+			func(x, y float64) bool { return x >= y },
+		}[op-71]))
+
+	// bitwise
+	case 77:
+		a := s.pop()
+		s.push(value{lo: ^a.lo, hi: ^a.hi})
+	case 78:
+		b, a := s.pop(), s.pop()
+		s.push(value{lo: a.lo & b.lo, hi: a.hi & b.hi})
+	case 79:
+		b, a := s.pop(), s.pop()
+		s.push(value{lo: a.lo &^ b.lo, hi: a.hi &^ b.hi})
+	case 80:
+		b, a := s.pop(), s.pop()
+		s.push(value{lo: a.lo | b.lo, hi: a.hi | b.hi})
+	case 81:
+		b, a := s.pop(), s.pop()
+		s.push(value{lo: a.lo ^ b.lo, hi: a.hi ^ b.hi})
+	case 82: // v128.bitselect
+		c, b, a := s.pop(), s.pop(), s.pop()
+		s.push(value{
+			lo: a.lo&c.lo | b.lo&^c.lo,
+			hi: a.hi&c.hi | b.hi&^c.hi,
+		})
+	case 83: // v128.any_true
+		a := s.pop()
+		s.pushBool(a.lo|a.hi != 0)
+
+	// i8x16
+	case 96:
+		s.push(map8(s.pop(), value{}, func(x, _ uint8) uint8 {
+			if int8(x) < 0 {
+				return -x
+			}
+			return x
+		}))
+	case 97:
+		s.push(map8(s.pop(), value{}, func(x, _ uint8) uint8 { return -x }))
+	case 98:
+		s.push(map8(s.pop(), value{}, func(x, _ uint8) uint8 { return uint8(bits.OnesCount8(x)) }))
+	case 99:
+		a := s.pop().u8()
+		all := true
+		for _, x := range a {
+			all = all && x != 0
+		}
+		s.pushBool(all)
+	case 100:
+		a := s.pop().u8()
+		l := make([]uint64, len(a))
+		for i, x := range a {
+			l[i] = uint64(x)
+		}
+		s.pushU32(bitmask(l, 8))
+	case 101, 102:
+		b, a := s.pop().u16(), s.pop().u16()
+		sat := satS8
+		if op == 102 {
+			sat = satU8
+		}
+		var r [16]uint8
+		for i := 0; i < 8; i++ {
+			r[i] = sat(int32(int16(a[i])))
+			r[i+8] = sat(int32(int16(b[i])))
+		}
+		s.push(fromU8(r))
+	case 107, 108, 109:
+		n := s.popU32() % 8
+		s.push(map8(s.pop(), value{}, func(x, _ uint8) uint8 {
+			switch op {
+			case 107:
+				return x << n
+			case 108:
+				return uint8(int8(x) >> n)
+			default:
+				return x >> n
+			}
+		}))
+	case 110:
+		b, a := s.pop(), s.pop()
+		s.push(map8(a, b, func(x, y uint8) uint8 { return x + y }))
+	case 111:
+		b, a := s.pop(), s.pop()
+		s.push(map8(a, b, func(x, y uint8) uint8 { return satS8(int32(int8(x)) + int32(int8(y))) }))
+	case 112:
+		b, a := s.pop(), s.pop()
+		s.push(map8(a, b, func(x, y uint8) uint8 { return satU8(int32(x) + int32(y)) }))
+	case 113:
+		b, a := s.pop(), s.pop()
+		s.push(map8(a, b, func(x, y uint8) uint8 { return x - y }))
+	case 114:
+		b, a := s.pop(), s.pop()
+		s.push(map8(a, b, func(x, y uint8) uint8 { return satS8(int32(int8(x)) - int32(int8(y))) }))
+	case 115:
+		b, a := s.pop(), s.pop()
+		s.push(map8(a, b, func(x, y uint8) uint8 { return satU8(int32(x) - int32(y)) }))
+	case 118, 119, 120, 121:
+		b, a := s.pop(), s.pop()
+		s.push(map8(a, b, func(x, y uint8) uint8 {
+			less := [...]bool{int8(x) < int8(y), x < y, int8(x) > int8(y), x > y}[op-118]
+			if less {
+				return x
+			}
+			return y
+		}))
+	case 123:
+		b, a := s.pop(), s.pop()
+		s.push(map8(a, b, func(x, y uint8) uint8 { return uint8((uint32(x) + uint32(y) + 1) / 2) }))
+
+	// i16x8
+	case 128:
+		s.push(map16(s.pop(), value{}, func(x, _ uint16) uint16 {
+			if int16(x) < 0 {
+				return -x
+			}
+			return x
+		}))
+	case 129:
+		s.push(map16(s.pop(), value{}, func(x, _ uint16) uint16 { return -x }))
+	case 131:
+		a := s.pop().u16()
+		all := true
+		for _, x := range a {
+			all = all && x != 0
+		}
+		s.pushBool(all)
+	case 132:
+		a := s.pop().u16()
+		l := make([]uint64, len(a))
+		for i, x := range a {
+			l[i] = uint64(x)
+		}
+		s.pushU32(bitmask(l, 16))
+	case 133, 134:
+		b, a := s.pop().u32(), s.pop().u32()
+		sat := satS16
+		if op == 134 {
+			sat = satU16
+		}
+		var r [8]uint16
+		for i := 0; i < 4; i++ {
+			r[i] = sat(int32(a[i]))
+			r[i+4] = sat(int32(b[i]))
+		}
+		s.push(fromU16(r))
+	case 135, 136, 137, 138:
+		a := s.pop().u8()
+		var r [8]uint16
+		for i := range r {
+			x := a[i]
+			if op == 136 || op == 138 {
+				x = a[i+8]
+			}
+			if op <= 136 {
+				r[i] = uint16(int8(x))
+			} else {
+				r[i] = uint16(x)
+			}
+		}
+		s.push(fromU16(r))
+	case 139, 140, 141:
+		n := s.popU32() % 16
+		s.push(map16(s.pop(), value{}, func(x, _ uint16) uint16 {
+			switch op {
+			case 139:
+				return x << n
+			case 140:
+				return uint16(int16(x) >> n)
+			default:
+				return x >> n
+			}
+		}))
+	case 142:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 { return x + y }))
+	case 143:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 { return satS16(int32(int16(x)) + int32(int16(y))) }))
+	case 144:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 { return satU16(int32(x) + int32(y)) }))
+	case 145:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 { return x - y }))
+	case 146:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 { return satS16(int32(int16(x)) - int32(int16(y))) }))
+	case 147:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 { return satU16(int32(x) - int32(y)) }))
+	case 149:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 { return x * y }))
+	case 150, 151, 152, 153:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 {
+			less := [...]bool{int16(x) < int16(y), x < y, int16(x) > int16(y), x > y}[op-150]
+			if less {
+				return x
+			}
+			return y
+		}))
+	case 155:
+		b, a := s.pop(), s.pop()
+		s.push(map16(a, b, func(x, y uint16) uint16 { return uint16((uint32(x) + uint32(y) + 1) / 2) }))
+
+	// i32x4
+	case 160:
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 {
+			if int32(x) < 0 {
+				return -x
+			}
+			return x
+		}))
+	case 161:
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 { return -x }))
+	case 163:
+		a := s.pop().u32()
+		s.pushBool(a[0] != 0 && a[1] != 0 && a[2] != 0 && a[3] != 0)
+	case 164:
+		a := s.pop().u32()
+		s.pushU32(bitmask([]uint64{uint64(a[0]), uint64(a[1]), uint64(a[2]), uint64(a[3])}, 32))
+	case 167, 168, 169, 170:
+		a := s.pop().u16()
+		var r [4]uint32
+		for i := range r {
+			x := a[i]
+			if op == 168 || op == 170 {
+				x = a[i+4]
+			}
+			if op <= 168 {
+				r[i] = uint32(int16(x))
+			} else {
+				r[i] = uint32(x)
+			}
+		}
+		s.push(fromU32(r))
+	case 171, 172, 173:
+		n := s.popU32() % 32
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 {
+			switch op {
+			case 171:
+				return x << n
+			case 172:
+				return uint32(int32(x) >> n)
+			default:
+				return x >> n
+			}
+		}))
+	case 174:
+		b, a := s.pop(), s.pop()
+		s.push(map32(a, b, func(x, y uint32) uint32 { return x + y }))
+	case 177:
+		b, a := s.pop(), s.pop()
+		s.push(map32(a, b, func(x, y uint32) uint32 { return x - y }))
+	case 181:
+		b, a := s.pop(), s.pop()
+		s.push(map32(a, b, func(x, y uint32) uint32 { return x * y }))
+	case 182, 183, 184, 185:
+		b, a := s.pop(), s.pop()
+		s.push(map32(a, b, func(x, y uint32) uint32 {
+			less := [...]bool{int32(x) < int32(y), x < y, int32(x) > int32(y), x > y}[op-182]
+			if less {
+				return x
+			}
+			return y
+		}))
+
+	// f32x4
+	case 103, 104, 105, 106, 227:
+		i := op - 103
+		if op == 227 {
+			i = 4
+		}
+		s.push(mapF32(s.pop(), value{}, func(x, _ float32) float32 {
+			return float32(unaryFloat(i, float64(x)))
+		}))
+	case 224:
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 { return x &^ (1 << 31) }))
+	case 225:
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 { return x ^ (1 << 31) }))
+	case 228, 229, 230, 231, 232, 233:
+		b, a := s.pop(), s.pop()
+		s.push(mapF32(a, b, func(x, y float32) float32 { return binaryF32(op-228+0x92, x, y) }))
+	case 234:
+		b, a := s.pop(), s.pop()
+		s.push(mapF32(a, b, func(x, y float32) float32 {
+			if y < x {
+				return y
+			}
+			return x
+		}))
+	case 235:
+		b, a := s.pop(), s.pop()
+		s.push(mapF32(a, b, func(x, y float32) float32 {
+			if x < y {
+				return y
+			}
+			return x
+		}))
+
+	// conversions
+	case 248:
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 {
+			return truncSatS32(float64(math.Float32frombits(x)))
+		}))
+	case 249:
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 {
+			return truncSatU32(float64(math.Float32frombits(x)))
+		}))
+	case 250:
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 {
+			return math.Float32bits(float32(int32(x)))
+		}))
+	case 251:
+		s.push(map32(s.pop(), value{}, func(x, _ uint32) uint32 {
+			return math.Float32bits(float32(x))
+		}))
+	default:
+		trap("unsupported instruction %v", in.op)
+	}
+}
+
+// loadSIMD executes the v128 load instruction op.
+func (inst *Instance) loadSIMD(op opcode, offset uint32, s *stack) {
+	addr := s.popU32()
+	switch op {
+	case 0:
+		var b [16]byte
+		copy(b[:], inst.mem(addr, offset, 16))
+		s.push(v128(b))
+	case 1, 2: // v128.load8x8_s, v128.load8x8_u
+		m := inst.mem(addr, offset, 8)
+		var r [8]uint16
+		for i := range r {
+			if op == 1 {
+				r[i] = uint16(int8(m[i]))
+			} else {
+				r[i] = uint16(m[i])
+			}
+		}
+		s.push(fromU16(r))
+	case 3, 4: // v128.load16x4_s, v128.load16x4_u
+		m := inst.mem(addr, offset, 8)
+		var r [4]uint32
+		for i := range r {
+			x := binary.LittleEndian.Uint16(m[2*i:])
+			if op == 3 {
+				r[i] = uint32(int16(x))
+			} else {
+				r[i] = uint32(x)
+			}
+		}
+		s.push(fromU32(r))
+	case 5, 6: // v128.load32x2_s, v128.load32x2_u
+		m := inst.mem(addr, offset, 8)
+		var v value
+		for i := 0; i < 2; i++ {
+			x := binary.LittleEndian.Uint32(m[4*i:])
+			r := uint64(x)
+			if op == 5 {
+				r = uint64(int32(x))
+			}
+			if i == 0 {
+				v.lo = r
+			} else {
+				v.hi = r
+			}
+		}
+		s.push(v)
+	case 7: // v128.load8_splat
+		x := inst.mem(addr, offset, 1)[0]
+		s.push(map8(value{}, value{}, func(_, _ uint8) uint8 { return x }))
+	case 8: // v128.load16_splat
+		x := binary.LittleEndian.Uint16(inst.mem(addr, offset, 2))
+		s.push(map16(value{}, value{}, func(_, _ uint16) uint16 { return x }))
+	case 9: // v128.load32_splat
+		x := binary.LittleEndian.Uint32(inst.mem(addr, offset, 4))
+		s.push(fromU32([4]uint32{x, x, x, x}))
+	case 10: // v128.load64_splat
+		x := binary.LittleEndian.Uint64(inst.mem(addr, offset, 8))
+		s.push(value{lo: x, hi: x})
+	case 92: // v128.load32_zero
+		s.push(value{lo: uint64(binary.LittleEndian.Uint32(inst.mem(addr, offset, 4)))})
+	case 93: // v128.load64_zero
+		s.push(value{lo: binary.LittleEndian.Uint64(inst.mem(addr, offset, 8))})
+	}
+}
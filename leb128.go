@@ -18,6 +18,27 @@ func writeu32(v uint32, out io.Writer) {
 	}
 }
 
+// writes32 writes v in signed LEB128 format.
+func writes32(v int32, out io.Writer) {
+	writes64(int64(v), out)
+}
+
+// writes64 writes v in signed LEB128 format.
+func writes64(v int64, out io.Writer) {
+	for {
+		b := byte(v & 0b01111111)
+		v >>= 7
+		done := (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0)
+		if !done {
+			b |= 0x80
+		}
+		out.Write([]byte{b})
+		if done {
+			return
+		}
+	}
+}
+
 type u32 uint32
 
 func (u u32) write(c instCtx) error {
@@ -0,0 +1,171 @@
+package wasm
+
+// Mask4 represents the result of comparing the 4 lanes of
+// two vectors. Each lane has all bits set if the comparison
+// is true for that lane, or all bits clear otherwise.
+type Mask4 interface {
+	Instruction
+	isMask4()
+}
+
+type opsMask4 ops
+
+func (o opsMask4) isMask4() {}
+
+func (o opsMask4) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+// ConstMask4 is a constant Mask4 value.
+type ConstMask4 [4]bool
+
+func (c ConstMask4) isMask4() {}
+
+func (c ConstMask4) write(out instCtx) error {
+	constV128.write(out)
+	var imm [16]byte
+	for i, b := range c {
+		if b {
+			copy(imm[i*4:], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+		}
+	}
+	out.Write(imm[:])
+	return nil
+}
+
+// SplatMask4 returns a Mask4 with all lanes set to b.
+func SplatMask4(b Bool) Mask4 {
+	return opsMask4{
+		// all bits are set if b is true
+		SubI32(ConstI32(0), b),
+		splati32x4V128,
+	}
+}
+
+// EqVec4F32 returns whether a == b for each lane.
+func EqVec4F32(a, b Vec4F32) Mask4 { return opsMask4{a, b, eqf32x4V128} }
+
+// NeVec4F32 returns whether a != b for each lane.
+func NeVec4F32(a, b Vec4F32) Mask4 { return opsMask4{a, b, nef32x4V128} }
+
+// LtVec4F32 returns whether a < b for each lane.
+func LtVec4F32(a, b Vec4F32) Mask4 { return opsMask4{a, b, ltf32x4V128} }
+
+// GtVec4F32 returns whether a > b for each lane.
+func GtVec4F32(a, b Vec4F32) Mask4 { return opsMask4{a, b, gtf32x4V128} }
+
+// LeVec4F32 returns whether a <= b for each lane.
+func LeVec4F32(a, b Vec4F32) Mask4 { return opsMask4{a, b, lef32x4V128} }
+
+// GeVec4F32 returns whether a >= b for each lane.
+func GeVec4F32(a, b Vec4F32) Mask4 { return opsMask4{a, b, gef32x4V128} }
+
+// AndMask4 returns the lanes set in both a and b.
+func AndMask4(a, b Mask4) Mask4 { return opsMask4{a, b, andV128} }
+
+// OrMask4 returns the lanes set in either a or b.
+func OrMask4(a, b Mask4) Mask4 { return opsMask4{a, b, orV128} }
+
+// XorMask4 returns the lanes set in exactly one of a and b.
+func XorMask4(a, b Mask4) Mask4 { return opsMask4{a, b, xorV128} }
+
+// AndNotMask4 returns the lanes set in a but not in b.
+func AndNotMask4(a, b Mask4) Mask4 { return opsMask4{a, b, andnotV128} }
+
+// NotMask4 returns the lanes not set in a.
+func NotMask4(a Mask4) Mask4 { return opsMask4{a, notV128} }
+
+// AnyMask4 returns whether any lane of a is set.
+func AnyMask4(a Mask4) Bool { return opsBool{a, anyTrueV128} }
+
+// AllMask4 returns whether every lane of a is set.
+func AllMask4(a Mask4) Bool { return opsBool{a, allTruei32x4V128} }
+
+// Mask4Bits returns an I32 with bit i set if lane i of
+// a is set.
+func Mask4Bits(a Mask4) I32 { return opsI32{a, bitmaski32x4V128} }
+
+// AndVec4F32 returns the bitwise and of a and b.
+func AndVec4F32(a, b Vec4F32) Vec4F32 { return opsVec4F32{a, b, andV128} }
+
+// OrVec4F32 returns the bitwise or of a and b.
+func OrVec4F32(a, b Vec4F32) Vec4F32 { return opsVec4F32{a, b, orV128} }
+
+// XorVec4F32 returns the bitwise exclusive or of a and b.
+func XorVec4F32(a, b Vec4F32) Vec4F32 { return opsVec4F32{a, b, xorV128} }
+
+// AndNotVec4F32 returns the bitwise and of a and the
+// complement of b.
+func AndNotVec4F32(a, b Vec4F32) Vec4F32 { return opsVec4F32{a, b, andnotV128} }
+
+// MaskVec4F32 returns the lanes of a where mask is set,
+// and zero in all other lanes.
+func MaskVec4F32(mask Mask4, a Vec4F32) Vec4F32 { return opsVec4F32{a, mask, andV128} }
+
+// Mask8 represents the result of comparing the 8 lanes of
+// two Vec8I16 values.
+type Mask8 interface {
+	Instruction
+	isMask8()
+}
+
+type opsMask8 ops
+
+func (o opsMask8) isMask8() {}
+
+func (o opsMask8) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+// AndMask8 returns the lanes set in both a and b.
+func AndMask8(a, b Mask8) Mask8 { return opsMask8{a, b, andV128} }
+
+// OrMask8 returns the lanes set in either a or b.
+func OrMask8(a, b Mask8) Mask8 { return opsMask8{a, b, orV128} }
+
+// NotMask8 returns the lanes not set in a.
+func NotMask8(a Mask8) Mask8 { return opsMask8{a, notV128} }
+
+// AnyMask8 returns whether any lane of a is set.
+func AnyMask8(a Mask8) Bool { return opsBool{a, anyTrueV128} }
+
+// AllMask8 returns whether every lane of a is set.
+func AllMask8(a Mask8) Bool { return opsBool{a, allTruei16x8V128} }
+
+// Mask8Bits returns an I32 with bit i set if lane i of
+// a is set.
+func Mask8Bits(a Mask8) I32 { return opsI32{a, bitmaski16x8V128} }
+
+// Mask16 represents the result of comparing the 16 lanes of
+// two Vec16I8 values.
+type Mask16 interface {
+	Instruction
+	isMask16()
+}
+
+type opsMask16 ops
+
+func (o opsMask16) isMask16() {}
+
+func (o opsMask16) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+// AndMask16 returns the lanes set in both a and b.
+func AndMask16(a, b Mask16) Mask16 { return opsMask16{a, b, andV128} }
+
+// OrMask16 returns the lanes set in either a or b.
+func OrMask16(a, b Mask16) Mask16 { return opsMask16{a, b, orV128} }
+
+// NotMask16 returns the lanes not set in a.
+func NotMask16(a Mask16) Mask16 { return opsMask16{a, notV128} }
+
+// AnyMask16 returns whether any lane of a is set.
+func AnyMask16(a Mask16) Bool { return opsBool{a, anyTrueV128} }
+
+// AllMask16 returns whether every lane of a is set.
+func AllMask16(a Mask16) Bool { return opsBool{a, allTruei8x16V128} }
+
+// Mask16Bits returns an I32 with bit i set if lane i of
+// a is set.
+func Mask16Bits(a Mask16) I32 { return opsI32{a, bitmaski8x16V128} }
@@ -0,0 +1,463 @@
+package wasm
+
+import (
+	"fmt"
+	"io"
+)
+
+// Mat4F32 represents a 4x4 float32 matrix, stored as four
+// column Vec4F32 values. The columns are pushed to the
+// stack in order.
+type Mat4F32 interface {
+	Instruction
+	isMat4F32()
+}
+
+// MutableMat4F32 represents a mutable Mat4F32 node.
+type MutableMat4F32 interface {
+	Mat4F32
+	set(out io.Writer) error
+}
+
+// GlobalMat4F32 represents a mutable Mat4F32 in the
+// global scope of the wasm module. It is stored as
+// four GlobalVec4F32 columns, which may be exported
+// individually.
+type GlobalMat4F32 interface {
+	MutableMat4F32
+	// Column returns the global holding column i.
+	Column(i int) GlobalVec4F32
+}
+
+// mat4F32 is a matrix whose columns are stored in
+// globals or locals.
+type mat4F32 [4]MutableVec4F32
+
+func (m mat4F32) isMat4F32() {}
+
+func (m mat4F32) write(out instCtx) error {
+	for _, col := range m {
+		if err := col.write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m mat4F32) set(out io.Writer) error {
+	// the last column is on top of the stack
+	for i := len(m) - 1; i >= 0; i-- {
+		if err := m[i].set(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m mat4F32) Column(i int) GlobalVec4F32 {
+	if i < 0 || i >= len(m) {
+		panic(fmt.Errorf("column %d out of range [0, 4)", i))
+	}
+	return m[i].(GlobalVec4F32)
+}
+
+// GlobalMat4F32 creates a global, mutable Mat4F32 object
+// with the column-major elements init.
+func (m *Module) GlobalMat4F32(init [16]float32) GlobalMat4F32 {
+	var out mat4F32
+	for i := range out {
+		var col [4]float32
+		copy(col[:], init[i*4:])
+		out[i] = m.GlobalVec4F32(col)
+	}
+	return out
+}
+
+// ImportMat4F32 imports a global Mat4F32 value as four
+// Vec4F32 columns, named name.0 to name.3.
+// If any column has already been imported, ImportMat4F32 panics.
+func (m *Module) ImportMat4F32(mod, name string) MutableMat4F32 {
+	var out mat4F32
+	for i := range out {
+		out[i] = m.ImportVec4F32(mod, fmt.Sprintf("%s.%d", name, i))
+	}
+	return out
+}
+
+func (f *function) LocalMat4F32() MutableMat4F32 {
+	var out mat4F32
+	for i := range out {
+		out[i] = f.LocalVec4F32()
+	}
+	return out
+}
+
+type opsMat4F32 ops
+
+func (o opsMat4F32) isMat4F32() {}
+
+func (o opsMat4F32) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+// ConstMat4F32 is a constant Mat4F32 value, with
+// elements in column-major order.
+type ConstMat4F32 [16]float32
+
+func (c ConstMat4F32) isMat4F32() {}
+
+func (c ConstMat4F32) write(out instCtx) error {
+	for i := 0; i < 4; i++ {
+		var col ConstVec4F32
+		copy(col[:], c[i*4:])
+		if err := col.write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IdentityMat4F32 is the identity matrix.
+var IdentityMat4F32 = ConstMat4F32{
+	1, 0, 0, 0,
+	0, 1, 0, 0,
+	0, 0, 1, 0,
+	0, 0, 0, 1,
+}
+
+// AssignMat4F32 assigns the value of v to dst.
+func AssignMat4F32(dst MutableMat4F32, v Mat4F32) Instruction {
+	return assignMat4F32{dst: dst, v: v}
+}
+
+type assignMat4F32 struct {
+	dst MutableMat4F32
+	v   Mat4F32
+}
+
+func (a assignMat4F32) write(out instCtx) error {
+	if err := a.v.write(out); err != nil {
+		return err
+	}
+	if err := a.dst.set(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// letMat4F32 stores the columns of x in temporary locals
+// so that they can be used more than once without being
+// evaluated again.
+type letMat4F32 struct {
+	x  Mat4F32
+	in func(cols [4]Vec4F32) Instruction
+}
+
+func (l letMat4F32) write(c instCtx) error {
+	t := c.fn.LocalMat4F32().(mat4F32)
+	if err := l.x.write(c); err != nil {
+		return err
+	}
+	if err := t.set(c); err != nil {
+		return err
+	}
+	var cols [4]Vec4F32
+	for i := range t {
+		cols[i] = t[i]
+	}
+	return l.in(cols).write(c)
+}
+
+// Mat4F32FromColumns returns the Mat4F32 with columns
+// c0, c1, c2 and c3.
+func Mat4F32FromColumns(c0, c1, c2, c3 Vec4F32) Mat4F32 {
+	return opsMat4F32{c0, c1, c2, c3}
+}
+
+// ColumnMat4F32 returns column i of m.
+func ColumnMat4F32(m Mat4F32, i int) Vec4F32 {
+	return columnMat4F32{m: m, i: i}
+}
+
+type columnMat4F32 struct {
+	m Mat4F32
+	i int
+}
+
+func (c columnMat4F32) isVec4F32() {}
+
+func (c columnMat4F32) write(out instCtx) error {
+	if c.i < 0 || c.i >= 4 {
+		return fmt.Errorf("column %d out of range [0, 4)", c.i)
+	}
+	return letMat4F32{x: c.m, in: func(cols [4]Vec4F32) Instruction {
+		return cols[c.i]
+	}}.write(out)
+}
+
+// broadcastVec4F32 returns lane i of v in every lane.
+func broadcastVec4F32(v Vec4F32, i int) Vec4F32 {
+	return ShuffleVec4F32(v, v, [4]int{i, i, i, i})
+}
+
+// mulColumnsVec4F32 multiplies the matrix with columns
+// cols by v, which must both be free to evaluate more
+// than once.
+func mulColumnsVec4F32(cols [4]Vec4F32, v Vec4F32) Vec4F32 {
+	return AddVec4F32(
+		AddVec4F32(
+			MulVec4F32(cols[0], broadcastVec4F32(v, 0)),
+			MulVec4F32(cols[1], broadcastVec4F32(v, 1)),
+		),
+		AddVec4F32(
+			MulVec4F32(cols[2], broadcastVec4F32(v, 2)),
+			MulVec4F32(cols[3], broadcastVec4F32(v, 3)),
+		),
+	)
+}
+
+// MulMat4Vec4 returns the product of m and the column
+// vector v.
+func MulMat4Vec4(m Mat4F32, v Vec4F32) Vec4F32 {
+	return opsVec4F32{letMat4F32{x: m, in: func(cols [4]Vec4F32) Instruction {
+		return letVec4F32{x: v, in: func(v Vec4F32) Instruction {
+			return mulColumnsVec4F32(cols, v)
+		}}
+	}}}
+}
+
+// MulMat4 returns the matrix product of a and b. When
+// transforming a vector, b is applied first.
+func MulMat4(a, b Mat4F32) Mat4F32 {
+	return opsMat4F32{letMat4F32{x: a, in: func(a [4]Vec4F32) Instruction {
+		return letMat4F32{x: b, in: func(b [4]Vec4F32) Instruction {
+			return ops{
+				mulColumnsVec4F32(a, b[0]),
+				mulColumnsVec4F32(a, b[1]),
+				mulColumnsVec4F32(a, b[2]),
+				mulColumnsVec4F32(a, b[3]),
+			}
+		}}
+	}}}
+}
+
+// TransposeMat4 returns the transpose of m.
+func TransposeMat4(m Mat4F32) Mat4F32 {
+	return opsMat4F32{letMat4F32{x: m, in: func(c [4]Vec4F32) Instruction {
+		// interleave the low and high halves of pairs of
+		// columns, then combine the halves of the results.
+		lo01 := ShuffleVec4F32(c[0], c[1], [4]int{0, 4, 1, 5})
+		lo23 := ShuffleVec4F32(c[2], c[3], [4]int{0, 4, 1, 5})
+		hi01 := ShuffleVec4F32(c[0], c[1], [4]int{2, 6, 3, 7})
+		hi23 := ShuffleVec4F32(c[2], c[3], [4]int{2, 6, 3, 7})
+		return ops{
+			ShuffleVec4F32(lo01, lo23, [4]int{0, 1, 4, 5}),
+			ShuffleVec4F32(lo01, lo23, [4]int{2, 3, 6, 7}),
+			ShuffleVec4F32(hi01, hi23, [4]int{0, 1, 4, 5}),
+			ShuffleVec4F32(hi01, hi23, [4]int{2, 3, 6, 7}),
+		}
+	}}}
+}
+
+// inverseMat4Cofactors lists the terms of each element
+// of the adjugate of a column-major matrix m. Element i
+// is the sum of m[a]*m[b]*m[c] for each of its terms, with
+// the signs + - - + + -, negated if neg is set.
+var inverseMat4Cofactors = [16]struct {
+	neg   bool
+	terms [6][3]int
+}{
+	{false, [6][3]int{{5, 10, 15}, {5, 11, 14}, {9, 6, 15}, {9, 7, 14}, {13, 6, 11}, {13, 7, 10}}},
+	{true, [6][3]int{{1, 10, 15}, {1, 11, 14}, {9, 2, 15}, {9, 3, 14}, {13, 2, 11}, {13, 3, 10}}},
+	{false, [6][3]int{{1, 6, 15}, {1, 7, 14}, {5, 2, 15}, {5, 3, 14}, {13, 2, 7}, {13, 3, 6}}},
+	{true, [6][3]int{{1, 6, 11}, {1, 7, 10}, {5, 2, 11}, {5, 3, 10}, {9, 2, 7}, {9, 3, 6}}},
+	{true, [6][3]int{{4, 10, 15}, {4, 11, 14}, {8, 6, 15}, {8, 7, 14}, {12, 6, 11}, {12, 7, 10}}},
+	{false, [6][3]int{{0, 10, 15}, {0, 11, 14}, {8, 2, 15}, {8, 3, 14}, {12, 2, 11}, {12, 3, 10}}},
+	{true, [6][3]int{{0, 6, 15}, {0, 7, 14}, {4, 2, 15}, {4, 3, 14}, {12, 2, 7}, {12, 3, 6}}},
+	{false, [6][3]int{{0, 6, 11}, {0, 7, 10}, {4, 2, 11}, {4, 3, 10}, {8, 2, 7}, {8, 3, 6}}},
+	{false, [6][3]int{{4, 9, 15}, {4, 11, 13}, {8, 5, 15}, {8, 7, 13}, {12, 5, 11}, {12, 7, 9}}},
+	{true, [6][3]int{{0, 9, 15}, {0, 11, 13}, {8, 1, 15}, {8, 3, 13}, {12, 1, 11}, {12, 3, 9}}},
+	{false, [6][3]int{{0, 5, 15}, {0, 7, 13}, {4, 1, 15}, {4, 3, 13}, {12, 1, 7}, {12, 3, 5}}},
+	{true, [6][3]int{{0, 5, 11}, {0, 7, 9}, {4, 1, 11}, {4, 3, 9}, {8, 1, 7}, {8, 3, 5}}},
+	{true, [6][3]int{{4, 9, 14}, {4, 10, 13}, {8, 5, 14}, {8, 6, 13}, {12, 5, 10}, {12, 6, 9}}},
+	{false, [6][3]int{{0, 9, 14}, {0, 10, 13}, {8, 1, 14}, {8, 2, 13}, {12, 1, 10}, {12, 2, 9}}},
+	{true, [6][3]int{{0, 5, 14}, {0, 6, 13}, {4, 1, 14}, {4, 2, 13}, {12, 1, 6}, {12, 2, 5}}},
+	{false, [6][3]int{{0, 5, 10}, {0, 6, 9}, {4, 1, 10}, {4, 2, 9}, {8, 1, 6}, {8, 2, 5}}},
+}
+
+// InverseMat4 returns the inverse of m. If m is not
+// invertible, the elements of the result are infinite
+// or NaN.
+func InverseMat4(m Mat4F32) Mat4F32 {
+	return inverseMat4F32{m: m}
+}
+
+type inverseMat4F32 struct {
+	m Mat4F32
+}
+
+func (inv inverseMat4F32) isMat4F32() {}
+
+func (inv inverseMat4F32) write(c instCtx) error {
+	return letMat4F32{x: inv.m, in: func(cols [4]Vec4F32) Instruction {
+		var body ops
+		var m [16]F32
+		for i := range m {
+			l := c.fn.LocalF32()
+			body = append(body, AssignF32(l, ExtractLaneVec4F32(cols[i/4], i%4)))
+			m[i] = l
+		}
+		var adj [16]F32
+		for i, cf := range inverseMat4Cofactors {
+			var sum F32
+			for j, t := range cf.terms {
+				p := MulF32(MulF32(m[t[0]], m[t[1]]), m[t[2]])
+				// signs are + - - + + -
+				neg := (j == 1 || j == 2 || j == 5) != cf.neg
+				switch {
+				case sum == nil && neg:
+					sum = NegF32(p)
+				case sum == nil:
+					sum = p
+				case neg:
+					sum = SubF32(sum, p)
+				default:
+					sum = AddF32(sum, p)
+				}
+			}
+			adj[i] = sum
+		}
+		// the first column of the adjugate is also
+		// used for the determinant.
+		for i := 0; i < 4; i++ {
+			l := c.fn.LocalF32()
+			body = append(body, AssignF32(l, adj[i*4]))
+			adj[i*4] = l
+		}
+		det := AddF32(
+			AddF32(MulF32(m[0], adj[0]), MulF32(m[1], adj[4])),
+			AddF32(MulF32(m[2], adj[8]), MulF32(m[3], adj[12])),
+		)
+		return append(body, letF32{
+			x: DivF32(ConstF32(1), det),
+			in: func(invDet F32) Instruction {
+				var out ops
+				for i := 0; i < 4; i++ {
+					out = append(out, MulVec4F32(
+						Vec4F32FromLanes(adj[i*4], adj[i*4+1], adj[i*4+2], adj[i*4+3]),
+						SplatVec4F32(invDet),
+					))
+				}
+				return out
+			},
+		})
+	}}.write(c)
+}
+
+// TranslationMat4 returns a matrix that translates
+// by x, y and z.
+func TranslationMat4(x, y, z F32) Mat4F32 {
+	return opsMat4F32{
+		ConstVec4F32{1, 0, 0, 0},
+		ConstVec4F32{0, 1, 0, 0},
+		ConstVec4F32{0, 0, 1, 0},
+		Vec4F32FromLanes(x, y, z, ConstF32(1)),
+	}
+}
+
+// ScaleMat4 returns a matrix that scales by x, y and z.
+func ScaleMat4(x, y, z F32) Mat4F32 {
+	return opsMat4F32{
+		ReplaceLaneVec4F32(ConstVec4F32{}, 0, x),
+		ReplaceLaneVec4F32(ConstVec4F32{}, 1, y),
+		ReplaceLaneVec4F32(ConstVec4F32{}, 2, z),
+		ConstVec4F32{0, 0, 0, 1},
+	}
+}
+
+// rotationMat4 returns a rotation about the axis i, given
+// the sine and cosine of the angle.
+func rotationMat4(i int, sin, cos F32) Mat4F32 {
+	j, k := (i+1)%3, (i+2)%3
+	return opsMat4F32{letF32{x: sin, in: func(s F32) Instruction {
+		return letF32{x: cos, in: func(c F32) Instruction {
+			var cols [4]Vec4F32
+			cols[i] = ReplaceLaneVec4F32(ConstVec4F32{}, i, ConstF32(1))
+			// column j is (c, s) and column k is (-s, c)
+			// in rows j and k.
+			cols[j] = ReplaceLaneVec4F32(ReplaceLaneVec4F32(ConstVec4F32{}, j, c), k, s)
+			cols[k] = ReplaceLaneVec4F32(ReplaceLaneVec4F32(ConstVec4F32{}, j, NegF32(s)), k, c)
+			cols[3] = ConstVec4F32{0, 0, 0, 1}
+			return ops{cols[0], cols[1], cols[2], cols[3]}
+		}}
+	}}}
+}
+
+// RotationXMat4 returns a matrix that rotates about the
+// x axis, given the sine and cosine of the angle.
+func RotationXMat4(sin, cos F32) Mat4F32 { return rotationMat4(0, sin, cos) }
+
+// RotationYMat4 returns a matrix that rotates about the
+// y axis, given the sine and cosine of the angle.
+func RotationYMat4(sin, cos F32) Mat4F32 { return rotationMat4(1, sin, cos) }
+
+// RotationZMat4 returns a matrix that rotates about the
+// z axis, given the sine and cosine of the angle.
+func RotationZMat4(sin, cos F32) Mat4F32 { return rotationMat4(2, sin, cos) }
+
+// RotationMat4 returns a matrix that rotates by the unit
+// quaternion q, with the lanes x, y, z and w.
+func RotationMat4(q Vec4F32) Mat4F32 {
+	return opsMat4F32{letVec4F32{x: q, in: func(q Vec4F32) Instruction {
+		x := ExtractLaneVec4F32(q, 0)
+		y := ExtractLaneVec4F32(q, 1)
+		z := ExtractLaneVec4F32(q, 2)
+		w := ExtractLaneVec4F32(q, 3)
+		// twice the product of a and b
+		p := func(a, b F32) F32 { return MulF32(ConstF32(2), MulF32(a, b)) }
+		one := ConstF32(1)
+		return ops{
+			Vec4F32FromLanes(
+				SubF32(one, AddF32(p(y, y), p(z, z))),
+				AddF32(p(x, y), p(z, w)),
+				SubF32(p(x, z), p(y, w)),
+				ConstF32(0),
+			),
+			Vec4F32FromLanes(
+				SubF32(p(x, y), p(z, w)),
+				SubF32(one, AddF32(p(x, x), p(z, z))),
+				AddF32(p(y, z), p(x, w)),
+				ConstF32(0),
+			),
+			Vec4F32FromLanes(
+				AddF32(p(x, z), p(y, w)),
+				SubF32(p(y, z), p(x, w)),
+				SubF32(one, AddF32(p(x, x), p(y, y))),
+				ConstF32(0),
+			),
+			ConstVec4F32{0, 0, 0, 1},
+		}
+	}}}
+}
+
+// PerspectiveMat4 returns a perspective projection matrix,
+// mapping the view frustum between the near and far planes
+// to clip space with z from -1 to 1. focal is the cotangent
+// of half the vertical field of view, and aspect is the
+// ratio of width to height.
+func PerspectiveMat4(focal, aspect, near, far F32) Mat4F32 {
+	return opsMat4F32{letF32{x: focal, in: func(focal F32) Instruction {
+		return letF32{x: near, in: func(n F32) Instruction {
+			return letF32{x: far, in: func(f F32) Instruction {
+				d := SubF32(n, f)
+				return ops{
+					ReplaceLaneVec4F32(ConstVec4F32{}, 0, DivF32(focal, aspect)),
+					ReplaceLaneVec4F32(ConstVec4F32{}, 1, focal),
+					ReplaceLaneVec4F32(ConstVec4F32{0, 0, 0, -1}, 2, DivF32(AddF32(f, n), d)),
+					ReplaceLaneVec4F32(ConstVec4F32{}, 2,
+						DivF32(MulF32(ConstF32(2), MulF32(f, n)), d)),
+				}
+			}}
+		}}
+	}}}
+}
@@ -0,0 +1,839 @@
+package wasm
+
+import (
+	"io"
+	"math"
+)
+
+// The transcendental functions below are added to the module as
+// helper functions the first time they are used, and are shared by
+// every call. Each is written once against numKind, so the F32 and
+// Vec4F32 variants compute identical results in each lane.
+//
+// The polynomial approximations are those of the Cephes library
+// for single precision. Errors are in units in the last place of
+// the exact result, measured over the ranges given.
+
+// SinF32 returns the sine of x, in radians. The error is at most
+// 1.5 ulp for all x. Arguments beyond 4096 in magnitude are
+// reduced exactly in F64, which is slower.
+func SinF32(x F32) F32 { return callHelper(f32Kind{}, sinHelper, x).(F32) }
+
+// CosF32 returns the cosine of x, in radians. The error is at
+// most 1.5 ulp for all x, reduced as by SinF32.
+func CosF32(x F32) F32 { return callHelper(f32Kind{}, cosHelper, x).(F32) }
+
+// TanF32 returns the tangent of x, in radians. The error is at
+// most 2.5 ulp for all x, reduced as by SinF32.
+func TanF32(x F32) F32 { return callHelper(f32Kind{}, tanHelper, x).(F32) }
+
+// Atan2F32 returns the arc tangent of y/x, in radians, using the
+// signs of both to determine the quadrant. The error is at most
+// 2 ulp. If x and y are both infinite, the result is NaN.
+func Atan2F32(y, x F32) F32 { return callHelper(f32Kind{}, atan2Helper, y, x).(F32) }
+
+// ExpF32 returns e**x. The error is at most 1 ulp, including
+// results in the subnormal range.
+func ExpF32(x F32) F32 { return callHelper(f32Kind{}, expHelper, x).(F32) }
+
+// LogF32 returns the natural logarithm of x. The error is at
+// most 1 ulp. LogF32 returns -Inf for 0, and NaN for negative x.
+func LogF32(x F32) F32 { return callHelper(f32Kind{}, logHelper, x).(F32) }
+
+// PowF32 returns x**y, computed as ExpF32(y*LogF32(|x|)). The
+// error grows with |y*log(x)|, from a few ulp for results near 1
+// to about 128 ulp for results near the limits of the F32 range.
+// Negative x is supported for integer y. As in the math package,
+// the result is 1 for y = 0, x = 1, and x = -1 with infinite y.
+func PowF32(x, y F32) F32 { return callHelper(f32Kind{}, powHelper, x, y).(F32) }
+
+// TanhF32 returns the hyperbolic tangent of x. The error is at
+// most 2 ulp.
+func TanhF32(x F32) F32 { return callHelper(f32Kind{}, tanhHelper, x).(F32) }
+
+// SinVec4F32 returns the sine of each lane of x, as SinF32.
+// Lanes beyond 4096 in magnitude are computed by SinF32.
+func SinVec4F32(x Vec4F32) Vec4F32 { return callHelper(vec4F32Kind{}, sinHelper, x).(Vec4F32) }
+
+// CosVec4F32 returns the cosine of each lane of x, as CosF32.
+// Lanes beyond 4096 in magnitude are computed by CosF32.
+func CosVec4F32(x Vec4F32) Vec4F32 { return callHelper(vec4F32Kind{}, cosHelper, x).(Vec4F32) }
+
+// TanVec4F32 returns the tangent of each lane of x, as TanF32.
+// Lanes beyond 4096 in magnitude are computed by TanF32.
+func TanVec4F32(x Vec4F32) Vec4F32 { return callHelper(vec4F32Kind{}, tanHelper, x).(Vec4F32) }
+
+// Atan2Vec4F32 returns the arc tangent of y/x for each lane,
+// as Atan2F32.
+func Atan2Vec4F32(y, x Vec4F32) Vec4F32 {
+	return callHelper(vec4F32Kind{}, atan2Helper, y, x).(Vec4F32)
+}
+
+// ExpVec4F32 returns e**x for each lane of x, as ExpF32.
+func ExpVec4F32(x Vec4F32) Vec4F32 { return callHelper(vec4F32Kind{}, expHelper, x).(Vec4F32) }
+
+// LogVec4F32 returns the natural logarithm of each lane of x,
+// as LogF32.
+func LogVec4F32(x Vec4F32) Vec4F32 { return callHelper(vec4F32Kind{}, logHelper, x).(Vec4F32) }
+
+// PowVec4F32 returns x**y for each lane, as PowF32.
+func PowVec4F32(x, y Vec4F32) Vec4F32 {
+	return callHelper(vec4F32Kind{}, powHelper, x, y).(Vec4F32)
+}
+
+// TanhVec4F32 returns the hyperbolic tangent of each lane of x,
+// as TanhF32.
+func TanhVec4F32(x Vec4F32) Vec4F32 { return callHelper(vec4F32Kind{}, tanhHelper, x).(Vec4F32) }
+
+// mathHelper is a helper function with params arguments
+// of the same kind, returning a single value.
+type mathHelper struct {
+	name   string
+	params int
+	body   func(k numKind, f Function, args []Instruction) Instruction
+	// large, if set, computes the F32 helper for arguments
+	// beyond reduceLimit in magnitude, which body does not
+	// support.
+	large func(f Function, x F32) F32
+}
+
+// numKind abstracts the arithmetic of F32 and Vec4F32, so that
+// each helper is written once for both. Float values, integer
+// values and masks are each represented by an Instruction of the
+// matching F32/I32/Bool or Vec4F32/Vec4I32/Mask4 type.
+type numKind interface {
+	name() string
+	typ() Type
+	wrap(a Instruction) Instruction
+	param(f Function, i int) Instruction
+	local(f Function) numVar
+	localInt(f Function) numVar
+
+	c(v float32) Instruction
+	add(a, b Instruction) Instruction
+	sub(a, b Instruction) Instruction
+	mul(a, b Instruction) Instruction
+	div(a, b Instruction) Instruction
+	min(a, b Instruction) Instruction
+	max(a, b Instruction) Instruction
+	neg(a Instruction) Instruction
+	abs(a Instruction) Instruction
+	nearest(a Instruction) Instruction
+	trunc(a Instruction) Instruction
+	copysign(a, b Instruction) Instruction
+
+	// large returns v, with the values for which |x| is
+	// beyond reduceLimit replaced by h.large of them.
+	large(f Function, h *mathHelper, x, v Instruction) Instruction
+
+	lt(a, b Instruction) Instruction
+	gt(a, b Instruction) Instruction
+	eq(a, b Instruction) Instruction
+	ne(a, b Instruction) Instruction
+	and(a, b Instruction) Instruction
+	or(a, b Instruction) Instruction
+	sel(mask, a, b Instruction) Instruction
+
+	bits(a Instruction) Instruction
+	fromBits(a Instruction) Instruction
+	toInt(a Instruction) Instruction
+	fromInt(a Instruction) Instruction
+	ic(v int32) Instruction
+	iadd(a, b Instruction) Instruction
+	isub(a, b Instruction) Instruction
+	iand(a, b Instruction) Instruction
+	ior(a, b Instruction) Instruction
+	ixor(a, b Instruction) Instruction
+	ishl(a Instruction, n int32) Instruction
+	ishrS(a Instruction, n int32) Instruction
+	ishrU(a Instruction, n int32) Instruction
+	ine(a, b Instruction) Instruction
+	ilt(a, b Instruction) Instruction
+}
+
+// numVar is a local of a numKind.
+type numVar interface {
+	Instruction
+	set(out io.Writer) error
+}
+
+// assignNum assigns the value of v to dst.
+func assignNum(dst numVar, v Instruction) Instruction {
+	return ops{v, setNum{dst}}
+}
+
+type setNum struct {
+	v numVar
+}
+
+func (s setNum) write(out instCtx) error {
+	return s.v.set(out)
+}
+
+// callHelper returns a call to the helper h for kind k, adding
+// the helper to the module if necessary.
+func callHelper(k numKind, h *mathHelper, args ...Instruction) Instruction {
+	return k.wrap(helperCall{k: k, h: h, args: args})
+}
+
+type helperCall struct {
+	k    numKind
+	h    *mathHelper
+	args []Instruction
+}
+
+func (hc helperCall) write(c instCtx) error {
+	sig := Signature{Results: []Type{hc.k.typ()}}
+	for i := 0; i < hc.h.params; i++ {
+		sig.Params = append(sig.Params, hc.k.typ())
+	}
+	f := c.m.helper(hc.h.name+hc.k.name(), sig, func(f Function) []Instruction {
+		args := make([]Instruction, hc.h.params)
+		for i := range args {
+			args[i] = hc.k.param(f, i)
+		}
+		v := hc.h.body(hc.k, f, args)
+		if hc.h.large != nil {
+			v = hc.k.large(f, hc.h, args[0], hc.k.wrap(v))
+		}
+		return []Instruction{ReturnValue(hc.k.wrap(v))}
+	})
+	return call{c: f, args: hc.args, result: hc.k.typ()}.write(c)
+}
+
+type f32Kind struct{}
+
+func (f32Kind) name() string                        { return "F32" }
+func (f32Kind) typ() Type                           { return TypeF32 }
+func (f32Kind) wrap(a Instruction) Instruction      { return opsF32{a} }
+func (f32Kind) param(f Function, i int) Instruction { return f.ParamF32(i) }
+func (f32Kind) local(f Function) numVar             { return f.LocalF32() }
+func (f32Kind) localInt(f Function) numVar          { return f.LocalI32() }
+
+func (f32Kind) c(v float32) Instruction          { return ConstF32(v) }
+func (f32Kind) add(a, b Instruction) Instruction { return AddF32(a.(F32), b.(F32)) }
+func (f32Kind) sub(a, b Instruction) Instruction { return SubF32(a.(F32), b.(F32)) }
+func (f32Kind) mul(a, b Instruction) Instruction { return MulF32(a.(F32), b.(F32)) }
+func (f32Kind) div(a, b Instruction) Instruction { return DivF32(a.(F32), b.(F32)) }
+func (f32Kind) min(a, b Instruction) Instruction { return MinF32(a.(F32), b.(F32)) }
+func (f32Kind) max(a, b Instruction) Instruction { return MaxF32(a.(F32), b.(F32)) }
+func (f32Kind) neg(a Instruction) Instruction    { return NegF32(a.(F32)) }
+func (f32Kind) abs(a Instruction) Instruction    { return AbsF32(a.(F32)) }
+func (f32Kind) nearest(a Instruction) Instruction {
+	return NearestF32(a.(F32))
+}
+func (f32Kind) trunc(a Instruction) Instruction { return TruncF32(a.(F32)) }
+func (f32Kind) copysign(a, b Instruction) Instruction {
+	return CopysignF32(a.(F32), b.(F32))
+}
+func (f32Kind) large(f Function, h *mathHelper, x, v Instruction) Instruction {
+	return IfExprF32{
+		Condition: GtF32(AbsF32(x.(F32)), ConstF32(reduceLimit)),
+		Then:      h.large(f, x.(F32)),
+		Else:      v.(F32),
+	}
+}
+
+func (f32Kind) lt(a, b Instruction) Instruction  { return LtF32(a.(F32), b.(F32)) }
+func (f32Kind) gt(a, b Instruction) Instruction  { return GtF32(a.(F32), b.(F32)) }
+func (f32Kind) eq(a, b Instruction) Instruction  { return EqF32(a.(F32), b.(F32)) }
+func (f32Kind) ne(a, b Instruction) Instruction  { return NeF32(a.(F32), b.(F32)) }
+func (f32Kind) and(a, b Instruction) Instruction { return And(a.(Bool), b.(Bool)) }
+func (f32Kind) or(a, b Instruction) Instruction  { return Or(a.(Bool), b.(Bool)) }
+func (f32Kind) sel(mask, a, b Instruction) Instruction {
+	return SelectF32(mask.(Bool), a.(F32), b.(F32))
+}
+
+func (f32Kind) bits(a Instruction) Instruction     { return F32Bits(a.(F32)) }
+func (f32Kind) fromBits(a Instruction) Instruction { return F32FromBits(a.(I32)) }
+func (f32Kind) toInt(a Instruction) Instruction    { return F32ToI32(a.(F32)) }
+func (f32Kind) fromInt(a Instruction) Instruction  { return I32ToF32(a.(I32)) }
+func (f32Kind) ic(v int32) Instruction             { return ConstI32(v) }
+func (f32Kind) iadd(a, b Instruction) Instruction  { return AddI32(a.(I32), b.(I32)) }
+func (f32Kind) isub(a, b Instruction) Instruction  { return SubI32(a.(I32), b.(I32)) }
+func (f32Kind) iand(a, b Instruction) Instruction  { return AndI32(a.(I32), b.(I32)) }
+func (f32Kind) ior(a, b Instruction) Instruction   { return OrI32(a.(I32), b.(I32)) }
+func (f32Kind) ixor(a, b Instruction) Instruction  { return XorI32(a.(I32), b.(I32)) }
+func (f32Kind) ishl(a Instruction, n int32) Instruction {
+	return ShlI32(a.(I32), ConstI32(n))
+}
+func (f32Kind) ishrS(a Instruction, n int32) Instruction {
+	return ShrSI32(a.(I32), ConstI32(n))
+}
+func (f32Kind) ishrU(a Instruction, n int32) Instruction {
+	return ShrUI32(a.(I32), ConstI32(n))
+}
+func (f32Kind) ine(a, b Instruction) Instruction { return NeI32(a.(I32), b.(I32)) }
+func (f32Kind) ilt(a, b Instruction) Instruction { return LtI32(a.(I32), b.(I32)) }
+
+type vec4F32Kind struct{}
+
+func (vec4F32Kind) name() string                        { return "Vec4F32" }
+func (vec4F32Kind) typ() Type                           { return TypeVec4F32 }
+func (vec4F32Kind) wrap(a Instruction) Instruction      { return opsVec4F32{a} }
+func (vec4F32Kind) param(f Function, i int) Instruction { return f.ParamVec4F32(i) }
+func (vec4F32Kind) local(f Function) numVar             { return f.LocalVec4F32() }
+func (vec4F32Kind) localInt(f Function) numVar          { return f.LocalVec4I32() }
+
+func (vec4F32Kind) c(v float32) Instruction { return ConstVec4F32{v, v, v, v} }
+func (vec4F32Kind) add(a, b Instruction) Instruction {
+	return AddVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) sub(a, b Instruction) Instruction {
+	return SubVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) mul(a, b Instruction) Instruction {
+	return MulVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) div(a, b Instruction) Instruction {
+	return DivVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) min(a, b Instruction) Instruction {
+	return MinVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) max(a, b Instruction) Instruction {
+	return MaxVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) neg(a Instruction) Instruction     { return NegVec4F32(a.(Vec4F32)) }
+func (vec4F32Kind) abs(a Instruction) Instruction     { return AbsVec4F32(a.(Vec4F32)) }
+func (vec4F32Kind) nearest(a Instruction) Instruction { return NearestVec4F32(a.(Vec4F32)) }
+func (vec4F32Kind) trunc(a Instruction) Instruction   { return TruncVec4F32(a.(Vec4F32)) }
+func (vec4F32Kind) copysign(a, b Instruction) Instruction {
+	// take the sign bit from b, and the others from a
+	return Vec4F32FromBits(OrVec4I32(
+		AndVec4I32(Vec4F32Bits(a.(Vec4F32)), ConstVec4I32{math.MaxInt32, math.MaxInt32, math.MaxInt32, math.MaxInt32}),
+		AndVec4I32(Vec4F32Bits(b.(Vec4F32)), ConstVec4I32{math.MinInt32, math.MinInt32, math.MinInt32, math.MinInt32}),
+	))
+}
+func (vec4F32Kind) large(f Function, h *mathHelper, x, v Instruction) Instruction {
+	// the large lanes are rare, and computed one at a time
+	// by the F32 helper
+	res := f.LocalVec4F32()
+	lanes := f.LocalI32()
+	var each []Instruction
+	for i := 0; i < 4; i++ {
+		each = append(each, If{
+			Condition: NeI32(AndI32(lanes, ConstI32(1<<i)), ConstI32(0)),
+			Then: []Instruction{AssignVec4F32(res, ReplaceLaneVec4F32(res, i,
+				callHelper(f32Kind{}, h, ExtractLaneVec4F32(x.(Vec4F32), i)).(F32)))},
+		})
+	}
+	return ops{
+		AssignVec4F32(res, v.(Vec4F32)),
+		AssignI32(lanes, Mask4Bits(GtVec4F32(
+			AbsVec4F32(x.(Vec4F32)),
+			ConstVec4F32{reduceLimit, reduceLimit, reduceLimit, reduceLimit},
+		))),
+		If{Condition: NeI32(lanes, ConstI32(0)), Then: each},
+		res,
+	}
+}
+
+func (vec4F32Kind) lt(a, b Instruction) Instruction {
+	return LtVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) gt(a, b Instruction) Instruction {
+	return GtVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) eq(a, b Instruction) Instruction {
+	return EqVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) ne(a, b Instruction) Instruction {
+	return NeVec4F32(a.(Vec4F32), b.(Vec4F32))
+}
+func (vec4F32Kind) and(a, b Instruction) Instruction { return AndMask4(a.(Mask4), b.(Mask4)) }
+func (vec4F32Kind) or(a, b Instruction) Instruction  { return OrMask4(a.(Mask4), b.(Mask4)) }
+func (vec4F32Kind) sel(mask, a, b Instruction) Instruction {
+	return SelectVec4F32(mask.(Mask4), a.(Vec4F32), b.(Vec4F32))
+}
+
+func (vec4F32Kind) bits(a Instruction) Instruction { return Vec4F32Bits(a.(Vec4F32)) }
+func (vec4F32Kind) fromBits(a Instruction) Instruction {
+	return Vec4F32FromBits(a.(Vec4I32))
+}
+func (vec4F32Kind) toInt(a Instruction) Instruction   { return Vec4F32ToVec4I32(a.(Vec4F32)) }
+func (vec4F32Kind) fromInt(a Instruction) Instruction { return Vec4I32ToVec4F32(a.(Vec4I32)) }
+func (vec4F32Kind) ic(v int32) Instruction            { return ConstVec4I32{v, v, v, v} }
+func (vec4F32Kind) iadd(a, b Instruction) Instruction {
+	return AddVec4I32(a.(Vec4I32), b.(Vec4I32))
+}
+func (vec4F32Kind) isub(a, b Instruction) Instruction {
+	return SubVec4I32(a.(Vec4I32), b.(Vec4I32))
+}
+func (vec4F32Kind) iand(a, b Instruction) Instruction {
+	return AndVec4I32(a.(Vec4I32), b.(Vec4I32))
+}
+func (vec4F32Kind) ior(a, b Instruction) Instruction {
+	return OrVec4I32(a.(Vec4I32), b.(Vec4I32))
+}
+func (vec4F32Kind) ixor(a, b Instruction) Instruction {
+	return XorVec4I32(a.(Vec4I32), b.(Vec4I32))
+}
+func (vec4F32Kind) ishl(a Instruction, n int32) Instruction {
+	return ShlVec4I32(a.(Vec4I32), ConstI32(n))
+}
+func (vec4F32Kind) ishrS(a Instruction, n int32) Instruction {
+	return ShrSVec4I32(a.(Vec4I32), ConstI32(n))
+}
+func (vec4F32Kind) ishrU(a Instruction, n int32) Instruction {
+	return ShrUVec4I32(a.(Vec4I32), ConstI32(n))
+}
+func (vec4F32Kind) ine(a, b Instruction) Instruction {
+	return NeVec4I32(a.(Vec4I32), b.(Vec4I32))
+}
+func (vec4F32Kind) ilt(a, b Instruction) Instruction {
+	return LtVec4I32(a.(Vec4I32), b.(Vec4I32))
+}
+
+// poly returns the polynomial with coefficients c, highest
+// degree first, evaluated at z.
+func poly(k numKind, z Instruction, c ...float32) Instruction {
+	p := k.c(c[0])
+	for _, ci := range c[1:] {
+		p = k.add(k.mul(p, z), k.c(ci))
+	}
+	return p
+}
+
+// reduceLimit is the largest magnitude of the arguments
+// reduced by reducePio2. Larger ones are reduced by
+// reducePio2Large.
+const reduceLimit = 4096
+
+// pio2Parts is pi/2 split in parts of 12 bits, so that their
+// products with the quotients of reducePio2, below 2**12, are
+// exact.
+var pio2Parts = [...]float32{0x1.92p+00, 0x1.fb4p-12, 0x1.444p-24, 0x1.68cp-39, 0x1.1a6p-54}
+
+// twoOverPiParts is 2/pi split in parts of 28 bits, so that
+// their products with any F32 are exact in F64.
+var twoOverPiParts = [...]float64{
+	0x1.45f306cp-01, 0x1.c9c882ap-29, 0x1.4fe13a8p-59, 0x1.f47d4dp-86,
+	0x1.bb81b6cp-113, 0x1.4acc9ep-143, 0x1.0e4107cp-170,
+}
+
+// reduction returns the instructions to reduce x to r + lo
+// in [-pi/4, pi/4], such that x = r + lo + q*pi/2, and assign
+// r, lo and the quadrant q. lo is below half an ulp of r.
+type reduction func(k numKind, f Function, x Instruction, r, lo, q numVar) []Instruction
+
+// reducePio2 is the reduction for |x| up to reduceLimit.
+func reducePio2(k numKind, f Function, x Instruction, r, lo, q numVar) []Instruction {
+	n := k.local(f)
+	s := k.local(f)
+	b := k.local(f)
+	e := k.local(f)
+	// sub subtracts n*c from r, adding its rounding error
+	// to e
+	sub := func(c float32) []Instruction {
+		nc := k.mul(n, k.c(c))
+		return []Instruction{
+			assignNum(s, k.sub(r, nc)),
+			assignNum(b, k.sub(s, r)),
+			assignNum(e, k.add(e, k.sub(k.sub(r, k.sub(s, b)), k.add(nc, b)))),
+			assignNum(r, s),
+		}
+	}
+	body := []Instruction{
+		assignNum(n, k.nearest(k.mul(x, k.c(2/math.Pi)))),
+		// exact, as n*pio2Parts[0] is close to x
+		assignNum(r, k.sub(x, k.mul(n, k.c(pio2Parts[0])))),
+		assignNum(e, k.c(0)),
+	}
+	body = append(body, sub(pio2Parts[1])...)
+	body = append(body, sub(pio2Parts[2])...)
+	return append(body,
+		// the last parts are small, and subtracted with the
+		// errors, leaving the rounding error of r in lo
+		assignNum(e, k.sub(k.add(k.mul(n, k.c(pio2Parts[3])), k.mul(n, k.c(pio2Parts[4]))), e)),
+		assignNum(s, k.sub(r, e)),
+		assignNum(lo, k.sub(k.sub(r, s), e)),
+		assignNum(r, s),
+		assignNum(q, k.toInt(n)),
+	)
+}
+
+// reducePio2Large is the reduction for any F32 x. x*2/pi is
+// computed modulo 4 as the sum of the exact products of x
+// with the parts of 2/pi, each split into an integer modulo
+// 4 and a fraction.
+func reducePio2Large(k numKind, f Function, x Instruction, r, lo, q numVar) []Instruction {
+	xd := f.LocalF64()
+	p := f.LocalF64()
+	i := f.LocalF64()
+	frac := f.LocalF64()
+	quad := f.LocalF64()
+	rd := f.LocalF64()
+	body := []Instruction{
+		AssignF64(xd, F32ToF64(x.(F32))),
+		AssignF64(frac, ConstF64(0)),
+		AssignF64(quad, ConstF64(0)),
+	}
+	for _, c := range twoOverPiParts {
+		body = append(body,
+			AssignF64(p, MulF64(xd, ConstF64(c))),
+			AssignF64(i, NearestF64(p)),
+			AssignF64(frac, AddF64(frac, SubF64(p, i))),
+			// i modulo 4, which is exact even if i is
+			// beyond 2**53
+			AssignF64(quad, AddF64(quad, SubF64(i,
+				MulF64(ConstF64(4), FloorF64(MulF64(i, ConstF64(0.25))))))),
+			// keep the fraction in [-0.5, 0.5]
+			AssignF64(i, NearestF64(frac)),
+			AssignF64(frac, SubF64(frac, i)),
+			AssignF64(quad, AddF64(quad, i)),
+		)
+	}
+	return append(body,
+		AssignF64(rd, MulF64(frac, ConstF64(math.Pi/2))),
+		assignNum(r, F64ToF32(rd)),
+		assignNum(lo, F64ToF32(SubF64(rd, F32ToF64(r.(F32))))),
+		assignNum(q, F64ToI32(quad)),
+	)
+}
+
+// sinCos returns sin(x + quadrant*pi/2).
+func sinCos(k numKind, f Function, x Instruction, quadrant int32, reduce reduction) Instruction {
+	r := k.local(f)
+	lo := k.local(f)
+	q := k.localInt(f)
+	z := k.local(f)
+	v := k.local(f)
+	body := ops(reduce(k, f, x, r, lo, q))
+	body = append(body,
+		assignNum(q, k.iadd(q, k.ic(quadrant))),
+		assignNum(z, k.mul(r, r)),
+		// sin(r) in even quadrants, cos(r) in odd, with lo
+		// added to first order
+		assignNum(v, k.sel(
+			k.ine(k.iand(q, k.ic(1)), k.ic(0)),
+			k.add(k.sub(k.sub(
+				k.mul(k.mul(poly(k, z, 2.443315711809948e-5, -1.388731625493765e-3, 4.166664568298827e-2), z), z),
+				k.mul(r, lo)),
+				k.mul(k.c(0.5), z)),
+				k.c(1)),
+			k.add(k.add(k.mul(k.mul(poly(k, z, -1.9515295891e-4, 8.3321608736e-3, -1.6666654611e-1), z), r), lo), r),
+		)),
+		// negated in the last two quadrants
+		k.fromBits(k.ixor(k.bits(v), k.ishl(k.iand(q, k.ic(2)), 30))),
+	)
+	return body
+}
+
+var sinHelper = &mathHelper{
+	name:   "sin",
+	params: 1,
+	body: func(k numKind, f Function, args []Instruction) Instruction {
+		// the reduction loses the sign of -0
+		x := args[0]
+		return k.sel(k.eq(x, k.c(0)), x, k.wrap(sinCos(k, f, x, 0, reducePio2)))
+	},
+	large: func(f Function, x F32) F32 {
+		return opsF32{sinCos(f32Kind{}, f, x, 0, reducePio2Large)}
+	},
+}
+
+var cosHelper = &mathHelper{
+	name:   "cos",
+	params: 1,
+	body: func(k numKind, f Function, args []Instruction) Instruction {
+		// cos(x) = sin(x + pi/2)
+		return sinCos(k, f, args[0], 1, reducePio2)
+	},
+	large: func(f Function, x F32) F32 {
+		return opsF32{sinCos(f32Kind{}, f, x, 1, reducePio2Large)}
+	},
+}
+
+// tan returns tan(x).
+func tan(k numKind, f Function, x Instruction, reduce reduction) Instruction {
+	r := k.local(f)
+	lo := k.local(f)
+	q := k.localInt(f)
+	z := k.local(f)
+	t := k.local(f)
+	body := ops(reduce(k, f, x, r, lo, q))
+	return append(body,
+		assignNum(z, k.mul(r, r)),
+		assignNum(t, k.add(k.add(k.mul(k.mul(poly(k, z,
+			9.38540185543e-3,
+			3.11992232697e-3,
+			2.44301354525e-2,
+			5.34112807005e-2,
+			1.33387994085e-1,
+			3.33331568548e-1,
+		), z), r), lo), r)),
+		// tan(r + pi/2) = -1/tan(r)
+		k.sel(
+			k.ine(k.iand(q, k.ic(1)), k.ic(0)),
+			k.div(k.c(-1), t),
+			t,
+		),
+	)
+}
+
+var tanHelper = &mathHelper{
+	name:   "tan",
+	params: 1,
+	body: func(k numKind, f Function, args []Instruction) Instruction {
+		// the reduction loses the sign of -0
+		x := args[0]
+		return k.sel(k.eq(x, k.c(0)), x, k.wrap(tan(k, f, x, reducePio2)))
+	},
+	large: func(f Function, x F32) F32 {
+		return opsF32{tan(f32Kind{}, f, x, reducePio2Large)}
+	},
+}
+
+var atan2Helper = &mathHelper{
+	name:   "atan2",
+	params: 2,
+	body: func(k numKind, f Function, args []Instruction) Instruction {
+		y, x := args[0], args[1]
+		ax := k.local(f)
+		ay := k.local(f)
+		mx := k.local(f)
+		t := k.local(f)
+		z := k.local(f)
+		c := k.local(f)
+		a := k.local(f)
+		n := k.local(f)
+		const (
+			tanPi8 = 0.41421356237309503
+			// atan(0.5) and pi/4 are split in two parts, with
+			// the low 8 bits of each high part clear so that
+			// sums of the high parts are exact.
+			atanHalfHi = 0.46364593505859375
+			atanHalfLo = 1.6739421653255704e-6
+			pi4Hi      = 0.7853851318359375
+			pi4Lo      = 1.3031561138632242e-5
+		)
+		mid := func() Instruction { return k.gt(t, k.c(tanPi8)) }
+		high := func() Instruction { return k.gt(t, k.c(0.6875)) }
+		// n*pi/4 - (c + a)
+		flip := func(cond Instruction, m float32) Instruction {
+			return ops{
+				assignNum(n, k.sel(cond, k.sub(k.c(m), n), n)),
+				assignNum(c, k.sel(cond, k.neg(c), c)),
+				assignNum(a, k.sel(cond, k.neg(a), a)),
+			}
+		}
+		return ops{
+			assignNum(ax, k.abs(x)),
+			assignNum(ay, k.abs(y)),
+			assignNum(mx, k.max(ax, ay)),
+			// t = min/max is in [0, 1], and 0 if both are 0
+			assignNum(t, k.sel(
+				k.eq(mx, k.c(0)),
+				k.c(0),
+				k.div(k.min(ax, ay), mx),
+			)),
+			// atan(t) = atan(0.5) + atan((2t-1)/(2+t))
+			//         = pi/4 + atan((t-1)/(t+1))
+			// keeps the argument of the polynomial small
+			// where t is above tan(pi/8).
+			assignNum(c, k.sel(high(), k.c(pi4Hi), k.sel(mid(), k.c(atanHalfHi), k.c(0)))),
+			assignNum(a, k.sel(high(), k.c(pi4Lo), k.sel(mid(), k.c(atanHalfLo), k.c(0)))),
+			assignNum(t, k.sel(
+				high(),
+				k.div(k.sub(t, k.c(1)), k.add(t, k.c(1))),
+				k.sel(
+					mid(),
+					k.div(k.sub(k.add(t, t), k.c(1)), k.add(t, k.c(2))),
+					t,
+				),
+			)),
+			assignNum(z, k.mul(t, t)),
+			assignNum(a, k.add(a, k.add(k.mul(k.mul(poly(k, z,
+				8.05374449538e-2,
+				-1.38776856032e-1,
+				1.99777106478e-1,
+				-3.33329491539e-1,
+			), z), t), t))),
+			// reflect into the octant and quadrant of (x, y),
+			// so that the result is n*pi/4 + c + a
+			assignNum(n, k.c(0)),
+			flip(k.gt(ay, ax), 2),
+			flip(k.ilt(k.bits(x), k.ic(0)), 4),
+			// the high parts are summed exactly, so the
+			// result is only rounded once more
+			k.copysign(k.add(
+				k.add(k.mul(n, k.c(pi4Hi)), c),
+				k.add(k.mul(n, k.c(pi4Lo)), a),
+			), y),
+		}
+	},
+}
+
+var expHelper = &mathHelper{
+	name:   "exp",
+	params: 1,
+	body: func(k numKind, f Function, args []Instruction) Instruction {
+		const (
+			c1 = 0.693359375
+			c2 = -2.12194440e-4
+		)
+		x := k.local(f)
+		n := k.local(f)
+		r := k.local(f)
+		ni := k.localInt(f)
+		half := k.localInt(f)
+		// 2**i for i in the normal exponent range
+		pow2 := func(i Instruction) Instruction {
+			return k.fromBits(k.ishl(k.iadd(i, k.ic(127)), 23))
+		}
+		return ops{
+			// beyond this range the result is 0 or +Inf
+			assignNum(x, k.min(k.max(args[0], k.c(-104)), k.c(89))),
+			assignNum(n, k.nearest(k.mul(x, k.c(math.Log2E)))),
+			// r = x - n*ln(2), with ln(2) split in two parts
+			assignNum(r, k.sub(k.sub(x, k.mul(n, k.c(c1))), k.mul(n, k.c(c2)))),
+			assignNum(ni, k.toInt(n)),
+			// 2**n is applied in two steps, since n may be
+			// outside of the normal exponent range.
+			assignNum(half, k.ishrS(ni, 1)),
+			k.mul(k.mul(
+				k.add(k.add(
+					k.mul(poly(k, r,
+						1.9875691500e-4,
+						1.3981999507e-3,
+						8.3334519073e-3,
+						4.1665795894e-2,
+						1.6666665459e-1,
+						5.0000001201e-1,
+					), k.mul(r, r)),
+					r),
+					k.c(1)),
+				pow2(half)),
+				pow2(k.isub(ni, half))),
+		}
+	},
+}
+
+var logHelper = &mathHelper{
+	name:   "log",
+	params: 1,
+	body: func(k numKind, f Function, args []Instruction) Instruction {
+		const (
+			c1         = 0.693359375
+			c2         = -2.12194440e-4
+			minNormal  = 1.17549435e-38
+			sqrtHalf   = 0.70710678118654752
+			subnormalN = 25
+		)
+		x := args[0]
+		b := k.localInt(f)
+		e := k.local(f)
+		m := k.local(f)
+		z := k.local(f)
+		y := k.local(f)
+		tiny := func() Instruction { return k.lt(x, k.c(minNormal)) }
+		small := func() Instruction { return k.lt(m, k.c(sqrtHalf)) }
+		return ops{
+			// split x into m in [0.5, 1) and the exponent e,
+			// scaling subnormals into the normal range first.
+			assignNum(b, k.bits(k.sel(tiny(), k.mul(x, k.c(1<<subnormalN)), x))),
+			assignNum(e, k.sub(
+				k.fromInt(k.isub(k.iand(k.ishrU(b, 23), k.ic(0xFF)), k.ic(126))),
+				k.sel(tiny(), k.c(subnormalN), k.c(0)),
+			)),
+			assignNum(m, k.fromBits(k.ior(k.iand(b, k.ic(0x007FFFFF)), k.ic(0x3F000000)))),
+			// move m into [sqrt(0.5), sqrt(2)), and subtract 1
+			assignNum(e, k.sub(e, k.sel(small(), k.c(1), k.c(0)))),
+			assignNum(m, k.sel(small(), k.sub(k.add(m, m), k.c(1)), k.sub(m, k.c(1)))),
+			assignNum(z, k.mul(m, m)),
+			assignNum(y, k.mul(k.mul(poly(k, m,
+				7.0376836292e-2,
+				-1.1514610310e-1,
+				1.1676998740e-1,
+				-1.2420140846e-1,
+				1.4249322787e-1,
+				-1.6668057665e-1,
+				2.0000714765e-1,
+				-2.4999993993e-1,
+				3.3333331174e-1,
+			), m), z)),
+			assignNum(y, k.add(y, k.mul(e, k.c(c2)))),
+			assignNum(y, k.sub(y, k.mul(k.c(0.5), z))),
+			assignNum(y, k.add(k.add(m, y), k.mul(e, k.c(c1)))),
+			k.sel(
+				k.eq(x, k.c(float32(math.Inf(1)))),
+				x,
+				k.sel(
+					k.gt(x, k.c(0)),
+					y,
+					k.sel(
+						k.eq(x, k.c(0)),
+						k.c(float32(math.Inf(-1))),
+						k.c(float32(math.NaN())),
+					),
+				),
+			),
+		}
+	},
+}
+
+var powHelper = &mathHelper{
+	name:   "pow",
+	params: 2,
+	body: func(k numKind, f Function, args []Instruction) Instruction {
+		x, y := args[0], args[1]
+		r := k.local(f)
+		half := k.local(f)
+		isInt := func() Instruction { return k.eq(k.trunc(y), y) }
+		return ops{
+			assignNum(r, callHelper(k, expHelper,
+				k.mul(y, callHelper(k, logHelper, k.abs(x))))),
+			assignNum(half, k.mul(y, k.c(0.5))),
+			// negative x is only defined for integer y, and
+			// negates the result for odd y.
+			assignNum(r, k.sel(
+				k.lt(x, k.c(0)),
+				k.sel(
+					isInt(),
+					k.sel(k.ne(k.trunc(half), half), k.neg(r), r),
+					k.c(float32(math.NaN())),
+				),
+				r,
+			)),
+			// (-1)**±Inf is 1, where exp(±Inf*0) is NaN
+			k.sel(
+				k.or(
+					k.or(k.eq(y, k.c(0)), k.eq(x, k.c(1))),
+					k.and(k.eq(k.abs(x), k.c(1)), k.eq(k.abs(y), k.c(float32(math.Inf(1))))),
+				),
+				k.c(1),
+				r,
+			),
+		}
+	},
+}
+
+var tanhHelper = &mathHelper{
+	name:   "tanh",
+	params: 1,
+	body: func(k numKind, f Function, args []Instruction) Instruction {
+		x := args[0]
+		ax := k.local(f)
+		z := k.local(f)
+		s := k.local(f)
+		return ops{
+			assignNum(ax, k.abs(x)),
+			assignNum(z, k.mul(x, x)),
+			// tanh(|x|) = 1 - 2/(exp(2|x|) + 1)
+			assignNum(s, callHelper(k, expHelper, k.mul(k.c(2), ax))),
+			k.sel(
+				k.lt(ax, k.c(0.625)),
+				// tanh is odd, and keeps the sign of -0
+				k.copysign(k.add(k.mul(k.mul(poly(k, z,
+					-5.70498872745e-3,
+					2.06390887954e-2,
+					-5.37397155531e-2,
+					1.33314422036e-1,
+					-3.33332819422e-1,
+				), z), x), x), x),
+				k.copysign(k.sub(k.c(1), k.div(k.c(2), k.add(s, k.c(1)))), x),
+			),
+		}
+	},
+}
@@ -1,15 +1,73 @@
 package wasm
 
-import "io"
+import (
+	"fmt"
+	"io"
+)
 
-type memImport struct{}
+// PageSize is the size in bytes of a page of linear memory.
+const PageSize = 65536
 
-func (imp memImport) writeImportDesc(m *Module, out io.Writer) error {
-	out.Write([]byte{0x02, 0x00})
-	writeu32(1, out)
+// Memory is the linear memory of a Module. A Module has at most
+// one Memory, which is either defined with Module.Memory or
+// imported with Module.ImportMemory. Memory may be exported.
+type Memory interface {
+	Exportable
+	isMemory()
+}
+
+type memory struct {
+	min, max uint32
+	hasMax   bool
+
+	// set if the memory is imported
+	imported bool
+	mod      string
+	name     string
+}
+
+func (mem *memory) isMemory() {}
+
+func (mem *memory) isExportable() {}
+
+func (mem *memory) encodeLimits(out io.Writer) {
+	if mem.hasMax {
+		out.Write([]byte{0x01})
+		writeu32(mem.min, out)
+		writeu32(mem.max, out)
+		return
+	}
+	out.Write([]byte{0x00})
+	writeu32(mem.min, out)
+}
+
+func (mem *memory) writeImportDesc(m *Module, out io.Writer) error {
+	out.Write([]byte{0x02})
+	mem.encodeLimits(out)
 	return nil
 }
 
+func newMemory(minPages, maxPages uint32) *memory {
+	if maxPages != 0 && maxPages < minPages {
+		panic(fmt.Errorf("memory maximum %d is less than minimum %d", maxPages, minPages))
+	}
+	return &memory{
+		min:    minPages,
+		max:    maxPages,
+		hasMax: maxPages != 0,
+	}
+}
+
+// defaultMemory is imported as wasm.memory when the module
+// accesses memory without declaring it.
+func defaultMemory() *memory {
+	mem := newMemory(1, 0)
+	mem.imported = true
+	mem.mod = "wasm"
+	mem.name = "memory"
+	return mem
+}
+
 func loadF32(offset I32) F32 {
 	return opsF32{
 		offset,
@@ -18,3 +76,32 @@ func loadF32(offset I32) F32 {
 		u32(0), // static offset
 	}
 }
+
+func storeF32(offset I32, v F32) Instruction {
+	return ops{
+		offset,
+		v,
+		op(0x38),
+		u32(0), // static align
+		u32(0), // static offset
+	}
+}
+
+func loadVec4F32(offset I32) Vec4F32 {
+	return opsVec4F32{
+		offset,
+		loadV128,
+		u32(0), // static align
+		u32(0), // static offset
+	}
+}
+
+func storeVec4F32(offset I32, v Vec4F32) Instruction {
+	return ops{
+		offset,
+		v,
+		storeV128,
+		u32(0), // static align
+		u32(0), // static offset
+	}
+}
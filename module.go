@@ -19,6 +19,10 @@ type Module struct {
 	functionTypeMap map[*function]int
 	functionTypes   []functype
 
+	// helper functions, added the first time
+	// they are called.
+	helpers map[string]*function
+
 	// exports state
 	exports [][]byte
 
@@ -31,7 +35,15 @@ type Module struct {
 	globalImportCnt   uint32
 	functionImportCnt uint32
 
+	// memory
+	memory        *memory
+	data          []*dataSegment
 	doesUseMemory bool
+
+	// name section
+	emitNames  bool
+	moduleName string
+	names      map[Exportable]string
 }
 
 // An Exportable type can be exported from the module.
@@ -39,37 +51,140 @@ type Module struct {
 // method, and can be created with the following functions:
 //
 // Module.GlobalF32
+// Module.GlobalI32
+// Module.GlobalF64
+// Module.GlobalI64
+// Module.GlobalVec4F32
 // Module.Function
+// Module.Memory
 type Exportable interface {
 	isExportable()
 }
 
+func (m *Module) addGlobal(g global) {
+	g.setGlobalIndex(m.globalImportCnt + uint32(len(m.globals)))
+	m.globals = append(m.globals, g)
+}
+
 // GlobalF32 creates a global, mutable F32 object.
 func (m *Module) GlobalF32(init float32) GlobalF32 {
 	g := new(varF32)
-	g.idx = uint32(len(m.globals))
 	g.init = init
-	m.globals = append(m.globals, g)
+	m.addGlobal(g)
+	return g
+}
+
+// GlobalI32 creates a global, mutable I32 object.
+func (m *Module) GlobalI32(init int32) GlobalI32 {
+	g := new(varI32)
+	g.init = init
+	m.addGlobal(g)
+	return g
+}
+
+// GlobalF64 creates a global, mutable F64 object.
+func (m *Module) GlobalF64(init float64) GlobalF64 {
+	g := new(varF64)
+	g.init = init
+	m.addGlobal(g)
+	return g
+}
+
+// GlobalI64 creates a global, mutable I64 object.
+func (m *Module) GlobalI64(init int64) GlobalI64 {
+	g := new(varI64)
+	g.init = init
+	m.addGlobal(g)
 	return g
 }
 
 // GlobalVec4F32 creates a global, mutable Vec4F32 object.
 func (m *Module) GlobalVec4F32(init [4]float32) GlobalVec4F32 {
 	g := new(vec4F32)
-	g.idx = uint32(len(m.globals))
 	g.init = init
-	m.globals = append(m.globals, g)
+	m.addGlobal(g)
 	return g
 }
 
-// Function instantiates a function.
+// Function instantiates a function that takes no parameters
+// and returns no results.
 func (m *Module) Function() Function {
+	return m.TypedFunction(Signature{})
+}
+
+// TypedFunction instantiates a function with the parameters and
+// results declared by sig. Parameters are accessed inside the
+// function body with the Function's Param methods.
+func (m *Module) TypedFunction(sig Signature) Function {
+	sig.functype() // panic early on invalid types
 	f := new(function)
-	f.idx = uint32(len(m.functions))
+	f.m = m
+	f.idx = m.functionImportCnt + uint32(len(m.functions))
+	f.sig = sig
 	m.functions = append(m.functions, f)
 	return f
 }
 
+// Memory defines the linear memory of the module, with an initial
+// size of minPages and a maximum size of maxPages, in units of
+// PageSize bytes. If maxPages is zero, the memory has no maximum.
+// A module may only have one memory; if memory has already been
+// defined or imported, Memory panics.
+//
+// If the module uses memory without defining or importing it,
+// a memory of at least one page, and large enough to hold any
+// data segments, is imported as "wasm.memory".
+func (m *Module) Memory(minPages, maxPages uint32) Memory {
+	mem := newMemory(minPages, maxPages)
+	m.setMemory(mem)
+	return mem
+}
+
+// ImportMemory imports the linear memory of the module as mod.name,
+// with the limits described by minPages and maxPages as in
+// Module.Memory. If memory has already been defined or imported,
+// ImportMemory panics.
+func (m *Module) ImportMemory(mod, name string, minPages, maxPages uint32) Memory {
+	key := [2]string{mod, name}
+	if _, ok := m.imports[key]; ok {
+		panic(fmt.Errorf("duplicate import %q", key))
+	}
+	mem := newMemory(minPages, maxPages)
+	mem.imported = true
+	mem.mod = mod
+	mem.name = name
+	m.setMemory(mem)
+	if m.imports == nil {
+		m.imports = make(map[[2]string]importable)
+		m.importIndex = make(map[[2]string]uint32)
+	}
+	// the memory is written after the other imports, so it
+	// is only registered to detect duplicates, without an
+	// index
+	m.imports[key] = mem
+	return mem
+}
+
+func (m *Module) setMemory(mem *memory) {
+	if m.memory != nil {
+		panic(fmt.Errorf("module already has a memory"))
+	}
+	m.memory = mem
+}
+
+// usedMemory returns the memory of the module, or nil if
+// it has none.
+func (m *Module) usedMemory() *memory {
+	if m.memory == nil && m.doesUseMemory {
+		mem := defaultMemory()
+		if p := m.dataPages(); p > mem.min {
+			mem.min = p
+		}
+		return mem
+	}
+	return m.memory
+}
+
 // Export exports v as name. If a previous Exportable has already
 // been exported as name, it will be replaced.
 func (m *Module) Export(name string, v Exportable) {
@@ -117,16 +232,49 @@ func (m *Module) ImportF32(mod, name string) MutableF32 {
 	return out
 }
 
+// ImportI32 imports a global I32 value.
+// If symbol has already been imported, ImportI32 panics.
+func (m *Module) ImportI32(mod, name string) MutableI32 {
+	out := new(varI32)
+	m.addImport(mod, name, out)
+	return out
+}
+
+// ImportF64 imports a global F64 value.
+// If symbol has already been imported, ImportF64 panics.
+func (m *Module) ImportF64(mod, name string) MutableF64 {
+	out := new(varF64)
+	m.addImport(mod, name, out)
+	return out
+}
+
+// ImportI64 imports a global I64 value.
+// If symbol has already been imported, ImportI64 panics.
+func (m *Module) ImportI64(mod, name string) MutableI64 {
+	out := new(varI64)
+	m.addImport(mod, name, out)
+	return out
+}
+
+// ImportVec4F32 imports a global Vec4F32 value.
+// If symbol has already been imported, ImportVec4F32 panics.
+func (m *Module) ImportVec4F32(mod, name string) MutableVec4F32 {
+	out := new(vec4F32)
+	m.addImport(mod, name, out)
+	return out
+}
+
 // ImportSliceF32 imports a slice of float32 values located
 // in memory. This requires memory to be provided to the wasm
-// module.
+// module. Elements of the slice may be assigned, in which case
+// the host will observe the stored values in memory.
 // SliceF32 is an i64 value that is interpreted as two u32 offsets,
 // defining the length of the slice (number of float32 elements),
 // and the byte-offset in the memory section.
 //
 // The lower-order bits are the offset while the higher order
 // bits are the length.
-func (m *Module) ImportSliceF32(name string) SliceF32 {
+func (m *Module) ImportSliceF32(name string) MutableSliceF32 {
 	out := new(sliceF32)
 	m.addImport("_sf32", name, out)
 	m.doesUseMemory = true
@@ -134,17 +282,44 @@ func (m *Module) ImportSliceF32(name string) SliceF32 {
 }
 
 // ImportFunction returns a handle to a function imported from
-// the runtime.
+// the runtime that takes no parameters and returns no results.
 func (m *Module) ImportFunction(mod, name string) ImportedFunction {
+	return m.ImportTypedFunction(mod, name, Signature{})
+}
+
+// ImportTypedFunction returns a handle to a function imported from
+// the runtime with the parameters and results declared by sig.
+func (m *Module) ImportTypedFunction(mod, name string, sig Signature) ImportedFunction {
+	sig.functype() // panic early on invalid types
 	out := new(function)
+	out.m = m
+	out.sig = sig
 	m.addImport(mod, name, out)
 	return out
 }
 
 // Compile compiles the module into binary WASM format.
+// The compiled module is validated before it is returned,
+// and errors name the function and instruction at fault.
 func (m *Module) Compile() ([]byte, error) {
 
+	// function bodies are encoded first, since they
+	// may add helper functions to the module.
+	code, err := m.encodeFunctions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write code section: %s", err)
+	}
+
 	m.functionTypeMap = make(map[*function]int)
+	m.functionTypes = nil
+	for _, k := range m.importKeys() {
+		if f, ok := m.imports[k].(*function); ok {
+			m.addFunction(f)
+		}
+	}
+	for _, f := range m.functions {
+		m.addFunction(f)
+	}
 
 	// collect exports
 	if err := m.collectExports(); err != nil {
@@ -174,6 +349,11 @@ func (m *Module) Compile() ([]byte, error) {
 		return nil, fmt.Errorf("failed to write function section: %s", err)
 	}
 
+	// (5) memory section
+	if err := m.writeMemorySection(); err != nil {
+		return nil, fmt.Errorf("failed to write memory section: %s", err)
+	}
+
 	// (6) global section
 	if err := m.writeGlobalSection(); err != nil {
 		return nil, fmt.Errorf("failed to write global section: %s", err)
@@ -185,12 +365,25 @@ func (m *Module) Compile() ([]byte, error) {
 	}
 
 	// (10) code section
-	if err := m.writeCodeSection(); err != nil {
+	if err := m.writeCodeSection(code); err != nil {
 		return nil, fmt.Errorf("failed to write code section: %s", err)
 	}
 
+	// (11) data section
+	if err := m.writeDataSection(); err != nil {
+		return nil, fmt.Errorf("failed to write data section: %s", err)
+	}
+
+	// (0) name section
+	if err := m.writeNameSection(); err != nil {
+		return nil, fmt.Errorf("failed to write name section: %s", err)
+	}
+
 	out := m.buf.Bytes()
 	m.buf = bytes.Buffer{}
+	if err := m.validate(out); err != nil {
+		return nil, err
+	}
 	return out, nil
 }
 
@@ -202,7 +395,7 @@ func (m *Module) addFunction(f *function) {
 	for i, ft := range m.functionTypes {
 		if ft.equals(t) {
 			m.functionTypeMap[f] = i
-			break
+			return
 		}
 	}
 	m.functionTypeMap[f] = len(m.functionTypes)
@@ -228,10 +421,27 @@ func (m *Module) collectExports() error {
 		case *function:
 			ei = v.idx
 			eid = 0x0
-			m.addFunction(v)
 		case *varF32:
 			ei = v.idx
 			eid = 0x03
+		case *varI32:
+			ei = v.idx
+			eid = 0x03
+		case *varF64:
+			ei = v.idx
+			eid = 0x03
+		case *varI64:
+			ei = v.idx
+			eid = 0x03
+		case *vec4F32:
+			ei = v.idx
+			eid = 0x03
+		case *memory:
+			if v != m.memory {
+				return fmt.Errorf("memory exported as %q does not belong to the module", name)
+			}
+			ei = 0
+			eid = 0x02
 		default:
 			return fmt.Errorf("%v is unsupported export type", v)
 		}
@@ -264,30 +474,29 @@ func (m *Module) writeTypeSection() error {
 }
 
 func (m *Module) writeImportSection() error {
-	if len(m.imports) == 0 {
-		return nil
+	imports := m.importKeys()
+	descs := make([]importable, len(imports))
+	for i, k := range imports {
+		descs[i] = m.imports[k]
 	}
-	buf := new(bytes.Buffer)
-	imports := make([][2]string, len(m.imports))
-	for k := range m.imports {
-		imports[m.importIndex[k]] = k
+	if mem := m.usedMemory(); mem != nil && mem.imported {
+		imports = append(imports, [2]string{mem.mod, mem.name})
+		descs = append(descs, mem)
 	}
-	if m.doesUseMemory {
-		key := [2]string{"wasm", "memory"}
-		m.imports[key] = memImport{}
-		imports = append(imports, key)
+	if len(imports) == 0 {
+		return nil
 	}
+	buf := new(bytes.Buffer)
 	// vec(import)
 	writeu32(uint32(len(imports)), buf)
-	for _, imp := range imports {
+	for i, imp := range imports {
 		// module
 		writeu32(uint32(len(imp[0])), buf)
 		buf.WriteString(imp[0])
 		// name
 		writeu32(uint32(len(imp[1])), buf)
 		buf.WriteString(imp[1])
-		g := m.imports[imp]
-		if err := g.writeImportDesc(m, buf); err != nil {
+		if err := descs[i].writeImportDesc(m, buf); err != nil {
 			return fmt.Errorf("failed to write import %s.%s: %s", imp[0], imp[1], err)
 		}
 	}
@@ -297,6 +506,16 @@ func (m *Module) writeImportSection() error {
 	return nil
 }
 
+// importKeys returns the keys of m.imports in the order
+// they were imported.
+func (m *Module) importKeys() [][2]string {
+	keys := make([][2]string, len(m.importIndex))
+	for k, i := range m.importIndex {
+		keys[i] = k
+	}
+	return keys
+}
+
 func (m *Module) writeFunctionSection() error {
 	if len(m.functions) == 0 {
 		return nil
@@ -317,6 +536,21 @@ func (m *Module) writeFunctionSection() error {
 	return nil
 }
 
+func (m *Module) writeMemorySection() error {
+	mem := m.usedMemory()
+	if mem == nil || mem.imported {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	writeu32(1, buf)
+	mem.encodeLimits(buf)
+
+	m.buf.WriteByte(0x05)
+	writeu32(uint32(buf.Len()), &m.buf)
+	m.buf.Write(buf.Bytes())
+	return nil
+}
+
 func (m *Module) writeGlobalSection() error {
 	if len(m.globals) == 0 {
 		return nil
@@ -328,6 +562,12 @@ func (m *Module) writeGlobalSection() error {
 		switch v := v.(type) {
 		case *varF32:
 			err = m.writeF32Global(v, buf)
+		case *varI32:
+			err = m.writeI32Global(v, buf)
+		case *varF64:
+			err = m.writeF64Global(v, buf)
+		case *varI64:
+			err = m.writeI64Global(v, buf)
 		case *vec4F32:
 			err = m.writeVec4F32Global(v, buf)
 		default:
@@ -361,11 +601,63 @@ func (m *Module) writeF32Global(v *varF32, out io.Writer) error {
 	return nil
 }
 
+func (m *Module) writeI32Global(v *varI32, out io.Writer) error {
+	err := globaltype{
+		valuetype: valuetype{
+			numtype: i32,
+		},
+		mutable: true,
+	}.encode(out)
+	if err != nil {
+		return err
+	}
+	if err := ConstI32(v.init).write(instCtx{Writer: out}); err != nil {
+		return err
+	}
+	out.Write([]byte{0x0B}) // end expression
+	return nil
+}
+
+func (m *Module) writeF64Global(v *varF64, out io.Writer) error {
+	err := globaltype{
+		valuetype: valuetype{
+			numtype: f64,
+		},
+		mutable: true,
+	}.encode(out)
+	if err != nil {
+		return err
+	}
+	if err := ConstF64(v.init).write(instCtx{Writer: out}); err != nil {
+		return err
+	}
+	out.Write([]byte{0x0B}) // end expression
+	return nil
+}
+
+func (m *Module) writeI64Global(v *varI64, out io.Writer) error {
+	err := globaltype{
+		valuetype: valuetype{
+			numtype: i64,
+		},
+		mutable: true,
+	}.encode(out)
+	if err != nil {
+		return err
+	}
+	if err := ConstI64(v.init).write(instCtx{Writer: out}); err != nil {
+		return err
+	}
+	out.Write([]byte{0x0B}) // end expression
+	return nil
+}
+
 func (m *Module) writeVec4F32Global(v *vec4F32, out io.Writer) error {
 	err := globaltype{
 		valuetype: valuetype{
 			vectype: true,
 		},
+		mutable: true,
 	}.encode(out)
 	if err != nil {
 		return err
@@ -393,16 +685,28 @@ func (m *Module) writeExportSection() error {
 	return nil
 }
 
-func (m *Module) writeCodeSection() error {
-	if len(m.functions) == 0 {
+// encodeFunctions encodes the body of each function,
+// including any helper functions added while encoding.
+func (m *Module) encodeFunctions() ([][]byte, error) {
+	var code [][]byte
+	for i := 0; i < len(m.functions); i++ {
+		buf := new(bytes.Buffer)
+		if err := m.functions[i].encode(m, buf); err != nil {
+			return nil, fmt.Errorf("%s: %s", m.describe(m.functions[i]), err)
+		}
+		code = append(code, buf.Bytes())
+	}
+	return code, nil
+}
+
+func (m *Module) writeCodeSection(code [][]byte) error {
+	if len(code) == 0 {
 		return nil
 	}
 	buf := new(bytes.Buffer)
-	writeu32(uint32(len(m.functions)), buf)
-	for _, f := range m.functions {
-		if err := f.encode(buf); err != nil {
-			return err
-		}
+	writeu32(uint32(len(code)), buf)
+	for _, c := range code {
+		buf.Write(c)
 	}
 
 	m.buf.WriteByte(10)
@@ -410,3 +714,19 @@ func (m *Module) writeCodeSection() error {
 	m.buf.Write(buf.Bytes())
 	return nil
 }
+
+// helper returns the helper function with the given name,
+// adding it to the module with the signature sig and the
+// instructions returned by body if it does not exist yet.
+func (m *Module) helper(name string, sig Signature, body func(f Function) []Instruction) *function {
+	if f, ok := m.helpers[name]; ok {
+		return f
+	}
+	if m.helpers == nil {
+		m.helpers = make(map[string]*function)
+	}
+	f := m.TypedFunction(sig).(*function)
+	m.helpers[name] = f
+	f.Body(body(f)...)
+	return f
+}
@@ -0,0 +1,231 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// EmitNames makes Compile write a name section, which
+// debuggers and runtimes use to name the module, functions,
+// locals and globals, for example in stack traces. The module
+// is named module, unless it is empty.
+//
+// Values named with Module.Name and Function.NameLocal keep
+// their names. Other functions and globals are named after
+// their first export in sorted order, or their import as
+// "module.name". Math helpers are named after the function
+// they compute, such as "cosF32".
+func (m *Module) EmitNames(module string) {
+	m.emitNames = true
+	m.moduleName = module
+}
+
+// Name names the Function or global v in the name section
+// written when EmitNames has been called. Name panics if v
+// is a Memory.
+func (m *Module) Name(name string, v Exportable) {
+	if _, ok := v.(*memory); ok {
+		panic(fmt.Errorf("memory cannot be named"))
+	}
+	if m.names == nil {
+		m.names = make(map[Exportable]string)
+	}
+	m.names[v] = name
+}
+
+// nameMap maps function, global or local indices to names.
+type nameMap map[uint32]string
+
+// encode writes the names sorted by index.
+func (nm nameMap) encode(out io.Writer) {
+	idx := make([]uint32, 0, len(nm))
+	for i := range nm {
+		idx = append(idx, i)
+	}
+	sort.Slice(idx, func(a, b int) bool { return idx[a] < idx[b] })
+	writeu32(uint32(len(idx)), out)
+	for _, i := range idx {
+		writeu32(i, out)
+		writeName(nm[i], out)
+	}
+}
+
+func writeName(name string, out io.Writer) {
+	writeu32(uint32(len(name)), out)
+	io.WriteString(out, name)
+}
+
+// defaultNames returns the names of the exported and
+// imported values, and of the helpers.
+func (m *Module) defaultNames() map[Exportable]string {
+	names := make(map[Exportable]string)
+	exportNames := make([]string, 0, len(m.exportNames))
+	for name := range m.exportNames {
+		exportNames = append(exportNames, name)
+	}
+	sort.Strings(exportNames)
+	for _, name := range exportNames {
+		if v := m.exportNames[name]; names[v] == "" {
+			names[v] = name
+		}
+	}
+	for _, k := range m.importKeys() {
+		if v, ok := m.imports[k].(Exportable); ok && names[v] == "" {
+			names[v] = k[0] + "." + k[1]
+		}
+	}
+	for name, f := range m.helpers {
+		if names[f] == "" {
+			names[f] = name
+		}
+	}
+	for v, name := range m.names {
+		names[v] = name
+	}
+	return names
+}
+
+func (m *Module) writeNameSection() error {
+	if !m.emitNames {
+		return nil
+	}
+	names := m.defaultNames()
+	funcs := make(nameMap)
+	globals := make(nameMap)
+	locals := make(map[uint32]nameMap)
+	for v, name := range names {
+		switch v := v.(type) {
+		case *function:
+			funcs[v.idx] = name
+		case global:
+			globals[v.globalIndex()] = name
+		case *memory:
+			// memories may be exported, but are not named
+		default:
+			return fmt.Errorf("%v cannot be named", v)
+		}
+	}
+	for _, f := range m.functions {
+		if len(f.localNames) > 0 {
+			locals[f.idx] = f.localNames
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	writeName("name", buf)
+	subsection := func(id byte, write func(out io.Writer)) {
+		sub := new(bytes.Buffer)
+		write(sub)
+		buf.WriteByte(id)
+		writeu32(uint32(sub.Len()), buf)
+		buf.Write(sub.Bytes())
+	}
+
+	// (0) module name
+	if m.moduleName != "" {
+		subsection(0, func(out io.Writer) {
+			writeName(m.moduleName, out)
+		})
+	}
+
+	// (1) function names
+	if len(funcs) > 0 {
+		subsection(1, funcs.encode)
+	}
+
+	// (2) local names
+	if len(locals) > 0 {
+		subsection(2, func(out io.Writer) {
+			idx := make([]uint32, 0, len(locals))
+			for i := range locals {
+				idx = append(idx, i)
+			}
+			sort.Slice(idx, func(a, b int) bool { return idx[a] < idx[b] })
+			writeu32(uint32(len(idx)), out)
+			for _, i := range idx {
+				writeu32(i, out)
+				locals[i].encode(out)
+			}
+		})
+	}
+
+	// (7) global names
+	if len(globals) > 0 {
+		subsection(7, globals.encode)
+	}
+
+	m.buf.WriteByte(0)
+	writeu32(uint32(buf.Len()), &m.buf)
+	m.buf.Write(buf.Bytes())
+	return nil
+}
+
+// describe describes the function f in errors.
+func (m *Module) describe(f *function) string {
+	return describeFunc(f.idx, m.defaultNames()[f])
+}
+
+func describeFunc(idx uint32, name string) string {
+	if name == "" {
+		return fmt.Sprintf("function %d", idx)
+	}
+	return fmt.Sprintf("function %d %q", idx, name)
+}
+
+// names returns the names of the functions and globals of
+// b, taken from its name section, exports or imports in that
+// order of preference. Unnamed values have empty names.
+func (b *Binary) names() (funcs, globals []string) {
+	var section nameSection
+	for _, c := range b.Custom {
+		if c.Name == "name" {
+			// a malformed name section is ignored, as
+			// by runtimes
+			section, _ = decodeNames(c.Data)
+		}
+	}
+	for _, imp := range b.Imports {
+		switch imp.Kind {
+		case ExternFunction:
+			funcs = append(funcs, "")
+		case ExternGlobal:
+			globals = append(globals, "")
+		}
+	}
+	funcs = append(funcs, make([]string, len(b.Functions))...)
+	globals = append(globals, make([]string, len(b.Globals))...)
+
+	name := func(names []string, idx uint32, s string) {
+		if int(idx) < len(names) && names[idx] == "" {
+			names[idx] = s
+		}
+	}
+	for idx, s := range section.funcs {
+		name(funcs, idx, s)
+	}
+	for idx, s := range section.globals {
+		name(globals, idx, s)
+	}
+	for _, e := range b.Exports {
+		switch e.Kind {
+		case ExternFunction:
+			name(funcs, e.Index, e.Name)
+		case ExternGlobal:
+			name(globals, e.Index, e.Name)
+		}
+	}
+	var fi, gi uint32
+	for _, imp := range b.Imports {
+		switch imp.Kind {
+		case ExternFunction:
+			name(funcs, fi, imp.Module+"."+imp.Name)
+			fi++
+		case ExternGlobal:
+			name(globals, gi, imp.Module+"."+imp.Name)
+			gi++
+		}
+	}
+	return funcs, globals
+}
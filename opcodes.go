@@ -0,0 +1,286 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Opcode identifies a decoded instruction. Prefixed
+// instructions are the prefix byte followed by the
+// sub-opcode, so that f32x4.add is 0xFDE4.
+type Opcode uint16
+
+// prefixes of multi-byte opcodes
+const (
+	prefixMisc Opcode = 0xFC00
+	prefixSIMD Opcode = 0xFD00
+)
+
+// String returns the text format name of op.
+func (op Opcode) String() string {
+	if info, ok := opcodes[op]; ok {
+		return info.name
+	}
+	if op > 0xFF {
+		return fmt.Sprintf("%#x %d", byte(op>>8), byte(op))
+	}
+	return fmt.Sprintf("%#02x", uint16(op))
+}
+
+// immediate is the kind of immediate operands that
+// follow an opcode.
+type immediate byte
+
+const (
+	immNone immediate = iota
+	immBlock
+	immLabel
+	immLabels
+	immFunc
+	immLocal
+	immGlobal
+	immMemarg
+	immMemory
+	immI32
+	immI64
+	immF32
+	immF64
+	immSelect
+	immV128
+	immShuffle
+	immLane
+	immMemargLane
+)
+
+// opInfo describes an opcode. Instructions with a fixed
+// stack effect pop params and push results; the effect of the
+// others depends on their immediates or context.
+type opInfo struct {
+	name    string
+	imm     immediate
+	fixed   bool
+	params  []Type
+	results []Type
+}
+
+// opcodes holds every instruction that can be decoded.
+var opcodes = make(map[Opcode]opInfo)
+
+// sigTypes maps the letters used by seq to types.
+var sigTypes = map[rune]Type{
+	'i': TypeI32,
+	'I': TypeI64,
+	'f': TypeF32,
+	'F': TypeF64,
+	'v': TypeVec4F32,
+}
+
+// seq adds consecutive opcodes starting at first, all with
+// immediates imm. Empty names are skipped. sig is empty, or
+// the fixed stack effect of the opcodes as the letters of
+// sigTypes for the params and results separated by a colon,
+// so that "ff:i" pops two F32 values and pushes an I32.
+func seq(first Opcode, imm immediate, sig string, names ...string) {
+	info := opInfo{imm: imm, fixed: sig != ""}
+	if info.fixed {
+		types := func(s string) []Type {
+			var out []Type
+			for _, c := range s {
+				out = append(out, sigTypes[c])
+			}
+			return out
+		}
+		parts := strings.SplitN(sig, ":", 2)
+		info.params, info.results = types(parts[0]), types(parts[1])
+	}
+	for i, name := range names {
+		if name != "" {
+			info.name = name
+			opcodes[first+Opcode(i)] = info
+		}
+	}
+}
+
+// prefixed returns names with each prefixed by p.
+func prefixed(p string, names ...string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		if n != "" {
+			out[i] = p + n
+		}
+	}
+	return out
+}
+
+func init() {
+	seq(0x00, immNone, "", "unreachable")
+	seq(0x01, immNone, ":", "nop")
+	seq(0x02, immBlock, "", "block", "loop", "if")
+	seq(0x05, immNone, "", "else")
+	seq(0x0B, immNone, "", "end")
+	seq(0x0C, immLabel, "", "br", "br_if")
+	seq(0x0E, immLabels, "", "br_table")
+	seq(0x0F, immNone, "", "return")
+	seq(0x10, immFunc, "", "call")
+	seq(0x1A, immNone, "", "drop", "select")
+	seq(0x1C, immSelect, "", "select")
+	seq(0x20, immLocal, "", "local.get", "local.set", "local.tee")
+	seq(0x23, immGlobal, "", "global.get", "global.set")
+	seq(0x28, immMemarg, "i:i", "i32.load")
+	seq(0x29, immMemarg, "i:I", "i64.load")
+	seq(0x2A, immMemarg, "i:f", "f32.load")
+	seq(0x2B, immMemarg, "i:F", "f64.load")
+	seq(0x2C, immMemarg, "i:i", "i32.load8_s", "i32.load8_u", "i32.load16_s", "i32.load16_u")
+	seq(0x30, immMemarg, "i:I",
+		"i64.load8_s", "i64.load8_u", "i64.load16_s", "i64.load16_u",
+		"i64.load32_s", "i64.load32_u")
+	seq(0x36, immMemarg, "ii:", "i32.store")
+	seq(0x37, immMemarg, "iI:", "i64.store")
+	seq(0x38, immMemarg, "if:", "f32.store")
+	seq(0x39, immMemarg, "iF:", "f64.store")
+	seq(0x3A, immMemarg, "ii:", "i32.store8", "i32.store16")
+	seq(0x3C, immMemarg, "iI:", "i64.store8", "i64.store16", "i64.store32")
+	seq(0x3F, immMemory, ":i", "memory.size")
+	seq(0x40, immMemory, "i:i", "memory.grow")
+	seq(0x41, immI32, ":i", "i32.const")
+	seq(0x42, immI64, ":I", "i64.const")
+	seq(0x43, immF32, ":f", "f32.const")
+	seq(0x44, immF64, ":F", "f64.const")
+
+	compare := []string{"eq", "ne", "lt_s", "lt_u", "gt_s", "gt_u", "le_s", "le_u", "ge_s", "ge_u"}
+	fcompare := []string{"eq", "ne", "lt", "gt", "le", "ge"}
+	iunary := []string{"clz", "ctz", "popcnt"}
+	ibinary := []string{"add", "sub", "mul", "div_s", "div_u",
+		"rem_s", "rem_u", "and", "or", "xor", "shl", "shr_s", "shr_u", "rotl", "rotr"}
+	funary := []string{"abs", "neg", "ceil", "floor", "trunc", "nearest", "sqrt"}
+	fbinary := []string{"add", "sub", "mul", "div", "min", "max", "copysign"}
+	seq(0x45, immNone, "i:i", "i32.eqz")
+	seq(0x46, immNone, "ii:i", prefixed("i32.", compare...)...)
+	seq(0x50, immNone, "I:i", "i64.eqz")
+	seq(0x51, immNone, "II:i", prefixed("i64.", compare...)...)
+	seq(0x5B, immNone, "ff:i", prefixed("f32.", fcompare...)...)
+	seq(0x61, immNone, "FF:i", prefixed("f64.", fcompare...)...)
+	seq(0x67, immNone, "i:i", prefixed("i32.", iunary...)...)
+	seq(0x6A, immNone, "ii:i", prefixed("i32.", ibinary...)...)
+	seq(0x79, immNone, "I:I", prefixed("i64.", iunary...)...)
+	seq(0x7C, immNone, "II:I", prefixed("i64.", ibinary...)...)
+	seq(0x8B, immNone, "f:f", prefixed("f32.", funary...)...)
+	seq(0x92, immNone, "ff:f", prefixed("f32.", fbinary...)...)
+	seq(0x99, immNone, "F:F", prefixed("f64.", funary...)...)
+	seq(0xA0, immNone, "FF:F", prefixed("f64.", fbinary...)...)
+	seq(0xA7, immNone, "I:i", "i32.wrap_i64")
+	seq(0xA8, immNone, "f:i", "i32.trunc_f32_s", "i32.trunc_f32_u")
+	seq(0xAA, immNone, "F:i", "i32.trunc_f64_s", "i32.trunc_f64_u")
+	seq(0xAC, immNone, "i:I", "i64.extend_i32_s", "i64.extend_i32_u")
+	seq(0xAE, immNone, "f:I", "i64.trunc_f32_s", "i64.trunc_f32_u")
+	seq(0xB0, immNone, "F:I", "i64.trunc_f64_s", "i64.trunc_f64_u")
+	seq(0xB2, immNone, "i:f", "f32.convert_i32_s", "f32.convert_i32_u")
+	seq(0xB4, immNone, "I:f", "f32.convert_i64_s", "f32.convert_i64_u")
+	seq(0xB6, immNone, "F:f", "f32.demote_f64")
+	seq(0xB7, immNone, "i:F", "f64.convert_i32_s", "f64.convert_i32_u")
+	seq(0xB9, immNone, "I:F", "f64.convert_i64_s", "f64.convert_i64_u")
+	seq(0xBB, immNone, "f:F", "f64.promote_f32")
+	seq(0xBC, immNone, "f:i", "i32.reinterpret_f32")
+	seq(0xBD, immNone, "F:I", "i64.reinterpret_f64")
+	seq(0xBE, immNone, "i:f", "f32.reinterpret_i32")
+	seq(0xBF, immNone, "I:F", "f64.reinterpret_i64")
+	seq(0xC0, immNone, "i:i", "i32.extend8_s", "i32.extend16_s")
+	seq(0xC2, immNone, "I:I", "i64.extend8_s", "i64.extend16_s", "i64.extend32_s")
+	seq(prefixMisc, immNone, "f:i", "i32.trunc_sat_f32_s", "i32.trunc_sat_f32_u")
+	seq(prefixMisc|2, immNone, "F:i", "i32.trunc_sat_f64_s", "i32.trunc_sat_f64_u")
+	seq(prefixMisc|4, immNone, "f:I", "i64.trunc_sat_f32_s", "i64.trunc_sat_f32_u")
+	seq(prefixMisc|6, immNone, "F:I", "i64.trunc_sat_f64_s", "i64.trunc_sat_f64_u")
+
+	// the SIMD instructions emitted by this package
+	seq(prefixSIMD, immMemarg, "i:v",
+		"v128.load",
+		"v128.load8x8_s", "v128.load8x8_u", "v128.load16x4_s", "v128.load16x4_u",
+		"v128.load32x2_s", "v128.load32x2_u",
+		"v128.load8_splat", "v128.load16_splat", "v128.load32_splat", "v128.load64_splat")
+	seq(prefixSIMD|11, immMemarg, "iv:", "v128.store")
+	seq(prefixSIMD|12, immV128, ":v", "v128.const")
+	seq(prefixSIMD|13, immShuffle, "vv:v", "i8x16.shuffle")
+	seq(prefixSIMD|14, immNone, "vv:v", "i8x16.swizzle")
+	seq(prefixSIMD|15, immNone, "i:v", "i8x16.splat", "i16x8.splat", "i32x4.splat")
+	seq(prefixSIMD|18, immNone, "I:v", "i64x2.splat")
+	seq(prefixSIMD|19, immNone, "f:v", "f32x4.splat")
+	seq(prefixSIMD|20, immNone, "F:v", "f64x2.splat")
+	seq(prefixSIMD|21, immLane, "v:i", "i8x16.extract_lane_s", "i8x16.extract_lane_u")
+	seq(prefixSIMD|23, immLane, "vi:v", "i8x16.replace_lane")
+	seq(prefixSIMD|24, immLane, "v:i", "i16x8.extract_lane_s", "i16x8.extract_lane_u")
+	seq(prefixSIMD|26, immLane, "vi:v", "i16x8.replace_lane")
+	seq(prefixSIMD|27, immLane, "v:i", "i32x4.extract_lane")
+	seq(prefixSIMD|28, immLane, "vi:v", "i32x4.replace_lane")
+	seq(prefixSIMD|29, immLane, "v:I", "i64x2.extract_lane")
+	seq(prefixSIMD|30, immLane, "vI:v", "i64x2.replace_lane")
+	seq(prefixSIMD|31, immLane, "v:f", "f32x4.extract_lane")
+	seq(prefixSIMD|32, immLane, "vf:v", "f32x4.replace_lane")
+	seq(prefixSIMD|33, immLane, "v:F", "f64x2.extract_lane")
+	seq(prefixSIMD|34, immLane, "vF:v", "f64x2.replace_lane")
+	seq(prefixSIMD|35, immNone, "vv:v", prefixed("i8x16.", compare...)...)
+	seq(prefixSIMD|45, immNone, "vv:v", prefixed("i16x8.", compare...)...)
+	seq(prefixSIMD|55, immNone, "vv:v", prefixed("i32x4.", compare...)...)
+	seq(prefixSIMD|65, immNone, "vv:v", prefixed("f32x4.", fcompare...)...)
+	seq(prefixSIMD|71, immNone, "vv:v", prefixed("f64x2.", fcompare...)...)
+	seq(prefixSIMD|77, immNone, "v:v", "v128.not")
+	seq(prefixSIMD|78, immNone, "vv:v", "v128.and", "v128.andnot", "v128.or", "v128.xor")
+	seq(prefixSIMD|82, immNone, "vvv:v", "v128.bitselect")
+	seq(prefixSIMD|83, immNone, "v:i", "v128.any_true")
+	seq(prefixSIMD|84, immMemargLane, "iv:v",
+		"v128.load8_lane", "v128.load16_lane", "v128.load32_lane", "v128.load64_lane")
+	seq(prefixSIMD|88, immMemargLane, "iv:",
+		"v128.store8_lane", "v128.store16_lane", "v128.store32_lane", "v128.store64_lane")
+	seq(prefixSIMD|92, immMemarg, "i:v", "v128.load32_zero", "v128.load64_zero")
+
+	// the i8x16, i16x8 and i32x4 instructions follow the same
+	// pattern, with some instructions missing for each shape
+	seq(prefixSIMD|96, immNone, "v:v", "i8x16.abs", "i8x16.neg", "i8x16.popcnt")
+	seq(prefixSIMD|128, immNone, "v:v", "i16x8.abs", "i16x8.neg")
+	seq(prefixSIMD|160, immNone, "v:v", "i32x4.abs", "i32x4.neg")
+	seq(prefixSIMD|99, immNone, "v:i", "i8x16.all_true", "i8x16.bitmask")
+	seq(prefixSIMD|131, immNone, "v:i", "i16x8.all_true", "i16x8.bitmask")
+	seq(prefixSIMD|163, immNone, "v:i", "i32x4.all_true", "i32x4.bitmask")
+	seq(prefixSIMD|101, immNone, "vv:v", "i8x16.narrow_i16x8_s", "i8x16.narrow_i16x8_u")
+	seq(prefixSIMD|133, immNone, "vv:v", "i16x8.narrow_i32x4_s", "i16x8.narrow_i32x4_u")
+	seq(prefixSIMD|103, immNone, "v:v", "f32x4.ceil", "f32x4.floor", "f32x4.trunc", "f32x4.nearest")
+	seq(prefixSIMD|135, immNone, "v:v", prefixed("i16x8.",
+		"extend_low_i8x16_s", "extend_high_i8x16_s",
+		"extend_low_i8x16_u", "extend_high_i8x16_u")...)
+	seq(prefixSIMD|167, immNone, "v:v", prefixed("i32x4.",
+		"extend_low_i16x8_s", "extend_high_i16x8_s",
+		"extend_low_i16x8_u", "extend_high_i16x8_u")...)
+	shift := []string{"shl", "shr_s", "shr_u"}
+	seq(prefixSIMD|107, immNone, "vi:v", prefixed("i8x16.", shift...)...)
+	seq(prefixSIMD|139, immNone, "vi:v", prefixed("i16x8.", shift...)...)
+	seq(prefixSIMD|171, immNone, "vi:v", prefixed("i32x4.", shift...)...)
+	seq(prefixSIMD|110, immNone, "vv:v", prefixed("i8x16.",
+		"add", "add_sat_s", "add_sat_u", "sub", "sub_sat_s", "sub_sat_u",
+		"", "", "min_s", "min_u", "max_s", "max_u", "", "avgr_u")...)
+	seq(prefixSIMD|142, immNone, "vv:v", prefixed("i16x8.",
+		"add", "add_sat_s", "add_sat_u", "sub", "sub_sat_s", "sub_sat_u",
+		"", "mul", "min_s", "min_u", "max_s", "max_u", "", "avgr_u")...)
+	seq(prefixSIMD|174, immNone, "vv:v", prefixed("i32x4.",
+		"add", "", "", "sub", "", "", "",
+		"mul", "min_s", "min_u", "max_s", "max_u")...)
+	seq(prefixSIMD|224, immNone, "v:v", "f32x4.abs", "f32x4.neg", "", "f32x4.sqrt")
+	seq(prefixSIMD|228, immNone, "vv:v", prefixed("f32x4.",
+		"add", "sub", "mul", "div", "min", "max", "pmin", "pmax")...)
+	seq(prefixSIMD|248, immNone, "v:v",
+		"i32x4.trunc_sat_f32x4_s", "i32x4.trunc_sat_f32x4_u",
+		"f32x4.convert_i32x4_s", "f32x4.convert_i32x4_u")
+}
+
+// laneCount returns the number of lanes of the vector
+// accessed by the lane instruction op.
+func laneCount(op Opcode) int {
+	switch op &^ prefixSIMD {
+	case 21, 22, 23, 84, 88:
+		return 16
+	case 24, 25, 26, 85, 89:
+		return 8
+	case 27, 28, 31, 32, 86, 90:
+		return 4
+	default:
+		return 2
+	}
+}
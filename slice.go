@@ -1,6 +1,9 @@
 package wasm
 
-import "io"
+import (
+	"fmt"
+	"io"
+)
 
 // SliceF32 is a contiguous slice of float32 values located in wasm memory.
 type SliceF32 interface {
@@ -8,6 +11,38 @@ type SliceF32 interface {
 	LengthF32() F32
 	// IndexF32 returns the float32 value at index i.
 	IndexF32(i F32) F32
+	// IndexVec4F32 returns the 4 float32 values starting
+	// at index i.
+	IndexVec4F32(i F32) Vec4F32
+
+	// offsetI32 returns the byte-offset of the slice in memory.
+	offsetI32() I32
+}
+
+// MutableSliceF32 is a SliceF32 whose elements can be assigned.
+type MutableSliceF32 interface {
+	SliceF32
+	// SetF32 returns an instruction that assigns v to
+	// the element at index i.
+	SetF32(i F32, v F32) Instruction
+	// SetVec4F32 returns an instruction that assigns the
+	// lanes of v to the 4 elements starting at index i.
+	SetVec4F32(i F32, v Vec4F32) Instruction
+}
+
+func addressSliceF32(s SliceF32, i I32) I32 {
+	return AddI32(
+		s.offsetI32(),
+		MulI32(i, constUI32(4)),
+	)
+}
+
+func indexSliceF32(s SliceF32, i I32) F32 {
+	return loadF32(addressSliceF32(s, i))
+}
+
+func indexSliceVec4F32(s SliceF32, i I32) Vec4F32 {
+	return loadVec4F32(addressSliceF32(s, i))
 }
 
 type sliceF32 struct {
@@ -43,8 +78,8 @@ func (s *sliceF32) LengthF32() F32 {
 		u32(s.idx),
 
 		// get higher order bits
-		constUI64(32),
-		shiftRightUI64,
+		ConstI64(32),
+		shrUI64,
 
 		// convert the length to an F32
 		converti64uF32,
@@ -61,28 +96,30 @@ func (s *sliceF32) offsetI32() I32 {
 	}
 }
 
-func (s *sliceF32) indexI32(i I32) F32 {
-	return loadF32(
-		addi32(
-			s.offsetI32(),
-			muli32(i, constUI32(4)),
-		),
-	)
+func (s *sliceF32) IndexF32(i F32) F32 {
+	return indexSliceF32(s, castF32I32(i))
 }
 
-func (s *sliceF32) IndexF32(i F32) F32 {
-	return loadF32(
-		addi32(
-			s.offsetI32(),
-			muli32(castF32I32(i), constUI32(4)),
-		),
-	)
+func (s *sliceF32) IndexVec4F32(i F32) Vec4F32 {
+	return indexSliceVec4F32(s, castF32I32(i))
+}
+
+func (s *sliceF32) SetF32(i F32, v F32) Instruction {
+	return storeF32(addressSliceF32(s, castF32I32(i)), v)
+}
+
+func (s *sliceF32) SetVec4F32(i F32, v Vec4F32) Instruction {
+	return storeVec4F32(addressSliceF32(s, castF32I32(i)), v)
 }
 
 // SliceF32RangeF32 is an instruction that runs the instructions
 // returned by Do for each value in the slice from index Begin
 // to index End-1
+//
+// Break and Continue with the loop's Label, or an empty label,
+// exit the loop or skip to the next element.
 type SliceF32RangeF32 struct {
+	Label string
 	Slice SliceF32
 	Begin F32
 	End   F32
@@ -90,43 +127,267 @@ type SliceF32RangeF32 struct {
 }
 
 func (s SliceF32RangeF32) write(c instCtx) error {
-	end := c.fn.localI32()
-	idx := c.fn.localI32()
-	if s.Begin == nil {
-		s.Begin = ConstF32(0)
+	return sliceRange{
+		label: s.Label,
+		slice: s.Slice,
+		begin: s.Begin,
+		end:   s.End,
+		do: func(idx I32) []Instruction {
+			return s.Do(indexSliceF32(s.Slice, idx))
+		},
+	}.write(c)
+}
+
+// MutableSliceF32RangeF32 is an instruction that runs the
+// instructions returned by Do for each element in the slice from
+// index Begin to index End-1. Assigning to the MutableF32 passed
+// to Do stores to the current element of the slice.
+//
+// Break and Continue with the loop's Label, or an empty label,
+// exit the loop or skip to the next element.
+type MutableSliceF32RangeF32 struct {
+	Label string
+	Slice MutableSliceF32
+	Begin F32
+	End   F32
+	Do    func(v MutableF32) []Instruction
+}
+
+func (s MutableSliceF32RangeF32) write(c instCtx) error {
+	elem := sliceElemF32{
+		addr: c.fn.LocalI32(),
+		tmp:  c.fn.LocalF32().(localF32),
 	}
-	if s.End == nil {
-		s.End = s.Slice.LengthF32()
+	return sliceRange{
+		label: s.Label,
+		slice: s.Slice,
+		begin: s.Begin,
+		end:   s.End,
+		do: func(idx I32) []Instruction {
+			body := []Instruction{
+				AssignI32(elem.addr, addressSliceF32(s.Slice, idx)),
+			}
+			return append(body, s.Do(elem)...)
+		},
+	}.write(c)
+}
+
+// SliceF32RangeVec4F32 is an instruction that runs the
+// instructions returned by Do for each group of 4 values in the
+// slice from index Begin to index End-1. If fewer than 4 values
+// remain, the instructions returned by Tail are run for each of
+// them instead. Tail is required, since the length of the slice
+// is usually only known at run time.
+//
+// Break with the loop's Label, or an empty label, exits the loop
+// from either Do or Tail. Continue skips to the next group, or
+// the next value in Tail.
+type SliceF32RangeVec4F32 struct {
+	Label string
+	Slice SliceF32
+	Begin F32
+	End   F32
+	Do    func(v Vec4F32) []Instruction
+	Tail  func(v F32) []Instruction
+}
+
+func (s SliceF32RangeVec4F32) write(c instCtx) error {
+	if s.Tail == nil {
+		return fmt.Errorf("vec4 slice range has no tail")
 	}
-	body := ops{
+	return sliceRange{
+		label: s.Label,
+		slice: s.Slice,
+		begin: s.Begin,
+		end:   s.End,
+		doVec4: func(idx I32) []Instruction {
+			return s.Do(indexSliceVec4F32(s.Slice, idx))
+		},
+		do: func(idx I32) []Instruction {
+			return s.Tail(indexSliceF32(s.Slice, idx))
+		},
+	}.write(c)
+}
+
+// MutableSliceF32RangeVec4F32 is an instruction that runs the
+// instructions returned by Do for each group of 4 elements in the
+// slice from index Begin to index End-1. If fewer than 4 elements
+// remain, the instructions returned by Tail are run for each of
+// them instead. Tail is required, since the length of the slice
+// is usually only known at run time. Assigning to the value
+// passed to Do or Tail stores to the current elements of the
+// slice.
+//
+// Break with the loop's Label, or an empty label, exits the loop
+// from either Do or Tail. Continue skips to the next group, or
+// the next element in Tail.
+type MutableSliceF32RangeVec4F32 struct {
+	Label string
+	Slice MutableSliceF32
+	Begin F32
+	End   F32
+	Do    func(v MutableVec4F32) []Instruction
+	Tail  func(v MutableF32) []Instruction
+}
+
+func (s MutableSliceF32RangeVec4F32) write(c instCtx) error {
+	if s.Tail == nil {
+		return fmt.Errorf("vec4 slice range has no tail")
+	}
+	addr := c.fn.LocalI32()
+	vec := sliceElemVec4F32{
+		addr: addr,
+		tmp:  c.fn.LocalVec4F32().(localVec4F32),
+	}
+	elem := sliceElemF32{
+		addr: addr,
+		tmp:  c.fn.LocalF32().(localF32),
+	}
+	return sliceRange{
+		label: s.Label,
+		slice: s.Slice,
+		begin: s.Begin,
+		end:   s.End,
+		doVec4: func(idx I32) []Instruction {
+			body := []Instruction{
+				AssignI32(addr, addressSliceF32(s.Slice, idx)),
+			}
+			return append(body, s.Do(vec)...)
+		},
+		do: func(idx I32) []Instruction {
+			body := []Instruction{
+				AssignI32(addr, addressSliceF32(s.Slice, idx)),
+			}
+			return append(body, s.Tail(elem)...)
+		},
+	}.write(c)
+}
+
+// sliceRange loops over the indices of slice from begin to end-1.
+//
+// If doVec4 is set, it is run for each index while at least 4
+// indices remain, then do is run for each remaining index.
+type sliceRange struct {
+	label      string
+	slice      SliceF32
+	begin, end F32
+	do         func(idx I32) []Instruction
+	doVec4     func(idx I32) []Instruction
+}
+
+func (s sliceRange) write(c instCtx) error {
+	end := c.fn.LocalI32()
+	idx := c.fn.LocalI32()
+	if s.begin == nil {
+		s.begin = ConstF32(0)
+	}
+	if s.end == nil {
+		s.end = s.slice.LengthF32()
+	}
+	init := ops{
 		// begin = uint32(begin)
-		assignI32{dst: idx, v: castF32I32(s.Begin)},
+		AssignI32(idx, castF32I32(s.begin)),
 		// end = uint32(end)
-		assignI32{dst: end, v: castF32I32(s.End)},
-		// idx = uint32(0)
-
-		blockCI,
-		loopCI,
-
-		// if (idx >= end) break;
-		idx,
-		end,
-		geUI32,
-		branchIfCI,
-		u32(1),
-	}
-	slice := s.Slice.(*sliceF32)
-	body = append(body, s.Do(slice.indexI32(idx))...)
-	body = append(body,
-		// idx++
-		assignI32{dst: idx, v: addi32(idx, constUI32(1))},
-
-		// continue
-		branchCI,
-		u32(0),
-
-		endCI, // loopCI
-		endCI, // blockCI
-	)
-	return body.write(c)
+		AssignI32(end, castF32I32(s.end)),
+	}
+	if err := init.write(c); err != nil {
+		return err
+	}
+	scalar := func(outer int) loopBlock {
+		return loopBlock{
+			label: s.label,
+			check: []Instruction{
+				// if (idx >= end) break;
+				GeUI32(idx, end),
+				branchIf(1),
+			},
+			body: s.do(idx),
+			step: []Instruction{
+				// idx++
+				AssignI32(idx, AddI32(idx, constUI32(1))),
+			},
+			outer: outer,
+		}
+	}
+	if s.doVec4 == nil {
+		return scalar(0).write(c)
+	}
+	// both loops are wrapped in a block so that
+	// Break exits the tail as well.
+	blockCI.write(c)
+	inner := c.enter(1)
+	err := loopBlock{
+		label: s.label,
+		check: []Instruction{
+			// if (idx + 4 > end) break;
+			GtUI32(AddI32(idx, constUI32(4)), end),
+			branchIf(1),
+		},
+		body: s.doVec4(idx),
+		step: []Instruction{
+			// idx += 4
+			AssignI32(idx, AddI32(idx, constUI32(4))),
+		},
+		outer: 1,
+	}.write(inner)
+	if err != nil {
+		return err
+	}
+	if err := scalar(1).write(inner); err != nil {
+		return err
+	}
+	endCI.write(c)
+	return nil
+}
+
+// sliceElemF32 is an element of a slice whose address
+// is stored in a local.
+type sliceElemF32 struct {
+	addr MutableI32
+	tmp  localF32
+}
+
+func (e sliceElemF32) isF32() {}
+
+func (e sliceElemF32) write(out instCtx) error {
+	return loadF32(e.addr).write(out)
+}
+
+func (e sliceElemF32) set(out io.Writer) error {
+	// the value is on top of the stack, but the address
+	// must be pushed before it.
+	c, ok := out.(instCtx)
+	if !ok {
+		c = instCtx{Writer: out}
+	}
+	if err := e.tmp.set(out); err != nil {
+		return err
+	}
+	return storeF32(e.addr, e.tmp).write(c)
+}
+
+// sliceElemVec4F32 is a group of 4 elements of a slice
+// whose address is stored in a local.
+type sliceElemVec4F32 struct {
+	addr MutableI32
+	tmp  localVec4F32
+}
+
+func (e sliceElemVec4F32) isVec4F32() {}
+
+func (e sliceElemVec4F32) write(out instCtx) error {
+	return loadVec4F32(e.addr).write(out)
+}
+
+func (e sliceElemVec4F32) set(out io.Writer) error {
+	// the value is on top of the stack, but the address
+	// must be pushed before it.
+	c, ok := out.(instCtx)
+	if !ok {
+		c = instCtx{Writer: out}
+	}
+	if err := e.tmp.set(out); err != nil {
+		return err
+	}
+	return storeVec4F32(e.addr, e.tmp).write(c)
 }
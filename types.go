@@ -103,6 +103,9 @@ func (vt valuetype) encode(out io.Writer) error {
 }
 
 func (vt valuetype) String() string {
+	if vt.vectype {
+		return "v128"
+	}
 	if vt.numtype == 0 && vt.reftype == 0 {
 		panic("invalid valuetype")
 	}
@@ -112,6 +115,97 @@ func (vt valuetype) String() string {
 	return vt.numtype.String()
 }
 
+// Type is the type of a Function parameter or result.
+type Type byte
+
+const (
+	TypeI32 Type = iota + 1
+	TypeF32
+	TypeVec4F32
+	TypeF64
+	TypeI64
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeI32:
+		return "I32"
+	case TypeF32:
+		return "F32"
+	case TypeVec4F32:
+		return "Vec4F32"
+	case TypeF64:
+		return "F64"
+	case TypeI64:
+		return "I64"
+	default:
+		return fmt.Sprintf("Type(%d)", byte(t))
+	}
+}
+
+func (t Type) valuetype() valuetype {
+	switch t {
+	case TypeI32:
+		return valuetype{numtype: i32}
+	case TypeF32:
+		return valuetype{numtype: f32}
+	case TypeVec4F32:
+		return valuetype{vectype: true}
+	case TypeF64:
+		return valuetype{numtype: f64}
+	case TypeI64:
+		return valuetype{numtype: i64}
+	default:
+		panic(fmt.Errorf("%v is not a valid Type", t))
+	}
+}
+
+// typeOf returns the Type of the value produced by inst.
+func typeOf(inst Instruction) (Type, bool) {
+	switch inst.(type) {
+	case I32:
+		return TypeI32, true
+	case F32:
+		return TypeF32, true
+	case Vec4F32:
+		return TypeVec4F32, true
+	case Vec4I32, Vec8I16, Vec16I8, Mask4, Mask8, Mask16:
+		// all vectors share the v128 representation
+		return TypeVec4F32, true
+	case F64:
+		return TypeF64, true
+	case I64:
+		return TypeI64, true
+	default:
+		return 0, false
+	}
+}
+
+// Signature declares the parameter and result types of
+// a function.
+type Signature struct {
+	Params  []Type
+	Results []Type
+}
+
+func (s Signature) String() string {
+	return s.functype().String()
+}
+
+func (s Signature) functype() functype {
+	ft := functype{
+		params:  make(resulttype, len(s.Params)),
+		results: make(resulttype, len(s.Results)),
+	}
+	for i, t := range s.Params {
+		ft.params[i] = t.valuetype()
+	}
+	for i, t := range s.Results {
+		ft.results[i] = t.valuetype()
+	}
+	return ft
+}
+
 type resulttype []valuetype
 
 func (rt resulttype) String() string {
@@ -0,0 +1,322 @@
+package wasm
+
+import "fmt"
+
+// Validate checks that the function bodies of the module are
+// well typed: that every instruction finds operands of the
+// expected types on the stack, that blocks and functions leave
+// exactly their results on the stack, and that only mutable
+// globals are set. The structure of the module and the ranges
+// of its indices are checked by Decode.
+func (b *Binary) Validate() error {
+	funcs, _ := b.names()
+	return b.validate(funcs)
+}
+
+// validate decodes and validates the compiled module b. Errors
+// name functions as in the name section, even if it is not
+// written.
+func (m *Module) validate(b []byte) error {
+	bin, err := Decode(b)
+	if err != nil {
+		return fmt.Errorf("invalid module: %s", err)
+	}
+	funcs, _ := bin.names()
+	for v, name := range m.defaultNames() {
+		if f, ok := v.(*function); ok && int(f.idx) < len(funcs) {
+			funcs[f.idx] = name
+		}
+	}
+	if err := bin.validate(funcs); err != nil {
+		return fmt.Errorf("invalid module: %s", err)
+	}
+	return nil
+}
+
+// validate validates b, naming the functions in errors by
+// funcNames.
+func (b *Binary) validate(funcNames []string) (err error) {
+	v := &validator{Binary: b}
+	for _, imp := range b.Imports {
+		switch imp.Kind {
+		case ExternFunction:
+			v.funcs = append(v.funcs, imp.Type)
+		case ExternGlobal:
+			v.globals = append(v.globals, BinaryGlobal{Type: imp.Global, Mutable: imp.Mutable})
+		}
+	}
+	imported := uint32(len(v.funcs))
+	v.funcs = append(v.funcs, b.Functions...)
+	v.globals = append(v.globals, b.Globals...)
+
+	exports := make(map[string]bool)
+	for _, e := range b.Exports {
+		if exports[e.Name] {
+			return fmt.Errorf("duplicate export %q", e.Name)
+		}
+		exports[e.Name] = true
+	}
+	if b.Start >= 0 {
+		idx := uint32(b.Start)
+		if sig := b.Types[v.funcs[idx]]; len(sig.Params) != 0 || len(sig.Results) != 0 {
+			return fmt.Errorf("start %s has type %s",
+				describeFunc(idx, funcNames[idx]), sig)
+		}
+	}
+	for i, c := range b.Code {
+		idx := imported + uint32(i)
+		if err := v.function(b.Types[b.Functions[i]], c); err != nil {
+			return fmt.Errorf("%s: %s", describeFunc(idx, funcNames[idx]), err)
+		}
+	}
+	return nil
+}
+
+// unknown is the type of the values popped from the stack
+// in unreachable code.
+const unknown Type = 0
+
+// ctrlFrame is a block, loop or if, or a function body.
+type ctrlFrame struct {
+	op              Opcode
+	params, results []Type
+	// height is the height of the operand stack at the
+	// start of the block, after popping its params.
+	height      int
+	unreachable bool
+}
+
+func (f ctrlFrame) String() string {
+	if f.op == 0 {
+		return "function"
+	}
+	return f.op.String()
+}
+
+// labelTypes returns the types of the values taken by a
+// branch to f.
+func (f ctrlFrame) labelTypes() []Type {
+	if f.op == 0x03 {
+		return f.params
+	}
+	return f.results
+}
+
+// validator type checks function bodies by simulating the
+// types of the values on the operand stack.
+type validator struct {
+	*Binary
+	// funcs holds the type index of every function, and
+	// globals every global, with imports first.
+	funcs   []uint32
+	globals []BinaryGlobal
+
+	locals  []Type
+	results []Type
+	vals    []Type
+	ctrls   []ctrlFrame
+}
+
+// function validates the body c of a function of type sig.
+func (v *validator) function(sig Signature, c BinaryCode) (err error) {
+	v.locals = append(append(v.locals[:0], sig.Params...), c.Locals...)
+	v.results = sig.Results
+	v.vals = v.vals[:0]
+	v.ctrls = v.ctrls[:0]
+	var pc int
+	defer func() {
+		if r := recover(); r != nil {
+			de, ok := r.(decodeError)
+			if !ok {
+				panic(r)
+			}
+			at := "end"
+			if pc < len(c.Body) {
+				at = fmt.Sprintf("instruction %d (%v)", pc, c.Body[pc])
+			}
+			err = fmt.Errorf("%s: %s", at, de.err)
+		}
+	}()
+	v.pushCtrl(0, nil, sig.Results)
+	for pc = range c.Body {
+		v.instr(c.Body[pc])
+	}
+	pc = len(c.Body)
+	v.popCtrl()
+	return nil
+}
+
+func (v *validator) push(t Type) {
+	v.vals = append(v.vals, t)
+}
+
+func (v *validator) pushAll(ts []Type) {
+	for _, t := range ts {
+		v.push(t)
+	}
+}
+
+// pop pops a value of type want, or of any type if want
+// is unknown, and returns its type.
+func (v *validator) pop(want Type) Type {
+	f := &v.ctrls[len(v.ctrls)-1]
+	if len(v.vals) == f.height {
+		switch {
+		case f.unreachable:
+			return want
+		case want == unknown:
+			fail("missing operand")
+		default:
+			fail("missing %s operand", watType(want))
+		}
+	}
+	t := v.vals[len(v.vals)-1]
+	v.vals = v.vals[:len(v.vals)-1]
+	switch {
+	case t == unknown:
+		return want
+	case want != unknown && t != want:
+		fail("expected %s operand, found %s", watType(want), watType(t))
+	}
+	return t
+}
+
+// popAll pops values of types ts, the last first.
+func (v *validator) popAll(ts []Type) {
+	for i := len(ts) - 1; i >= 0; i-- {
+		v.pop(ts[i])
+	}
+}
+
+func (v *validator) pushCtrl(op Opcode, params, results []Type) {
+	v.ctrls = append(v.ctrls, ctrlFrame{
+		op:      op,
+		params:  params,
+		results: results,
+		height:  len(v.vals),
+	})
+	v.pushAll(params)
+}
+
+func (v *validator) popCtrl() ctrlFrame {
+	f := v.ctrls[len(v.ctrls)-1]
+	v.popAll(f.results)
+	if n := len(v.vals) - f.height; n != 0 {
+		fail("%d extra values on the stack at the end of the %s", n, f)
+	}
+	v.ctrls = v.ctrls[:len(v.ctrls)-1]
+	return f
+}
+
+// label returns the block targeted by a branch to depth.
+func (v *validator) label(depth uint32) ctrlFrame {
+	if int(depth) >= len(v.ctrls) {
+		fail("label %d out of range", depth)
+	}
+	return v.ctrls[len(v.ctrls)-1-int(depth)]
+}
+
+// unreachable marks the rest of the current block as
+// unreachable, where the stack may hold values of any type.
+func (v *validator) unreachable() {
+	f := &v.ctrls[len(v.ctrls)-1]
+	v.vals = v.vals[:f.height]
+	f.unreachable = true
+}
+
+func (v *validator) instr(in Instr) {
+	info := opcodes[in.Op]
+	if info.fixed {
+		v.popAll(info.params)
+		v.pushAll(info.results)
+		return
+	}
+	switch in.Op {
+	case 0x00: // unreachable
+		v.unreachable()
+	case 0x02, 0x03: // block, loop
+		v.popAll(in.Block.Params)
+		v.pushCtrl(in.Op, in.Block.Params, in.Block.Results)
+	case 0x04: // if
+		v.pop(TypeI32)
+		v.popAll(in.Block.Params)
+		v.pushCtrl(in.Op, in.Block.Params, in.Block.Results)
+	case 0x05: // else
+		f := v.popCtrl()
+		v.pushCtrl(in.Op, f.params, f.results)
+	case 0x0B: // end
+		f := v.popCtrl()
+		if f.op == 0x04 && !equalTypes(f.params, f.results) {
+			fail("if without else must leave its params on the stack")
+		}
+		v.pushAll(f.results)
+	case 0x0C: // br
+		v.popAll(v.label(in.Index).labelTypes())
+		v.unreachable()
+	case 0x0D: // br_if
+		v.pop(TypeI32)
+		types := v.label(in.Index).labelTypes()
+		v.popAll(types)
+		v.pushAll(types)
+	case 0x0E: // br_table
+		v.pop(TypeI32)
+		def := v.label(in.Labels[len(in.Labels)-1]).labelTypes()
+		for _, l := range in.Labels[:len(in.Labels)-1] {
+			types := v.label(l).labelTypes()
+			if len(types) != len(def) {
+				fail("label %d takes %d values, but the default label takes %d", l, len(types), len(def))
+			}
+			v.popAll(types)
+			v.pushAll(types)
+		}
+		v.popAll(def)
+		v.unreachable()
+	case 0x0F: // return
+		v.popAll(v.results)
+		v.unreachable()
+	case 0x10: // call
+		sig := v.Types[v.funcs[in.Index]]
+		v.popAll(sig.Params)
+		v.pushAll(sig.Results)
+	case 0x1A: // drop
+		v.pop(unknown)
+	case 0x1B: // select
+		v.pop(TypeI32)
+		t := v.pop(unknown)
+		v.push(v.pop(t))
+	case 0x1C: // select t
+		t := in.Block.Results[0]
+		v.pop(TypeI32)
+		v.pop(t)
+		v.pop(t)
+		v.push(t)
+	case 0x20: // local.get
+		v.push(v.locals[in.Index])
+	case 0x21: // local.set
+		v.pop(v.locals[in.Index])
+	case 0x22: // local.tee
+		v.push(v.pop(v.locals[in.Index]))
+	case 0x23: // global.get
+		v.push(v.globals[in.Index].Type)
+	case 0x24: // global.set
+		g := v.globals[in.Index]
+		if !g.Mutable {
+			fail("global %d is immutable", in.Index)
+		}
+		v.pop(g.Type)
+	default:
+		fail("unsupported instruction")
+	}
+}
+
+func equalTypes(a, b []Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, t := range a {
+		if t != b[i] {
+			return false
+		}
+	}
+	return true
+}
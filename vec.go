@@ -2,6 +2,8 @@ package wasm
 
 import (
 	"encoding/binary"
+	"fmt"
+	"io"
 )
 
 // Vec4F32 represents a float32 vector of 4
@@ -11,8 +13,10 @@ type Vec4F32 interface {
 	isVec4F32()
 }
 
+// MutableVec4F32 represents a mutable Vec4F32 node.
 type MutableVec4F32 interface {
 	Vec4F32
+	set(out io.Writer) error
 }
 
 type vec4F32 struct {
@@ -28,8 +32,26 @@ func (v *vec4F32) write(out instCtx) error {
 	return nil
 }
 
+func (v *vec4F32) set(out io.Writer) error {
+	out.Write([]byte{0x24}) // global.set x
+	writeu32(v.idx, out)
+	return nil
+}
+
 func (v *vec4F32) isVec4F32() {}
 
+func (v *vec4F32) isExportable() {}
+
+func (v *vec4F32) writeImportDesc(m *Module, out io.Writer) error {
+	out.Write([]byte{0x03})
+	return globaltype{
+		mutable: true,
+		valuetype: valuetype{
+			vectype: true,
+		},
+	}.encode(out)
+}
+
 func (v *vec4F32) incGlobalIndex() {
 	v.idx++
 }
@@ -42,6 +64,31 @@ func (v *vec4F32) globalIndex() uint32 {
 	return v.idx
 }
 
+type localVec4F32 struct{ local }
+
+func (l localVec4F32) isVec4F32() {}
+
+// AssignVec4F32 assigns the value of v to dst.
+func AssignVec4F32(dst MutableVec4F32, v Vec4F32) Instruction {
+	return assignVec4F32{dst: dst, v: v}
+}
+
+type assignVec4F32 struct {
+	dst MutableVec4F32
+	v   Vec4F32
+}
+
+func (a assignVec4F32) write(out instCtx) error {
+	if err := a.v.write(out); err != nil {
+		return err
+	}
+	if err := a.dst.set(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConstVec4F32 is a constant Vec4F32 value.
 type ConstVec4F32 [4]float32
 
 func (c ConstVec4F32) isVec4F32() {}
@@ -57,7 +104,8 @@ func (c ConstVec4F32) write(out instCtx) error {
 // or read from any function, and also can be exported
 // to be observed by the runtime.
 type GlobalVec4F32 interface {
-	Vec4F32
+	MutableVec4F32
+	Exportable
 }
 
 type extractLaneVec4F32 struct {
@@ -71,9 +119,7 @@ func (e extractLaneVec4F32) write(out instCtx) error {
 	if err := e.x.write(out); err != nil {
 		return err
 	}
-	extractLanef32x4V128.write(out)
-	writeu32(uint32(e.i), out)
-	return nil
+	return laneOp{op: extractLanef32x4V128, lane: e.i, n: 4}.write(out)
 }
 
 // Return the index i as an F32.
@@ -81,6 +127,63 @@ func ExtractLaneVec4F32(x Vec4F32, i int) F32 {
 	return extractLaneVec4F32{x: x, i: i}
 }
 
+// laneOp is a vector instruction with a lane index immediate.
+type laneOp struct {
+	op   vecOp
+	lane int
+	n    int // number of lanes
+}
+
+func (l laneOp) write(out instCtx) error {
+	if l.lane < 0 || l.lane >= l.n {
+		return fmt.Errorf("lane %d out of range [0, %d)", l.lane, l.n)
+	}
+	l.op.write(out)
+	out.Write([]byte{byte(l.lane)})
+	return nil
+}
+
+// SplatVec4F32 returns a Vec4F32 with all lanes set to x.
+func SplatVec4F32(x F32) Vec4F32 { return opsVec4F32{x, splatf32x4V128} }
+
+// ReplaceLaneVec4F32 returns v with lane i replaced by x.
+func ReplaceLaneVec4F32(v Vec4F32, i int, x F32) Vec4F32 {
+	return opsVec4F32{v, x, laneOp{op: replaceLanef32x4V128, lane: i, n: 4}}
+}
+
+// Vec4F32FromLanes returns the Vec4F32 with lanes x, y, z and w.
+func Vec4F32FromLanes(x, y, z, w F32) Vec4F32 {
+	v := SplatVec4F32(x)
+	v = ReplaceLaneVec4F32(v, 1, y)
+	v = ReplaceLaneVec4F32(v, 2, z)
+	return ReplaceLaneVec4F32(v, 3, w)
+}
+
+// ShuffleVec4F32 returns a Vec4F32 with lanes selected from a
+// and b. Lane i of the result is lane lanes[i] of a if lanes[i]
+// is less than 4, or lane lanes[i]-4 of b otherwise.
+func ShuffleVec4F32(a, b Vec4F32, lanes [4]int) Vec4F32 {
+	return opsVec4F32{a, b, shuffle4(lanes)}
+}
+
+// shuffle4 is an i8x16.shuffle of 32-bit lanes.
+type shuffle4 [4]int
+
+func (s shuffle4) write(out instCtx) error {
+	var imm [16]byte
+	for i, l := range s {
+		if l < 0 || l >= 8 {
+			return fmt.Errorf("shuffle lane %d out of range [0, 8)", l)
+		}
+		for j := 0; j < 4; j++ {
+			imm[i*4+j] = byte(l*4 + j)
+		}
+	}
+	shuffleV128.write(out)
+	out.Write(imm[:])
+	return nil
+}
+
 // AbsVec4F32 returns the absolute value of a.
 func AbsVec4F32(a Vec4F32) Vec4F32 { return opsVec4F32{a, absf32x4V128} }
 
@@ -119,3 +222,93 @@ func MinVec4F32(a, b Vec4F32) Vec4F32 { return opsVec4F32{a, b, minf32x4V128} }
 
 // MaxVec4F32 returns the maximum of a and b.
 func MaxVec4F32(a, b Vec4F32) Vec4F32 { return opsVec4F32{a, b, maxf32x4V128} }
+
+// letVec4F32 stores x in a temporary local so that it
+// can be used more than once without being evaluated
+// again.
+type letVec4F32 struct {
+	x  Vec4F32
+	in func(t Vec4F32) Instruction
+}
+
+func (l letVec4F32) write(c instCtx) error {
+	t := c.fn.LocalVec4F32()
+	if err := l.x.write(c); err != nil {
+		return err
+	}
+	if err := t.set(c); err != nil {
+		return err
+	}
+	return l.in(t).write(c)
+}
+
+// reduceVec4F32 combines the lanes of x pairwise with op,
+// and returns the result in every lane.
+func reduceVec4F32(x Vec4F32, op func(a, b Vec4F32) Vec4F32) Vec4F32 {
+	return opsVec4F32{letVec4F32{x: x, in: func(t Vec4F32) Instruction {
+		// (x+z, y+w, z+x, w+y)
+		return letVec4F32{
+			x: op(t, ShuffleVec4F32(t, t, [4]int{2, 3, 0, 1})),
+			in: func(u Vec4F32) Instruction {
+				return op(u, ShuffleVec4F32(u, u, [4]int{1, 0, 3, 2}))
+			},
+		}
+	}}}
+}
+
+// SumVec4F32 returns the sum of the lanes of x.
+func SumVec4F32(x Vec4F32) F32 {
+	return ExtractLaneVec4F32(reduceVec4F32(x, AddVec4F32), 0)
+}
+
+// MinLaneVec4F32 returns the minimum lane of x.
+// If any lane is NaN, the result is NaN.
+func MinLaneVec4F32(x Vec4F32) F32 {
+	return ExtractLaneVec4F32(reduceVec4F32(x, MinVec4F32), 0)
+}
+
+// MaxLaneVec4F32 returns the maximum lane of x.
+// If any lane is NaN, the result is NaN.
+func MaxLaneVec4F32(x Vec4F32) F32 {
+	return ExtractLaneVec4F32(reduceVec4F32(x, MaxVec4F32), 0)
+}
+
+// DotVec4F32 returns the dot product of a and b.
+func DotVec4F32(a, b Vec4F32) F32 {
+	return SumVec4F32(MulVec4F32(a, b))
+}
+
+// LengthVec4F32 returns the euclidean length of x.
+func LengthVec4F32(x Vec4F32) F32 {
+	return opsF32{letVec4F32{x: x, in: func(t Vec4F32) Instruction {
+		return SqrtF32(DotVec4F32(t, t))
+	}}}
+}
+
+// NormalizeVec4F32 returns x divided by its length. If x
+// has a length of zero, all lanes of the result are NaN.
+func NormalizeVec4F32(x Vec4F32) Vec4F32 {
+	return opsVec4F32{letVec4F32{x: x, in: func(t Vec4F32) Instruction {
+		return DivVec4F32(t, SplatVec4F32(SqrtF32(DotVec4F32(t, t))))
+	}}}
+}
+
+// CrossVec3 returns the cross product of the first 3 lanes
+// of a and b. The last lane of the result is zero if the last
+// lanes of a and b are finite.
+func CrossVec3(a, b Vec4F32) Vec4F32 {
+	yzx := [4]int{1, 2, 0, 3}
+	return opsVec4F32{letVec4F32{x: a, in: func(a Vec4F32) Instruction {
+		return letVec4F32{x: b, in: func(b Vec4F32) Instruction {
+			// c = a * b.yzx - a.yzx * b is the cross product
+			// in zxy order.
+			c := SubVec4F32(
+				MulVec4F32(a, ShuffleVec4F32(b, b, yzx)),
+				MulVec4F32(ShuffleVec4F32(a, a, yzx), b),
+			)
+			return letVec4F32{x: c, in: func(c Vec4F32) Instruction {
+				return ShuffleVec4F32(c, c, yzx)
+			}}
+		}}
+	}}}
+}
@@ -0,0 +1,213 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Vec16I8 represents an int8 vector of 16
+// elements.
+//
+// Vec16I8 values are loaded from and stored to memory
+// through a Vec4F32, as described for Vec4I32. For example,
+// a SliceF32 holding RGBA8 pixels is brightened 4 pixels at
+// a time by
+//
+//	px := wasm.Vec16I8FromBits(wasm.Vec4F32Bits(slice.IndexVec4F32(i)))
+//	px = wasm.AddSatUVec16I8(px, wasm.SplatVec16I8(wasm.ConstI32(16)))
+//	slice.SetVec4F32(i, wasm.Vec4F32FromBits(wasm.Vec16I8Bits(px)))
+type Vec16I8 interface {
+	Instruction
+	isVec16I8()
+}
+
+// MutableVec16I8 represents a mutable Vec16I8 node.
+type MutableVec16I8 interface {
+	Vec16I8
+	set(out io.Writer) error
+}
+
+type opsVec16I8 ops
+
+func (o opsVec16I8) isVec16I8() {}
+
+func (o opsVec16I8) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+type localVec16I8 struct{ local }
+
+func (l localVec16I8) isVec16I8() {}
+
+// AssignVec16I8 assigns the value of v to dst.
+func AssignVec16I8(dst MutableVec16I8, v Vec16I8) Instruction {
+	return assignVec16I8{dst: dst, v: v}
+}
+
+type assignVec16I8 struct {
+	dst MutableVec16I8
+	v   Vec16I8
+}
+
+func (a assignVec16I8) write(out instCtx) error {
+	if err := a.v.write(out); err != nil {
+		return err
+	}
+	if err := a.dst.set(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConstVec16I8 is a constant Vec16I8 value.
+type ConstVec16I8 [16]int8
+
+func (c ConstVec16I8) isVec16I8() {}
+
+func (c ConstVec16I8) write(out instCtx) error {
+	constV128.write(out)
+	binary.Write(out, binary.LittleEndian, c[:])
+	return nil
+}
+
+// SplatVec16I8 returns a Vec16I8 with all lanes set to the
+// lower 8 bits of x.
+func SplatVec16I8(x I32) Vec16I8 { return opsVec16I8{x, splati8x16V128} }
+
+// ExtractLaneVec16I8 returns lane i of v, sign-extended.
+func ExtractLaneVec16I8(v Vec16I8, i int) I32 {
+	return opsI32{v, laneOp{op: extractLanei8x16sV128, lane: i, n: 16}}
+}
+
+// ExtractLaneUVec16I8 returns lane i of v, zero-extended.
+func ExtractLaneUVec16I8(v Vec16I8, i int) I32 {
+	return opsI32{v, laneOp{op: extractLanei8x16uV128, lane: i, n: 16}}
+}
+
+// ReplaceLaneVec16I8 returns v with lane i replaced by the
+// lower 8 bits of x.
+func ReplaceLaneVec16I8(v Vec16I8, i int, x I32) Vec16I8 {
+	return opsVec16I8{v, x, laneOp{op: replaceLanei8x16V128, lane: i, n: 16}}
+}
+
+// Vec16I8Bits returns the lanes of a reinterpreted as a Vec4I32.
+func Vec16I8Bits(a Vec16I8) Vec4I32 { return opsVec4I32{a} }
+
+// Vec16I8FromBits returns the lanes of a reinterpreted as a Vec16I8.
+func Vec16I8FromBits(a Vec4I32) Vec16I8 { return opsVec16I8{a} }
+
+// NarrowVec8I16 returns the lanes of a followed by the lanes
+// of b, saturated to signed 8-bit integers.
+func NarrowVec8I16(a, b Vec8I16) Vec16I8 { return opsVec16I8{a, b, narrowi16x8sV128} }
+
+// NarrowUVec8I16 returns the lanes of a followed by the lanes
+// of b, saturated to unsigned 8-bit integers.
+func NarrowUVec8I16(a, b Vec8I16) Vec16I8 { return opsVec16I8{a, b, narrowi16x8uV128} }
+
+// AbsVec16I8 returns the absolute value of a.
+func AbsVec16I8(a Vec16I8) Vec16I8 { return opsVec16I8{a, absi8x16V128} }
+
+// NegVec16I8 returns the result of negating a.
+func NegVec16I8(a Vec16I8) Vec16I8 { return opsVec16I8{a, negi8x16V128} }
+
+// AddVec16I8 returns the sum of a and b.
+func AddVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, addi8x16V128} }
+
+// AddSatVec16I8 returns the sum of a and b, saturated
+// as signed integers.
+func AddSatVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, addSatSi8x16V128} }
+
+// AddSatUVec16I8 returns the sum of a and b, saturated
+// as unsigned integers.
+func AddSatUVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, addSatUi8x16V128} }
+
+// SubVec16I8 returns the difference of a and b.
+func SubVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, subi8x16V128} }
+
+// SubSatVec16I8 returns the difference of a and b, saturated
+// as signed integers.
+func SubSatVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, subSatSi8x16V128} }
+
+// SubSatUVec16I8 returns the difference of a and b, saturated
+// as unsigned integers.
+func SubSatUVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, subSatUi8x16V128} }
+
+// AvgUVec16I8 returns the rounded average of a and b as
+// unsigned integers.
+func AvgUVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, avgrUi8x16V128} }
+
+// MinVec16I8 returns the minimum of a and b as signed integers.
+func MinVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, minSi8x16V128} }
+
+// MinUVec16I8 returns the minimum of a and b as unsigned integers.
+func MinUVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, minUi8x16V128} }
+
+// MaxVec16I8 returns the maximum of a and b as signed integers.
+func MaxVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, maxSi8x16V128} }
+
+// MaxUVec16I8 returns the maximum of a and b as unsigned integers.
+func MaxUVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, maxUi8x16V128} }
+
+// ShlVec16I8 returns the lanes of a shifted left by b
+// modulo 8 bits.
+func ShlVec16I8(a Vec16I8, b I32) Vec16I8 { return opsVec16I8{a, b, shli8x16V128} }
+
+// ShrSVec16I8 returns the lanes of a arithmetically shifted
+// right by b modulo 8 bits.
+func ShrSVec16I8(a Vec16I8, b I32) Vec16I8 { return opsVec16I8{a, b, shrSi8x16V128} }
+
+// ShrUVec16I8 returns the lanes of a logically shifted
+// right by b modulo 8 bits.
+func ShrUVec16I8(a Vec16I8, b I32) Vec16I8 { return opsVec16I8{a, b, shrUi8x16V128} }
+
+// AndVec16I8 returns the bitwise and of a and b.
+func AndVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, andV128} }
+
+// OrVec16I8 returns the bitwise or of a and b.
+func OrVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, orV128} }
+
+// XorVec16I8 returns the bitwise exclusive or of a and b.
+func XorVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, xorV128} }
+
+// AndNotVec16I8 returns the bitwise and of a and the
+// complement of b.
+func AndNotVec16I8(a, b Vec16I8) Vec16I8 { return opsVec16I8{a, b, andnotV128} }
+
+// NotVec16I8 returns the bitwise complement of a.
+func NotVec16I8(a Vec16I8) Vec16I8 { return opsVec16I8{a, notV128} }
+
+// SelectVec16I8 returns the lanes of a where mask is set,
+// and the lanes of b otherwise.
+func SelectVec16I8(mask Mask16, a, b Vec16I8) Vec16I8 {
+	return opsVec16I8{a, b, mask, bitselectV128}
+}
+
+// EqVec16I8 returns whether a == b for each lane.
+func EqVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, eqi8x16V128} }
+
+// NeVec16I8 returns whether a != b for each lane.
+func NeVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, nei8x16V128} }
+
+// LtVec16I8 returns whether a < b for each lane as signed integers.
+func LtVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, ltSi8x16V128} }
+
+// LtUVec16I8 returns whether a < b for each lane as unsigned integers.
+func LtUVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, ltUi8x16V128} }
+
+// GtVec16I8 returns whether a > b for each lane as signed integers.
+func GtVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, gtSi8x16V128} }
+
+// GtUVec16I8 returns whether a > b for each lane as unsigned integers.
+func GtUVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, gtUi8x16V128} }
+
+// LeVec16I8 returns whether a <= b for each lane as signed integers.
+func LeVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, leSi8x16V128} }
+
+// LeUVec16I8 returns whether a <= b for each lane as unsigned integers.
+func LeUVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, leUi8x16V128} }
+
+// GeVec16I8 returns whether a >= b for each lane as signed integers.
+func GeVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, geSi8x16V128} }
+
+// GeUVec16I8 returns whether a >= b for each lane as unsigned integers.
+func GeUVec16I8(a, b Vec16I8) Mask16 { return opsMask16{a, b, geUi8x16V128} }
@@ -0,0 +1,210 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Vec4I32 represents an int32 vector of 4
+// elements.
+//
+// There are no Vec4I32 globals, params or slices. Integer
+// vectors are loaded from and stored to memory as the bits
+// of a Vec4F32, with Vec4F32Bits and Vec4F32FromBits:
+//
+//	v := wasm.Vec4F32Bits(slice.IndexVec4F32(i))
+//	...
+//	slice.SetVec4F32(i, wasm.Vec4F32FromBits(v))
+type Vec4I32 interface {
+	Instruction
+	isVec4I32()
+}
+
+// MutableVec4I32 represents a mutable Vec4I32 node.
+type MutableVec4I32 interface {
+	Vec4I32
+	set(out io.Writer) error
+}
+
+type opsVec4I32 ops
+
+func (o opsVec4I32) isVec4I32() {}
+
+func (o opsVec4I32) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+type localVec4I32 struct{ local }
+
+func (l localVec4I32) isVec4I32() {}
+
+// AssignVec4I32 assigns the value of v to dst.
+func AssignVec4I32(dst MutableVec4I32, v Vec4I32) Instruction {
+	return assignVec4I32{dst: dst, v: v}
+}
+
+type assignVec4I32 struct {
+	dst MutableVec4I32
+	v   Vec4I32
+}
+
+func (a assignVec4I32) write(out instCtx) error {
+	if err := a.v.write(out); err != nil {
+		return err
+	}
+	if err := a.dst.set(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConstVec4I32 is a constant Vec4I32 value.
+type ConstVec4I32 [4]int32
+
+func (c ConstVec4I32) isVec4I32() {}
+
+func (c ConstVec4I32) write(out instCtx) error {
+	constV128.write(out)
+	binary.Write(out, binary.LittleEndian, c[:])
+	return nil
+}
+
+// SplatVec4I32 returns a Vec4I32 with all lanes set to x.
+func SplatVec4I32(x I32) Vec4I32 { return opsVec4I32{x, splati32x4V128} }
+
+// ExtractLaneVec4I32 returns lane i of v.
+func ExtractLaneVec4I32(v Vec4I32, i int) I32 {
+	return opsI32{v, laneOp{op: extractLanei32x4V128, lane: i, n: 4}}
+}
+
+// ReplaceLaneVec4I32 returns v with lane i replaced by x.
+func ReplaceLaneVec4I32(v Vec4I32, i int, x I32) Vec4I32 {
+	return opsVec4I32{v, x, laneOp{op: replaceLanei32x4V128, lane: i, n: 4}}
+}
+
+// Vec4F32Bits returns the IEEE 754 binary representation
+// of the lanes of a.
+func Vec4F32Bits(a Vec4F32) Vec4I32 { return opsVec4I32{a} }
+
+// Vec4F32FromBits returns the Vec4F32 with the IEEE 754
+// binary representation of the lanes of a.
+func Vec4F32FromBits(a Vec4I32) Vec4F32 { return opsVec4F32{a} }
+
+// Vec4I32ToVec4F32 converts the signed lanes of a to float32.
+func Vec4I32ToVec4F32(a Vec4I32) Vec4F32 { return opsVec4F32{a, convertI32x4sV128} }
+
+// UVec4I32ToVec4F32 converts the unsigned lanes of a to float32.
+func UVec4I32ToVec4F32(a Vec4I32) Vec4F32 { return opsVec4F32{a, convertI32x4uV128} }
+
+// Vec4F32ToVec4I32 converts the lanes of a to signed integers,
+// truncating towards zero. Values out of range saturate, and
+// NaN converts to 0.
+func Vec4F32ToVec4I32(a Vec4F32) Vec4I32 { return opsVec4I32{a, truncSatf32x4sV128} }
+
+// Vec4F32ToUVec4I32 converts the lanes of a to unsigned integers,
+// truncating towards zero. Values out of range saturate, and
+// NaN converts to 0.
+func Vec4F32ToUVec4I32(a Vec4F32) Vec4I32 { return opsVec4I32{a, truncSatf32x4uV128} }
+
+// ExtendLowVec8I16 sign-extends the lower 4 lanes of a.
+func ExtendLowVec8I16(a Vec8I16) Vec4I32 { return opsVec4I32{a, extendLowi16x8sV128} }
+
+// ExtendHighVec8I16 sign-extends the upper 4 lanes of a.
+func ExtendHighVec8I16(a Vec8I16) Vec4I32 { return opsVec4I32{a, extendHighi16x8sV128} }
+
+// ExtendLowUVec8I16 zero-extends the lower 4 lanes of a.
+func ExtendLowUVec8I16(a Vec8I16) Vec4I32 { return opsVec4I32{a, extendLowi16x8uV128} }
+
+// ExtendHighUVec8I16 zero-extends the upper 4 lanes of a.
+func ExtendHighUVec8I16(a Vec8I16) Vec4I32 { return opsVec4I32{a, extendHighi16x8uV128} }
+
+// AbsVec4I32 returns the absolute value of a.
+func AbsVec4I32(a Vec4I32) Vec4I32 { return opsVec4I32{a, absi32x4V128} }
+
+// NegVec4I32 returns the result of negating a.
+func NegVec4I32(a Vec4I32) Vec4I32 { return opsVec4I32{a, negi32x4V128} }
+
+// AddVec4I32 returns the sum of a and b.
+func AddVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, addi32x4V128} }
+
+// SubVec4I32 returns the difference of a and b.
+func SubVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, subi32x4V128} }
+
+// MulVec4I32 returns the product of a and b.
+func MulVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, muli32x4V128} }
+
+// MinVec4I32 returns the minimum of a and b as signed integers.
+func MinVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, minSi32x4V128} }
+
+// MinUVec4I32 returns the minimum of a and b as unsigned integers.
+func MinUVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, minUi32x4V128} }
+
+// MaxVec4I32 returns the maximum of a and b as signed integers.
+func MaxVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, maxSi32x4V128} }
+
+// MaxUVec4I32 returns the maximum of a and b as unsigned integers.
+func MaxUVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, maxUi32x4V128} }
+
+// ShlVec4I32 returns the lanes of a shifted left by b
+// modulo 32 bits.
+func ShlVec4I32(a Vec4I32, b I32) Vec4I32 { return opsVec4I32{a, b, shli32x4V128} }
+
+// ShrSVec4I32 returns the lanes of a arithmetically shifted
+// right by b modulo 32 bits.
+func ShrSVec4I32(a Vec4I32, b I32) Vec4I32 { return opsVec4I32{a, b, shrSi32x4V128} }
+
+// ShrUVec4I32 returns the lanes of a logically shifted
+// right by b modulo 32 bits.
+func ShrUVec4I32(a Vec4I32, b I32) Vec4I32 { return opsVec4I32{a, b, shrUi32x4V128} }
+
+// AndVec4I32 returns the bitwise and of a and b.
+func AndVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, andV128} }
+
+// OrVec4I32 returns the bitwise or of a and b.
+func OrVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, orV128} }
+
+// XorVec4I32 returns the bitwise exclusive or of a and b.
+func XorVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, xorV128} }
+
+// AndNotVec4I32 returns the bitwise and of a and the
+// complement of b.
+func AndNotVec4I32(a, b Vec4I32) Vec4I32 { return opsVec4I32{a, b, andnotV128} }
+
+// NotVec4I32 returns the bitwise complement of a.
+func NotVec4I32(a Vec4I32) Vec4I32 { return opsVec4I32{a, notV128} }
+
+// SelectVec4I32 returns the lanes of a where mask is set,
+// and the lanes of b otherwise.
+func SelectVec4I32(mask Mask4, a, b Vec4I32) Vec4I32 {
+	return opsVec4I32{a, b, mask, bitselectV128}
+}
+
+// EqVec4I32 returns whether a == b for each lane.
+func EqVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, eqi32x4V128} }
+
+// NeVec4I32 returns whether a != b for each lane.
+func NeVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, nei32x4V128} }
+
+// LtVec4I32 returns whether a < b for each lane as signed integers.
+func LtVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, ltSi32x4V128} }
+
+// LtUVec4I32 returns whether a < b for each lane as unsigned integers.
+func LtUVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, ltUi32x4V128} }
+
+// GtVec4I32 returns whether a > b for each lane as signed integers.
+func GtVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, gtSi32x4V128} }
+
+// GtUVec4I32 returns whether a > b for each lane as unsigned integers.
+func GtUVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, gtUi32x4V128} }
+
+// LeVec4I32 returns whether a <= b for each lane as signed integers.
+func LeVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, leSi32x4V128} }
+
+// LeUVec4I32 returns whether a <= b for each lane as unsigned integers.
+func LeUVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, leUi32x4V128} }
+
+// GeVec4I32 returns whether a >= b for each lane as signed integers.
+func GeVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, geSi32x4V128} }
+
+// GeUVec4I32 returns whether a >= b for each lane as unsigned integers.
+func GeUVec4I32(a, b Vec4I32) Mask4 { return opsMask4{a, b, geUi32x4V128} }
@@ -0,0 +1,223 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Vec8I16 represents an int16 vector of 8
+// elements.
+//
+// Vec8I16 values are loaded from and stored to memory
+// through a Vec4F32, as described for Vec4I32, with
+// Vec8I16FromBits and Vec8I16Bits.
+type Vec8I16 interface {
+	Instruction
+	isVec8I16()
+}
+
+// MutableVec8I16 represents a mutable Vec8I16 node.
+type MutableVec8I16 interface {
+	Vec8I16
+	set(out io.Writer) error
+}
+
+type opsVec8I16 ops
+
+func (o opsVec8I16) isVec8I16() {}
+
+func (o opsVec8I16) write(out instCtx) error {
+	return ops(o).write(out)
+}
+
+type localVec8I16 struct{ local }
+
+func (l localVec8I16) isVec8I16() {}
+
+// AssignVec8I16 assigns the value of v to dst.
+func AssignVec8I16(dst MutableVec8I16, v Vec8I16) Instruction {
+	return assignVec8I16{dst: dst, v: v}
+}
+
+type assignVec8I16 struct {
+	dst MutableVec8I16
+	v   Vec8I16
+}
+
+func (a assignVec8I16) write(out instCtx) error {
+	if err := a.v.write(out); err != nil {
+		return err
+	}
+	if err := a.dst.set(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConstVec8I16 is a constant Vec8I16 value.
+type ConstVec8I16 [8]int16
+
+func (c ConstVec8I16) isVec8I16() {}
+
+func (c ConstVec8I16) write(out instCtx) error {
+	constV128.write(out)
+	binary.Write(out, binary.LittleEndian, c[:])
+	return nil
+}
+
+// SplatVec8I16 returns a Vec8I16 with all lanes set to the
+// lower 16 bits of x.
+func SplatVec8I16(x I32) Vec8I16 { return opsVec8I16{x, splati16x8V128} }
+
+// ExtractLaneVec8I16 returns lane i of v, sign-extended.
+func ExtractLaneVec8I16(v Vec8I16, i int) I32 {
+	return opsI32{v, laneOp{op: extractLanei16x8sV128, lane: i, n: 8}}
+}
+
+// ExtractLaneUVec8I16 returns lane i of v, zero-extended.
+func ExtractLaneUVec8I16(v Vec8I16, i int) I32 {
+	return opsI32{v, laneOp{op: extractLanei16x8uV128, lane: i, n: 8}}
+}
+
+// ReplaceLaneVec8I16 returns v with lane i replaced by the
+// lower 16 bits of x.
+func ReplaceLaneVec8I16(v Vec8I16, i int, x I32) Vec8I16 {
+	return opsVec8I16{v, x, laneOp{op: replaceLanei16x8V128, lane: i, n: 8}}
+}
+
+// Vec8I16Bits returns the lanes of a reinterpreted as a Vec4I32.
+func Vec8I16Bits(a Vec8I16) Vec4I32 { return opsVec4I32{a} }
+
+// Vec8I16FromBits returns the lanes of a reinterpreted as a Vec8I16.
+func Vec8I16FromBits(a Vec4I32) Vec8I16 { return opsVec8I16{a} }
+
+// NarrowVec4I32 returns the lanes of a followed by the lanes
+// of b, saturated to signed 16-bit integers.
+func NarrowVec4I32(a, b Vec4I32) Vec8I16 { return opsVec8I16{a, b, narrowi32x4sV128} }
+
+// NarrowUVec4I32 returns the lanes of a followed by the lanes
+// of b, saturated to unsigned 16-bit integers.
+func NarrowUVec4I32(a, b Vec4I32) Vec8I16 { return opsVec8I16{a, b, narrowi32x4uV128} }
+
+// ExtendLowVec16I8 sign-extends the lower 8 lanes of a.
+func ExtendLowVec16I8(a Vec16I8) Vec8I16 { return opsVec8I16{a, extendLowi8x16sV128} }
+
+// ExtendHighVec16I8 sign-extends the upper 8 lanes of a.
+func ExtendHighVec16I8(a Vec16I8) Vec8I16 { return opsVec8I16{a, extendHighi8x16sV128} }
+
+// ExtendLowUVec16I8 zero-extends the lower 8 lanes of a.
+func ExtendLowUVec16I8(a Vec16I8) Vec8I16 { return opsVec8I16{a, extendLowi8x16uV128} }
+
+// ExtendHighUVec16I8 zero-extends the upper 8 lanes of a.
+func ExtendHighUVec16I8(a Vec16I8) Vec8I16 { return opsVec8I16{a, extendHighi8x16uV128} }
+
+// AbsVec8I16 returns the absolute value of a.
+func AbsVec8I16(a Vec8I16) Vec8I16 { return opsVec8I16{a, absi16x8V128} }
+
+// NegVec8I16 returns the result of negating a.
+func NegVec8I16(a Vec8I16) Vec8I16 { return opsVec8I16{a, negi16x8V128} }
+
+// AddVec8I16 returns the sum of a and b.
+func AddVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, addi16x8V128} }
+
+// AddSatVec8I16 returns the sum of a and b, saturated
+// as signed integers.
+func AddSatVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, addSatSi16x8V128} }
+
+// AddSatUVec8I16 returns the sum of a and b, saturated
+// as unsigned integers.
+func AddSatUVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, addSatUi16x8V128} }
+
+// SubVec8I16 returns the difference of a and b.
+func SubVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, subi16x8V128} }
+
+// SubSatVec8I16 returns the difference of a and b, saturated
+// as signed integers.
+func SubSatVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, subSatSi16x8V128} }
+
+// SubSatUVec8I16 returns the difference of a and b, saturated
+// as unsigned integers.
+func SubSatUVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, subSatUi16x8V128} }
+
+// MulVec8I16 returns the product of a and b.
+func MulVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, muli16x8V128} }
+
+// AvgUVec8I16 returns the rounded average of a and b as
+// unsigned integers.
+func AvgUVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, avgrUi16x8V128} }
+
+// MinVec8I16 returns the minimum of a and b as signed integers.
+func MinVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, minSi16x8V128} }
+
+// MinUVec8I16 returns the minimum of a and b as unsigned integers.
+func MinUVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, minUi16x8V128} }
+
+// MaxVec8I16 returns the maximum of a and b as signed integers.
+func MaxVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, maxSi16x8V128} }
+
+// MaxUVec8I16 returns the maximum of a and b as unsigned integers.
+func MaxUVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, maxUi16x8V128} }
+
+// ShlVec8I16 returns the lanes of a shifted left by b
+// modulo 16 bits.
+func ShlVec8I16(a Vec8I16, b I32) Vec8I16 { return opsVec8I16{a, b, shli16x8V128} }
+
+// ShrSVec8I16 returns the lanes of a arithmetically shifted
+// right by b modulo 16 bits.
+func ShrSVec8I16(a Vec8I16, b I32) Vec8I16 { return opsVec8I16{a, b, shrSi16x8V128} }
+
+// ShrUVec8I16 returns the lanes of a logically shifted
+// right by b modulo 16 bits.
+func ShrUVec8I16(a Vec8I16, b I32) Vec8I16 { return opsVec8I16{a, b, shrUi16x8V128} }
+
+// AndVec8I16 returns the bitwise and of a and b.
+func AndVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, andV128} }
+
+// OrVec8I16 returns the bitwise or of a and b.
+func OrVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, orV128} }
+
+// XorVec8I16 returns the bitwise exclusive or of a and b.
+func XorVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, xorV128} }
+
+// AndNotVec8I16 returns the bitwise and of a and the
+// complement of b.
+func AndNotVec8I16(a, b Vec8I16) Vec8I16 { return opsVec8I16{a, b, andnotV128} }
+
+// NotVec8I16 returns the bitwise complement of a.
+func NotVec8I16(a Vec8I16) Vec8I16 { return opsVec8I16{a, notV128} }
+
+// SelectVec8I16 returns the lanes of a where mask is set,
+// and the lanes of b otherwise.
+func SelectVec8I16(mask Mask8, a, b Vec8I16) Vec8I16 {
+	return opsVec8I16{a, b, mask, bitselectV128}
+}
+
+// EqVec8I16 returns whether a == b for each lane.
+func EqVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, eqi16x8V128} }
+
+// NeVec8I16 returns whether a != b for each lane.
+func NeVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, nei16x8V128} }
+
+// LtVec8I16 returns whether a < b for each lane as signed integers.
+func LtVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, ltSi16x8V128} }
+
+// LtUVec8I16 returns whether a < b for each lane as unsigned integers.
+func LtUVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, ltUi16x8V128} }
+
+// GtVec8I16 returns whether a > b for each lane as signed integers.
+func GtVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, gtSi16x8V128} }
+
+// GtUVec8I16 returns whether a > b for each lane as unsigned integers.
+func GtUVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, gtUi16x8V128} }
+
+// LeVec8I16 returns whether a <= b for each lane as signed integers.
+func LeVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, leSi16x8V128} }
+
+// LeUVec8I16 returns whether a <= b for each lane as unsigned integers.
+func LeUVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, leUi16x8V128} }
+
+// GeVec8I16 returns whether a >= b for each lane as signed integers.
+func GeVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, geSi16x8V128} }
+
+// GeUVec8I16 returns whether a >= b for each lane as unsigned integers.
+func GeUVec8I16(a, b Vec8I16) Mask8 { return opsMask8{a, b, geUi16x8V128} }
@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"strings"
 	"testing"
 
 	wasm "github.com/chriscraws/gowasm"
+	"github.com/chriscraws/gowasm/interp"
 	"github.com/wasmerio/wasmer-go/wasmer"
 )
 
@@ -105,6 +110,364 @@ var opf32Tests = []struct {
 		assign: wasm.CopysignF32(wasm.ConstF32(30), wasm.ConstF32(-5)),
 		expect: -30,
 	},
+	{
+		what: "select true",
+		assign: wasm.SelectF32(
+			wasm.LtF32(wasm.ConstF32(1), wasm.ConstF32(2)),
+			wasm.ConstF32(3), wasm.ConstF32(4)),
+		expect: 3,
+	},
+	{
+		what: "select false",
+		assign: wasm.SelectF32(
+			wasm.ConstBool(false),
+			wasm.ConstF32(3), wasm.ConstF32(4)),
+		expect: 4,
+	},
+	{
+		what: "if expression",
+		assign: wasm.AddF32(wasm.ConstF32(1), wasm.IfExprF32{
+			Condition: wasm.ConstBool(true),
+			Then:      wasm.ConstF32(10),
+			Else:      wasm.I32ToF32(wasm.DivSI32(wasm.ConstI32(1), wasm.ConstI32(0))),
+		}),
+		expect: 11,
+	},
+	{
+		what: "if expression else",
+		assign: wasm.IfExprF32{
+			Condition: wasm.ConstBool(false),
+			Then:      wasm.I32ToF32(wasm.DivSI32(wasm.ConstI32(1), wasm.ConstI32(0))),
+			Else:      wasm.ConstF32(-10),
+		},
+		expect: -10,
+	},
+	{
+		what: "select i32",
+		assign: wasm.I32ToF32(wasm.SelectI32(
+			wasm.ConstBool(true), wasm.ConstI32(7), wasm.ConstI32(8))),
+		expect: 7,
+	},
+	{
+		what: "select f64",
+		assign: wasm.F64ToF32(wasm.SelectF64(
+			wasm.ConstBool(false), wasm.ConstF64(7), wasm.ConstF64(8))),
+		expect: 8,
+	},
+	{
+		what: "select i64",
+		assign: wasm.I64ToF32(wasm.SelectI64(
+			wasm.ConstBool(false), wasm.ConstI64(7), wasm.ConstI64(9))),
+		expect: 9,
+	},
+	{
+		what:   "sum vec4",
+		assign: wasm.SumVec4F32(wasm.ConstVec4F32{1, 2, 3, 4}),
+		expect: 10,
+	},
+	{
+		what:   "min lane vec4",
+		assign: wasm.MinLaneVec4F32(wasm.ConstVec4F32{3, 2, -1, 4}),
+		expect: -1,
+	},
+	{
+		what:   "max lane vec4",
+		assign: wasm.MaxLaneVec4F32(wasm.ConstVec4F32{3, 2, -1, 4}),
+		expect: 4,
+	},
+	{
+		what: "dot vec4",
+		assign: wasm.DotVec4F32(
+			wasm.ConstVec4F32{1, 2, 3, 4},
+			wasm.ConstVec4F32{2, -1, 0.5, 1}),
+		expect: 5.5,
+	},
+	{
+		what:   "length vec4",
+		assign: wasm.LengthVec4F32(wasm.ConstVec4F32{1, 2, 2, 4}),
+		expect: 5,
+	},
+}
+
+var opi32Tests = []struct {
+	what   string
+	assign wasm.I32
+	expect int32
+}{
+	{"const", wasm.ConstI32(-7), -7},
+	{"const large", wasm.ConstI32(1 << 30), 1 << 30},
+	{"clz", wasm.ClzI32(wasm.ConstI32(1)), 31},
+	{"ctz", wasm.CtzI32(wasm.ConstI32(8)), 3},
+	{"popcnt", wasm.PopcntI32(wasm.ConstI32(0xF0F)), 8},
+	{"add", wasm.AddI32(wasm.ConstI32(1), wasm.ConstI32(5)), 6},
+	{"sub", wasm.SubI32(wasm.ConstI32(1), wasm.ConstI32(5)), -4},
+	{"mul", wasm.MulI32(wasm.ConstI32(-3), wasm.ConstI32(5)), -15},
+	{"div signed", wasm.DivSI32(wasm.ConstI32(-7), wasm.ConstI32(2)), -3},
+	{"div unsigned", wasm.DivUI32(wasm.ConstI32(-2), wasm.ConstI32(2)), 0x7FFFFFFF},
+	{"rem signed", wasm.RemSI32(wasm.ConstI32(-7), wasm.ConstI32(2)), -1},
+	{"rem unsigned", wasm.RemUI32(wasm.ConstI32(7), wasm.ConstI32(4)), 3},
+	{"and", wasm.AndI32(wasm.ConstI32(6), wasm.ConstI32(3)), 2},
+	{"or", wasm.OrI32(wasm.ConstI32(6), wasm.ConstI32(3)), 7},
+	{"xor", wasm.XorI32(wasm.ConstI32(6), wasm.ConstI32(3)), 5},
+	{"shl", wasm.ShlI32(wasm.ConstI32(3), wasm.ConstI32(4)), 48},
+	{"shr signed", wasm.ShrSI32(wasm.ConstI32(-16), wasm.ConstI32(2)), -4},
+	{"shr unsigned", wasm.ShrUI32(wasm.ConstI32(-16), wasm.ConstI32(28)), 15},
+	{"rotl", wasm.RotlI32(wasm.ConstI32(-0x80000000), wasm.ConstI32(1)), 1},
+	{"rotr", wasm.RotrI32(wasm.ConstI32(1), wasm.ConstI32(1)), -0x80000000},
+	{"eqz", wasm.EqzI32(wasm.ConstI32(0)), 1},
+	{"eq", wasm.EqI32(wasm.ConstI32(2), wasm.ConstI32(3)), 0},
+	{"ne", wasm.NeI32(wasm.ConstI32(2), wasm.ConstI32(3)), 1},
+	{"lt signed", wasm.LtI32(wasm.ConstI32(-1), wasm.ConstI32(3)), 1},
+	{"lt unsigned", wasm.LtUI32(wasm.ConstI32(-1), wasm.ConstI32(3)), 0},
+	{"gt signed", wasm.GtI32(wasm.ConstI32(-1), wasm.ConstI32(3)), 0},
+	{"gt unsigned", wasm.GtUI32(wasm.ConstI32(-1), wasm.ConstI32(3)), 1},
+	{"le signed", wasm.LeI32(wasm.ConstI32(3), wasm.ConstI32(3)), 1},
+	{"le unsigned", wasm.LeUI32(wasm.ConstI32(-1), wasm.ConstI32(3)), 0},
+	{"ge signed", wasm.GeI32(wasm.ConstI32(2), wasm.ConstI32(3)), 0},
+	{"ge unsigned", wasm.GeUI32(wasm.ConstI32(-1), wasm.ConstI32(3)), 1},
+	{"from f32", wasm.F32ToI32(wasm.ConstF32(-3.7)), -3},
+	{"from f32 saturates", wasm.F32ToI32(wasm.ConstF32(1e20)), 0x7FFFFFFF},
+	{"from f32 unsigned", wasm.F32ToUI32(wasm.ConstF32(-3.7)), 0},
+	{"f32 bits", wasm.F32Bits(wasm.ConstF32(1)), 0x3F800000},
+	{"lt f64", wasm.LtF64(wasm.ConstF64(1), wasm.ConstF64(1.0000001)), 1},
+	{"ge f64", wasm.GeF64(wasm.ConstF64(1), wasm.ConstF64(1.0000001)), 0},
+	{"eq f64", wasm.EqF64(wasm.ConstF64(0.5), wasm.ConstF64(0.5)), 1},
+	{"from f64", wasm.F64ToI32(wasm.ConstF64(-1e300)), -0x80000000},
+	{"from f64 unsigned", wasm.F64ToUI32(wasm.ConstF64(4294967295)), -1},
+	{"const 64", wasm.ConstI32(64), 64},
+	{"const min", wasm.ConstI32(-0x80000000), -0x80000000},
+	{"lt i64", wasm.LtI64(wasm.ConstI64(-1), wasm.ConstI64(0)), 1},
+	{"lt u64", wasm.LtUI64(wasm.ConstI64(-1), wasm.ConstI64(0)), 0},
+	{"eqz i64", wasm.EqzI64(wasm.ConstI64(1 << 40)), 0},
+	{"ge u64", wasm.GeUI64(wasm.ConstI64(-1), wasm.ConstI64(1<<40)), 1},
+	{"from i64", wasm.I64ToI32(wasm.ConstI64(1<<32 + 7)), 7},
+	{"mask eq", wasm.Mask4Bits(wasm.EqVec4F32(
+		wasm.ConstVec4F32{1, 2, 3, 4}, wasm.ConstVec4F32{1, 0, 3, 0})), 0x5},
+	{"mask ne", wasm.Mask4Bits(wasm.NeVec4F32(
+		wasm.ConstVec4F32{1, 2, 3, 4}, wasm.ConstVec4F32{1, 0, 3, 0})), 0xA},
+	{"mask lt", wasm.Mask4Bits(wasm.LtVec4F32(
+		wasm.ConstVec4F32{1, 2, 3, 4}, wasm.ConstVec4F32{2, 2, 2, 2})), 0x1},
+	{"mask gt", wasm.Mask4Bits(wasm.GtVec4F32(
+		wasm.ConstVec4F32{1, 2, 3, 4}, wasm.ConstVec4F32{2, 2, 2, 2})), 0xC},
+	{"mask le", wasm.Mask4Bits(wasm.LeVec4F32(
+		wasm.ConstVec4F32{1, 2, 3, 4}, wasm.ConstVec4F32{2, 2, 2, 2})), 0x3},
+	{"mask ge", wasm.Mask4Bits(wasm.GeVec4F32(
+		wasm.ConstVec4F32{1, 2, 3, 4}, wasm.ConstVec4F32{2, 2, 2, 2})), 0xE},
+	{"mask const", wasm.Mask4Bits(wasm.ConstMask4{true, false, false, true}), 0x9},
+	{"mask splat", wasm.Mask4Bits(wasm.SplatMask4(wasm.ConstBool(true))), 0xF},
+	{"mask and", wasm.Mask4Bits(wasm.AndMask4(
+		wasm.ConstMask4{true, true, false, false},
+		wasm.ConstMask4{true, false, true, false})), 0x1},
+	{"mask or", wasm.Mask4Bits(wasm.OrMask4(
+		wasm.ConstMask4{true, true, false, false},
+		wasm.ConstMask4{true, false, true, false})), 0x7},
+	{"mask xor", wasm.Mask4Bits(wasm.XorMask4(
+		wasm.ConstMask4{true, true, false, false},
+		wasm.ConstMask4{true, false, true, false})), 0x6},
+	{"mask andnot", wasm.Mask4Bits(wasm.AndNotMask4(
+		wasm.ConstMask4{true, true, false, false},
+		wasm.ConstMask4{true, false, true, false})), 0x2},
+	{"mask not", wasm.Mask4Bits(wasm.NotMask4(
+		wasm.ConstMask4{true, true, false, false})), 0xC},
+	{"mask any", wasm.AnyMask4(wasm.ConstMask4{false, false, true, false}), 1},
+	{"mask any none", wasm.AnyMask4(wasm.ConstMask4{}), 0},
+	{"mask all", wasm.AllMask4(wasm.ConstMask4{true, true, true, true}), 1},
+	{"mask all some", wasm.AllMask4(wasm.ConstMask4{true, true, false, true}), 0},
+	{"mask nan", wasm.Mask4Bits(wasm.EqVec4F32(
+		wasm.SplatVec4F32(wasm.DivF32(wasm.ConstF32(0), wasm.ConstF32(0))),
+		wasm.ConstVec4F32{})), 0},
+	{"vec4i32 add", wasm.ExtractLaneVec4I32(wasm.AddVec4I32(
+		wasm.ConstVec4I32{1, 2, 3, 4}, wasm.SplatVec4I32(wasm.ConstI32(10))), 2), 13},
+	{"vec4i32 sub", wasm.ExtractLaneVec4I32(wasm.SubVec4I32(
+		wasm.ConstVec4I32{1, 2, 3, 4}, wasm.ConstVec4I32{4, 3, 2, 1}), 0), -3},
+	{"vec4i32 mul", wasm.ExtractLaneVec4I32(wasm.MulVec4I32(
+		wasm.ConstVec4I32{1, 2, 3, -4}, wasm.ConstVec4I32{4, 3, 2, 1}), 3), -4},
+	{"vec4i32 neg", wasm.ExtractLaneVec4I32(wasm.NegVec4I32(wasm.ConstVec4I32{1, 2, 3, 4}), 1), -2},
+	{"vec4i32 abs", wasm.ExtractLaneVec4I32(wasm.AbsVec4I32(wasm.ConstVec4I32{1, -2, 3, 4}), 1), 2},
+	{"vec4i32 min", wasm.ExtractLaneVec4I32(wasm.MinVec4I32(
+		wasm.ConstVec4I32{-1, 2, 3, 4}, wasm.ConstVec4I32{1, 1, 1, 1}), 0), -1},
+	{"vec4i32 min u", wasm.ExtractLaneVec4I32(wasm.MinUVec4I32(
+		wasm.ConstVec4I32{-1, 2, 3, 4}, wasm.ConstVec4I32{1, 1, 1, 1}), 0), 1},
+	{"vec4i32 max", wasm.ExtractLaneVec4I32(wasm.MaxVec4I32(
+		wasm.ConstVec4I32{-1, 2, 3, 4}, wasm.ConstVec4I32{1, 1, 1, 1}), 0), 1},
+	{"vec4i32 max u", wasm.ExtractLaneVec4I32(wasm.MaxUVec4I32(
+		wasm.ConstVec4I32{-1, 2, 3, 4}, wasm.ConstVec4I32{1, 1, 1, 1}), 0), -1},
+	{"vec4i32 shl", wasm.ExtractLaneVec4I32(wasm.ShlVec4I32(
+		wasm.ConstVec4I32{1, 2, 3, 4}, wasm.ConstI32(33)), 3), 8},
+	{"vec4i32 shr s", wasm.ExtractLaneVec4I32(wasm.ShrSVec4I32(
+		wasm.ConstVec4I32{-8, 2, 3, 4}, wasm.ConstI32(2)), 0), -2},
+	{"vec4i32 shr u", wasm.ExtractLaneVec4I32(wasm.ShrUVec4I32(
+		wasm.ConstVec4I32{-8, 2, 3, 4}, wasm.ConstI32(28)), 0), 15},
+	{"vec4i32 and not", wasm.ExtractLaneVec4I32(wasm.AndNotVec4I32(
+		wasm.SplatVec4I32(wasm.ConstI32(0xFF)), wasm.SplatVec4I32(wasm.ConstI32(0x0F))), 0), 0xF0},
+	{"vec4i32 replace", wasm.ExtractLaneVec4I32(wasm.ReplaceLaneVec4I32(
+		wasm.ConstVec4I32{}, 1, wasm.ConstI32(7)), 1), 7},
+	{"vec4i32 compare", wasm.Mask4Bits(wasm.LtVec4I32(
+		wasm.ConstVec4I32{-1, 2, 3, 4}, wasm.ConstVec4I32{0, 0, 5, 5})), 0xD},
+	{"vec4i32 compare u", wasm.Mask4Bits(wasm.LtUVec4I32(
+		wasm.ConstVec4I32{-1, 2, 3, 4}, wasm.ConstVec4I32{0, 0, 5, 5})), 0xC},
+	{"vec4i32 select", wasm.ExtractLaneVec4I32(wasm.SelectVec4I32(
+		wasm.GeVec4I32(wasm.ConstVec4I32{1, 5, 1, 5}, wasm.SplatVec4I32(wasm.ConstI32(3))),
+		wasm.SplatVec4I32(wasm.ConstI32(3)),
+		wasm.ConstVec4I32{1, 5, 1, 5}), 1), 3},
+	{"vec4i32 from f32", wasm.ExtractLaneVec4I32(wasm.Vec4F32ToVec4I32(
+		wasm.ConstVec4F32{1.7, -2.5, 1e10, 0}), 1), -2},
+	{"vec4i32 from f32 saturate", wasm.ExtractLaneVec4I32(wasm.Vec4F32ToVec4I32(
+		wasm.ConstVec4F32{1.7, -2.5, 1e10, 0}), 2), math.MaxInt32},
+	{"vec4i32 from f32 u", wasm.ExtractLaneVec4I32(wasm.Vec4F32ToUVec4I32(
+		wasm.ConstVec4F32{1.7, -2.5, 1e10, 0}), 1), 0},
+	{"vec4i32 bits", wasm.ExtractLaneVec4I32(wasm.Vec4F32Bits(
+		wasm.ConstVec4F32{1, 0, 0, 0}), 0), 0x3F800000},
+	{"vec4i32 extend", wasm.ExtractLaneVec4I32(wasm.ExtendHighVec8I16(
+		wasm.ConstVec8I16{0, 0, 0, 0, -5, 0, 0, 0}), 0), -5},
+	{"vec4i32 extend u", wasm.ExtractLaneVec4I32(wasm.ExtendHighUVec8I16(
+		wasm.ConstVec8I16{0, 0, 0, 0, -5, 0, 0, 0}), 0), 0xFFFB},
+	{"vec4i32 extend low", wasm.ExtractLaneVec4I32(wasm.ExtendLowVec8I16(
+		wasm.ConstVec8I16{0, 0, 0, -5, 0, 0, 0, 0}), 3), -5},
+	{"vec8i16 add", wasm.ExtractLaneVec8I16(wasm.AddVec8I16(
+		wasm.SplatVec8I16(wasm.ConstI32(0x7FFF)), wasm.SplatVec8I16(wasm.ConstI32(1))), 5), -0x8000},
+	{"vec8i16 add sat", wasm.ExtractLaneVec8I16(wasm.AddSatVec8I16(
+		wasm.SplatVec8I16(wasm.ConstI32(0x7FFF)), wasm.SplatVec8I16(wasm.ConstI32(1))), 5), 0x7FFF},
+	{"vec8i16 add sat u", wasm.ExtractLaneUVec8I16(wasm.AddSatUVec8I16(
+		wasm.SplatVec8I16(wasm.ConstI32(0xFFFE)), wasm.SplatVec8I16(wasm.ConstI32(5))), 5), 0xFFFF},
+	{"vec8i16 sub sat", wasm.ExtractLaneVec8I16(wasm.SubSatVec8I16(
+		wasm.SplatVec8I16(wasm.ConstI32(-0x7FFF)), wasm.SplatVec8I16(wasm.ConstI32(5))), 0), -0x8000},
+	{"vec8i16 sub sat u", wasm.ExtractLaneVec8I16(wasm.SubSatUVec8I16(
+		wasm.SplatVec8I16(wasm.ConstI32(3)), wasm.SplatVec8I16(wasm.ConstI32(5))), 0), 0},
+	{"vec8i16 mul", wasm.ExtractLaneVec8I16(wasm.MulVec8I16(
+		wasm.ConstVec8I16{1, 2, 3, 4, 5, 6, 7, 8}, wasm.ConstVec8I16{8, 7, 6, 5, 4, 3, 2, 1}), 4), 20},
+	{"vec8i16 avg u", wasm.ExtractLaneVec8I16(wasm.AvgUVec8I16(
+		wasm.ConstVec8I16{1, 2, 3, 4, 5, 6, 7, 8}, wasm.ConstVec8I16{8, 7, 6, 5, 4, 3, 2, 1}), 0), 5},
+	{"vec8i16 max u", wasm.ExtractLaneUVec8I16(wasm.MaxUVec8I16(
+		wasm.ConstVec8I16{-1}, wasm.ConstVec8I16{1}), 0), 0xFFFF},
+	{"vec8i16 min", wasm.ExtractLaneVec8I16(wasm.MinVec8I16(
+		wasm.ConstVec8I16{-1}, wasm.ConstVec8I16{1}), 0), -1},
+	{"vec8i16 shr u", wasm.ExtractLaneVec8I16(wasm.ShrUVec8I16(
+		wasm.ConstVec8I16{-1}, wasm.ConstI32(12)), 0), 0xF},
+	{"vec8i16 shr s", wasm.ExtractLaneVec8I16(wasm.ShrSVec8I16(
+		wasm.ConstVec8I16{-16}, wasm.ConstI32(2)), 0), -4},
+	{"vec8i16 shl", wasm.ExtractLaneVec8I16(wasm.ShlVec8I16(
+		wasm.ConstVec8I16{3}, wasm.ConstI32(2)), 0), 12},
+	{"vec8i16 narrow", wasm.ExtractLaneVec8I16(wasm.NarrowVec4I32(
+		wasm.ConstVec4I32{1, 2, 3, 4}, wasm.ConstVec4I32{40000, 6, 7, 8}), 4), 0x7FFF},
+	{"vec8i16 narrow u", wasm.ExtractLaneVec8I16(wasm.NarrowUVec4I32(
+		wasm.ConstVec4I32{1, -2, 3, 4}, wasm.ConstVec4I32{5, 6, 7, 8}), 1), 0},
+	{"vec8i16 extend u", wasm.ExtractLaneVec8I16(wasm.ExtendLowUVec16I8(
+		wasm.ConstVec16I8{-1}), 0), 0xFF},
+	{"vec8i16 extend", wasm.ExtractLaneVec8I16(wasm.ExtendLowVec16I8(
+		wasm.ConstVec16I8{-1}), 0), -1},
+	{"vec8i16 extend high", wasm.ExtractLaneVec8I16(wasm.ExtendHighUVec16I8(
+		wasm.ConstVec16I8{8: -2}), 0), 0xFE},
+	{"vec8i16 compare", wasm.Mask8Bits(wasm.GtVec8I16(
+		wasm.ConstVec8I16{1, 2, 3, 4, 5, 6, 7, 8}, wasm.SplatVec8I16(wasm.ConstI32(4)))), 0xF0},
+	{"vec8i16 any", wasm.AnyMask8(wasm.EqVec8I16(
+		wasm.ConstVec8I16{1, 2, 3, 4, 5, 6, 7, 8}, wasm.SplatVec8I16(wasm.ConstI32(4)))), 1},
+	{"vec8i16 all", wasm.AllMask8(wasm.NeVec8I16(
+		wasm.ConstVec8I16{1, 2, 3, 4, 5, 6, 7, 8}, wasm.SplatVec8I16(wasm.ConstI32(4)))), 0},
+	{"vec8i16 replace", wasm.ExtractLaneVec8I16(wasm.ReplaceLaneVec8I16(
+		wasm.ConstVec8I16{}, 7, wasm.ConstI32(0x12345)), 7), 0x2345},
+	{"vec16i8 add sat u", wasm.ExtractLaneUVec16I8(wasm.AddSatUVec16I8(
+		wasm.SplatVec16I8(wasm.ConstI32(200)), wasm.SplatVec16I8(wasm.ConstI32(100))), 9), 255},
+	{"vec16i8 add", wasm.ExtractLaneUVec16I8(wasm.AddVec16I8(
+		wasm.SplatVec16I8(wasm.ConstI32(200)), wasm.SplatVec16I8(wasm.ConstI32(100))), 9), 44},
+	{"vec16i8 sub sat", wasm.ExtractLaneVec16I8(wasm.SubSatVec16I8(
+		wasm.SplatVec16I8(wasm.ConstI32(-100)), wasm.SplatVec16I8(wasm.ConstI32(100))), 15), -128},
+	{"vec16i8 avg u", wasm.ExtractLaneUVec16I8(wasm.AvgUVec16I8(
+		wasm.SplatVec16I8(wasm.ConstI32(255)), wasm.SplatVec16I8(wasm.ConstI32(0))), 0), 128},
+	{"vec16i8 min u", wasm.ExtractLaneUVec16I8(wasm.MinUVec16I8(
+		wasm.ConstVec16I8{-1}, wasm.ConstVec16I8{5}), 0), 5},
+	{"vec16i8 max", wasm.ExtractLaneVec16I8(wasm.MaxVec16I8(
+		wasm.ConstVec16I8{-1}, wasm.ConstVec16I8{5}), 0), 5},
+	{"vec16i8 shl", wasm.ExtractLaneVec16I8(wasm.ShlVec16I8(
+		wasm.ConstVec16I8{1}, wasm.ConstI32(7)), 0), -128},
+	{"vec16i8 narrow u", wasm.ExtractLaneUVec16I8(wasm.NarrowUVec8I16(
+		wasm.ConstVec8I16{300}, wasm.ConstVec8I16{}), 0), 255},
+	{"vec16i8 narrow", wasm.ExtractLaneVec16I8(wasm.NarrowVec8I16(
+		wasm.ConstVec8I16{}, wasm.ConstVec8I16{-300}), 8), -128},
+	{"vec16i8 compare", wasm.Mask16Bits(wasm.LtUVec16I8(
+		wasm.ConstVec16I8{0: -1, 1: 1}, wasm.SplatVec16I8(wasm.ConstI32(2)))), 0xFFFE},
+	{"vec16i8 all", wasm.AllMask16(wasm.GeVec16I8(
+		wasm.ConstVec16I8{}, wasm.ConstVec16I8{})), 1},
+	{"vec16i8 bits", wasm.ExtractLaneVec4I32(wasm.Vec16I8Bits(
+		wasm.ConstVec16I8{1, 2, 3, 4}), 0), 0x04030201},
+	{"rgba luma", wasm.ExtractLaneVec4I32(
+		// weights R, G and B of each pixel, and sums them
+		wasm.ShrUVec4I32(
+			wasm.AddVec4I32(
+				wasm.AddVec4I32(
+					wasm.MulVec4I32(wasm.AndVec4I32(wasm.Vec16I8Bits(wasm.ConstVec16I8{
+						100, 50, 20, -1, 0, 0, 0, -1, -1, -1, -1, -1, 10, 20, 30, -1,
+					}), wasm.SplatVec4I32(wasm.ConstI32(0xFF))), wasm.SplatVec4I32(wasm.ConstI32(77))),
+					wasm.MulVec4I32(wasm.AndVec4I32(wasm.ShrUVec4I32(wasm.Vec16I8Bits(wasm.ConstVec16I8{
+						100, 50, 20, -1, 0, 0, 0, -1, -1, -1, -1, -1, 10, 20, 30, -1,
+					}), wasm.ConstI32(8)), wasm.SplatVec4I32(wasm.ConstI32(0xFF))), wasm.SplatVec4I32(wasm.ConstI32(150)))),
+				wasm.MulVec4I32(wasm.AndVec4I32(wasm.ShrUVec4I32(wasm.Vec16I8Bits(wasm.ConstVec16I8{
+					100, 50, 20, -1, 0, 0, 0, -1, -1, -1, -1, -1, 10, 20, 30, -1,
+				}), wasm.ConstI32(16)), wasm.SplatVec4I32(wasm.ConstI32(0xFF))), wasm.SplatVec4I32(wasm.ConstI32(29)))),
+			wasm.ConstI32(8)), 0), (100*77 + 50*150 + 20*29) >> 8},
+}
+
+var opf64Tests = []struct {
+	what   string
+	assign wasm.F64
+	expect float64
+}{
+	{"abs", wasm.AbsF64(wasm.ConstF64(-10)), 10},
+	{"neg", wasm.NegF64(wasm.ConstF64(10)), -10},
+	{"ceil", wasm.CeilF64(wasm.ConstF64(-0.2)), -0},
+	{"floor", wasm.FloorF64(wasm.ConstF64(-0.2)), -1},
+	{"trunc", wasm.TruncF64(wasm.ConstF64(-1.7)), -1},
+	{"nearest", wasm.NearestF64(wasm.ConstF64(0.6)), 1},
+	{"sqrt", wasm.SqrtF64(wasm.ConstF64(2)), 1.4142135623730951},
+	{"add", wasm.AddF64(wasm.ConstF64(1), wasm.ConstF64(1e-12)), 1.000000000001},
+	{"sub", wasm.SubF64(wasm.ConstF64(1), wasm.ConstF64(5)), -4},
+	{"mul", wasm.MulF64(wasm.ConstF64(3), wasm.ConstF64(5)), 15},
+	{"div", wasm.DivF64(wasm.ConstF64(1), wasm.ConstF64(3)), 1.0 / 3},
+	{"min", wasm.MinF64(wasm.ConstF64(30), wasm.ConstF64(5)), 5},
+	{"max", wasm.MaxF64(wasm.ConstF64(30), wasm.ConstF64(5)), 30},
+	{"copysign", wasm.CopysignF64(wasm.ConstF64(30), wasm.ConstF64(-5)), -30},
+	{"promote", wasm.F32ToF64(wasm.ConstF32(0.1)), float64(float32(0.1))},
+	{"from i32", wasm.I32ToF64(wasm.ConstI32(-3)), -3},
+	{"from u32", wasm.UI32ToF64(wasm.ConstI32(-1)), 4294967295},
+	{"from i64", wasm.I64ToF64(wasm.ConstI64(-1 << 40)), -1 << 40},
+	{"from u64", wasm.UI64ToF64(wasm.ConstI64(-1)), 1 << 64},
+	{"from bits", wasm.F64FromBits(wasm.ConstI64(0x4000000000000000)), 2},
+}
+
+var opi64Tests = []struct {
+	what   string
+	assign wasm.I64
+	expect int64
+}{
+	{"const 64", wasm.ConstI64(64), 64},
+	{"const negative", wasm.ConstI64(-65), -65},
+	{"const large", wasm.ConstI64(1<<62 + 12345), 1<<62 + 12345},
+	{"const min", wasm.ConstI64(-1 << 63), -1 << 63},
+	{"clz", wasm.ClzI64(wasm.ConstI64(1)), 63},
+	{"ctz", wasm.CtzI64(wasm.ConstI64(1 << 40)), 40},
+	{"popcnt", wasm.PopcntI64(wasm.ConstI64(-1)), 64},
+	{"add", wasm.AddI64(wasm.ConstI64(1<<40), wasm.ConstI64(5)), 1<<40 + 5},
+	{"sub", wasm.SubI64(wasm.ConstI64(1), wasm.ConstI64(5)), -4},
+	{"mul", wasm.MulI64(wasm.ConstI64(1<<32), wasm.ConstI64(3)), 3 << 32},
+	{"div signed", wasm.DivSI64(wasm.ConstI64(-7), wasm.ConstI64(2)), -3},
+	{"div unsigned", wasm.DivUI64(wasm.ConstI64(-2), wasm.ConstI64(2)), 1<<63 - 1},
+	{"rem signed", wasm.RemSI64(wasm.ConstI64(-7), wasm.ConstI64(2)), -1},
+	{"rem unsigned", wasm.RemUI64(wasm.ConstI64(7), wasm.ConstI64(4)), 3},
+	{"and", wasm.AndI64(wasm.ConstI64(6), wasm.ConstI64(3)), 2},
+	{"or", wasm.OrI64(wasm.ConstI64(6), wasm.ConstI64(3)), 7},
+	{"xor", wasm.XorI64(wasm.ConstI64(6), wasm.ConstI64(3)), 5},
+	{"shl", wasm.ShlI64(wasm.ConstI64(3), wasm.ConstI64(40)), 3 << 40},
+	{"shr signed", wasm.ShrSI64(wasm.ConstI64(-16), wasm.ConstI64(2)), -4},
+	{"shr unsigned", wasm.ShrUI64(wasm.ConstI64(-16), wasm.ConstI64(60)), 15},
+	{"rotl", wasm.RotlI64(wasm.ConstI64(-1<<63), wasm.ConstI64(1)), 1},
+	{"rotr", wasm.RotrI64(wasm.ConstI64(1), wasm.ConstI64(1)), -1 << 63},
+	{"from i32", wasm.I32ToI64(wasm.ConstI32(-1)), -1},
+	{"from u32", wasm.UI32ToI64(wasm.ConstI32(-1)), 1<<32 - 1},
+	{"from f32", wasm.F32ToI64(wasm.ConstF32(-1e10)), -1e10},
+	{"from f32 unsigned", wasm.F32ToUI64(wasm.ConstF32(-1)), 0},
+	{"from f64", wasm.F64ToI64(wasm.ConstF64(1e300)), 1<<63 - 1},
+	{"from f64 unsigned", wasm.F64ToUI64(wasm.ConstF64(3.5)), 3},
+	{"f64 bits", wasm.F64Bits(wasm.ConstF64(1)), 0x3FF0000000000000},
 }
 
 var opvec4f32Tests = []struct {
@@ -196,6 +559,320 @@ var opvec4f32Tests = []struct {
 			wasm.ConstVec4F32{3, 2, -3, 1}),
 		expect: [4]float32{12, 16, 9, 25},
 	},
+	{
+		what: "select true",
+		assign: wasm.SelectVec4F32(
+			wasm.SplatMask4(wasm.ConstBool(true)),
+			wasm.ConstVec4F32{12, 16, 9, 25},
+			wasm.ConstVec4F32{3, 2, -3, 1}),
+		expect: [4]float32{12, 16, 9, 25},
+	},
+	{
+		what: "select false",
+		assign: wasm.SelectVec4F32(
+			wasm.SplatMask4(wasm.ConstBool(false)),
+			wasm.ConstVec4F32{12, 16, 9, 25},
+			wasm.ConstVec4F32{3, 2, -3, 1}),
+		expect: [4]float32{3, 2, -3, 1},
+	},
+	{
+		what:   "from vec4i32",
+		assign: wasm.Vec4I32ToVec4F32(wasm.ConstVec4I32{1, -2, 3, -4}),
+		expect: [4]float32{1, -2, 3, -4},
+	},
+	{
+		what:   "from vec4i32 unsigned",
+		assign: wasm.UVec4I32ToVec4F32(wasm.ConstVec4I32{1, -1, 3, 4}),
+		expect: [4]float32{1, 4294967296, 3, 4},
+	},
+	{
+		what:   "from bits",
+		assign: wasm.Vec4F32FromBits(wasm.SplatVec4I32(wasm.ConstI32(0x40000000))),
+		expect: [4]float32{2, 2, 2, 2},
+	},
+	{
+		what:   "normalize",
+		assign: wasm.NormalizeVec4F32(wasm.ConstVec4F32{0, 3, 0, 4}),
+		expect: [4]float32{0, 0.6, 0, 0.8},
+	},
+	{
+		what: "cross",
+		assign: wasm.CrossVec3(
+			wasm.ConstVec4F32{1, 0, 0, 1},
+			wasm.ConstVec4F32{0, 1, 0, 1}),
+		expect: [4]float32{0, 0, 1, 0},
+	},
+	{
+		what: "cross general",
+		assign: wasm.CrossVec3(
+			wasm.ConstVec4F32{1, 2, 3, 0},
+			wasm.ConstVec4F32{4, 5, 6, 0}),
+		expect: [4]float32{-3, 6, -3, 0},
+	},
+	{
+		what:   "mat4 column",
+		assign: wasm.ColumnMat4F32(wasm.ConstMat4F32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, 2),
+		expect: [4]float32{8, 9, 10, 11},
+	},
+	{
+		what: "mat4 from columns",
+		assign: wasm.ColumnMat4F32(wasm.Mat4F32FromColumns(
+			wasm.ConstVec4F32{1, 2, 3, 4},
+			wasm.ConstVec4F32{5, 6, 7, 8},
+			wasm.ConstVec4F32{9, 10, 11, 12},
+			wasm.ConstVec4F32{13, 14, 15, 16}), 3),
+		expect: [4]float32{13, 14, 15, 16},
+	},
+	{
+		what: "translate",
+		assign: wasm.MulMat4Vec4(
+			wasm.TranslationMat4(wasm.ConstF32(1), wasm.ConstF32(2), wasm.ConstF32(3)),
+			wasm.ConstVec4F32{1, 1, 1, 1}),
+		expect: [4]float32{2, 3, 4, 1},
+	},
+	{
+		what: "scale",
+		assign: wasm.MulMat4Vec4(
+			wasm.ScaleMat4(wasm.ConstF32(2), wasm.ConstF32(3), wasm.ConstF32(4)),
+			wasm.ConstVec4F32{1, 1, 1, 1}),
+		expect: [4]float32{2, 3, 4, 1},
+	},
+	{
+		what: "mat4 mul",
+		assign: wasm.MulMat4Vec4(
+			wasm.MulMat4(
+				wasm.TranslationMat4(wasm.ConstF32(1), wasm.ConstF32(0), wasm.ConstF32(0)),
+				wasm.ScaleMat4(wasm.ConstF32(2), wasm.ConstF32(2), wasm.ConstF32(2))),
+			wasm.ConstVec4F32{1, 1, 1, 1}),
+		expect: [4]float32{3, 2, 2, 1},
+	},
+	{
+		what:   "mat4 identity",
+		assign: wasm.MulMat4Vec4(wasm.IdentityMat4F32, wasm.ConstVec4F32{1, 2, 3, 4}),
+		expect: [4]float32{1, 2, 3, 4},
+	},
+	{
+		what: "transpose",
+		assign: wasm.ColumnMat4F32(wasm.TransposeMat4(
+			wasm.ConstMat4F32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}), 1),
+		expect: [4]float32{1, 5, 9, 13},
+	},
+	{
+		what: "transpose last",
+		assign: wasm.ColumnMat4F32(wasm.TransposeMat4(
+			wasm.ConstMat4F32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}), 3),
+		expect: [4]float32{3, 7, 11, 15},
+	},
+	{
+		what: "inverse",
+		assign: wasm.MulMat4Vec4(
+			wasm.InverseMat4(wasm.MulMat4(
+				wasm.TranslationMat4(wasm.ConstF32(1), wasm.ConstF32(2), wasm.ConstF32(3)),
+				wasm.ScaleMat4(wasm.ConstF32(2), wasm.ConstF32(4), wasm.ConstF32(0.5)))),
+			wasm.ConstVec4F32{3, 6, 3.5, 1}),
+		expect: [4]float32{1, 1, 1, 1},
+	},
+	{
+		what: "inverse general",
+		assign: wasm.ColumnMat4F32(wasm.MulMat4(
+			wasm.ConstMat4F32{2, 1, 0, 1, 1, 1, 0, 0, 0, 3, 1, 0, 0, 0, 2, 1},
+			wasm.InverseMat4(wasm.ConstMat4F32{2, 1, 0, 1, 1, 1, 0, 0, 0, 3, 1, 0, 0, 0, 2, 1})), 1),
+		expect: [4]float32{0, 1, 0, 0},
+	},
+	{
+		what: "rotate x",
+		assign: wasm.MulMat4Vec4(
+			wasm.RotationXMat4(wasm.ConstF32(1), wasm.ConstF32(0)),
+			wasm.ConstVec4F32{0, 1, 0, 0}),
+		expect: [4]float32{0, 0, 1, 0},
+	},
+	{
+		what: "rotate y",
+		assign: wasm.MulMat4Vec4(
+			wasm.RotationYMat4(wasm.ConstF32(1), wasm.ConstF32(0)),
+			wasm.ConstVec4F32{0, 0, 1, 0}),
+		expect: [4]float32{1, 0, 0, 0},
+	},
+	{
+		what: "rotate z",
+		assign: wasm.MulMat4Vec4(
+			wasm.RotationZMat4(wasm.ConstF32(1), wasm.ConstF32(0)),
+			wasm.ConstVec4F32{1, 0, 0, 1}),
+		expect: [4]float32{0, 1, 0, 1},
+	},
+	{
+		what: "rotate quaternion",
+		assign: wasm.MulMat4Vec4(
+			wasm.RotationMat4(wasm.ConstVec4F32{0, 0, 1, 0}),
+			wasm.ConstVec4F32{1, 2, 3, 1}),
+		expect: [4]float32{-1, -2, 3, 1},
+	},
+	{
+		what: "perspective",
+		assign: wasm.MulMat4Vec4(
+			wasm.PerspectiveMat4(wasm.ConstF32(1), wasm.ConstF32(2), wasm.ConstF32(1), wasm.ConstF32(3)),
+			wasm.ConstVec4F32{2, 1, -1, 1}),
+		expect: [4]float32{1, 1, -1, 1},
+	},
+	{
+		what: "select mask",
+		assign: wasm.SelectVec4F32(
+			wasm.ConstMask4{true, false, true, false},
+			wasm.ConstVec4F32{12, 16, 9, 25},
+			wasm.ConstVec4F32{3, 2, -3, 1}),
+		expect: [4]float32{12, 2, 9, 1},
+	},
+	{
+		what: "clamp",
+		assign: wasm.SelectVec4F32(
+			wasm.GtVec4F32(wasm.ConstVec4F32{-1, 0.5, 2, 1}, wasm.ConstVec4F32{1, 1, 1, 1}),
+			wasm.ConstVec4F32{1, 1, 1, 1},
+			wasm.ConstVec4F32{-1, 0.5, 2, 1}),
+		expect: [4]float32{-1, 0.5, 1, 1},
+	},
+	{
+		what: "threshold",
+		assign: wasm.MaskVec4F32(
+			wasm.GeVec4F32(wasm.ConstVec4F32{0.2, 0.7, 0.5, 0.9}, wasm.SplatVec4F32(wasm.ConstF32(0.5))),
+			wasm.ConstVec4F32{0.2, 0.7, 0.5, 0.9}),
+		expect: [4]float32{0, 0.7, 0.5, 0.9},
+	},
+	{
+		what: "bitwise abs",
+		assign: wasm.AndNotVec4F32(
+			wasm.ConstVec4F32{-1, 2, -3, 4},
+			wasm.SplatVec4F32(wasm.ConstF32(float32(math.Copysign(0, -1))))),
+		expect: [4]float32{1, 2, 3, 4},
+	},
+	{
+		what: "bitwise neg",
+		assign: wasm.XorVec4F32(
+			wasm.ConstVec4F32{-1, 2, -3, 4},
+			wasm.SplatVec4F32(wasm.ConstF32(float32(math.Copysign(0, -1))))),
+		expect: [4]float32{1, -2, 3, -4},
+	},
+	{
+		what: "bitwise and or",
+		assign: wasm.OrVec4F32(
+			wasm.AndVec4F32(wasm.ConstVec4F32{1, 2, 3, 4}, wasm.ConstVec4F32{}),
+			wasm.ConstVec4F32{5, 6, 7, 8}),
+		expect: [4]float32{5, 6, 7, 8},
+	},
+	{
+		what: "if expression",
+		assign: wasm.IfExprVec4F32{
+			Condition: wasm.GtF32(wasm.ConstF32(1), wasm.ConstF32(2)),
+			Then:      wasm.ConstVec4F32{12, 16, 9, 25},
+			Else:      wasm.ConstVec4F32{3, 2, -3, 1},
+		},
+		expect: [4]float32{3, 2, -3, 1},
+	},
+	{
+		what:   "splat",
+		assign: wasm.SplatVec4F32(wasm.ConstF32(2.5)),
+		expect: [4]float32{2.5, 2.5, 2.5, 2.5},
+	},
+	{
+		what: "replace lane",
+		assign: wasm.ReplaceLaneVec4F32(
+			wasm.ConstVec4F32{1, 2, 3, 4}, 2, wasm.ConstF32(-1)),
+		expect: [4]float32{1, 2, -1, 4},
+	},
+	{
+		what: "from lanes",
+		assign: wasm.Vec4F32FromLanes(
+			wasm.ConstF32(5), wasm.ConstF32(6),
+			wasm.AddF32(wasm.ConstF32(3), wasm.ConstF32(4)), wasm.ConstF32(8)),
+		expect: [4]float32{5, 6, 7, 8},
+	},
+	{
+		what: "shuffle",
+		assign: wasm.ShuffleVec4F32(
+			wasm.ConstVec4F32{1, 2, 3, 4},
+			wasm.ConstVec4F32{5, 6, 7, 8},
+			[4]int{0, 5, 2, 7}),
+		expect: [4]float32{1, 6, 3, 8},
+	},
+	{
+		what: "shuffle reverse",
+		assign: wasm.ShuffleVec4F32(
+			wasm.ConstVec4F32{1, 2, 3, 4},
+			wasm.ConstVec4F32{},
+			[4]int{3, 2, 1, 0}),
+		expect: [4]float32{4, 3, 2, 1},
+	},
+}
+
+// mathTests are compared against the math package, allowing
+// the error documented for each function.
+var mathTests = []struct {
+	what   string
+	assign wasm.F32
+	expect float64
+	ulp    float64
+}{
+	{"sin", wasm.SinF32(wasm.ConstF32(0.5)), math.Sin(0.5), 1.5},
+	{"sin negative", wasm.SinF32(wasm.ConstF32(-2.5)), math.Sin(-2.5), 1.5},
+	{"sin large", wasm.SinF32(wasm.ConstF32(1000)), math.Sin(1000), 1.5},
+	{"sin 1e6", wasm.SinF32(wasm.ConstF32(1e6)), math.Sin(1e6), 1.5},
+	{"sin 1e30", wasm.SinF32(wasm.ConstF32(1e30)), math.Sin(float64(float32(1e30))), 1.5},
+	{"sin max", wasm.SinF32(wasm.ConstF32(math.MaxFloat32)), math.Sin(math.MaxFloat32), 1.5},
+	{"sin inf", wasm.SinF32(wasm.ConstF32(float32(math.Inf(-1)))), math.NaN(), 0},
+	{"sin negative zero", wasm.SinF32(wasm.ConstF32(float32(math.Copysign(0, -1)))), math.Copysign(0, -1), 0},
+	{"cos", wasm.CosF32(wasm.ConstF32(0.5)), math.Cos(0.5), 1.5},
+	{"cos quadrant", wasm.CosF32(wasm.ConstF32(4)), math.Cos(4), 1.5},
+	{"cos 1e10", wasm.CosF32(wasm.ConstF32(1e10)), math.Cos(1e10), 1.5},
+	{"cos 1e30", wasm.CosF32(wasm.ConstF32(1e30)), math.Cos(float64(float32(1e30))), 1.5},
+	{"cos negative zero", wasm.CosF32(wasm.ConstF32(float32(math.Copysign(0, -1)))), 1, 0},
+	{"tan", wasm.TanF32(wasm.ConstF32(1.2)), math.Tan(1.2), 2.5},
+	{"tan negative", wasm.TanF32(wasm.ConstF32(-7)), math.Tan(-7), 2.5},
+	{"tan 1e20", wasm.TanF32(wasm.ConstF32(1e20)), math.Tan(float64(float32(1e20))), 2.5},
+	{"tan negative zero", wasm.TanF32(wasm.ConstF32(float32(math.Copysign(0, -1)))), math.Copysign(0, -1), 0},
+	{"atan2", wasm.Atan2F32(wasm.ConstF32(1), wasm.ConstF32(2)), math.Atan2(1, 2), 2},
+	{"atan2 steep", wasm.Atan2F32(wasm.ConstF32(5), wasm.ConstF32(-1)), math.Atan2(5, -1), 2},
+	{"atan2 third quadrant", wasm.Atan2F32(wasm.ConstF32(-1), wasm.ConstF32(-3)), math.Atan2(-1, -3), 2},
+	{"atan2 near tan(pi/8)", wasm.Atan2F32(wasm.ConstF32(-0.0079778135), wasm.ConstF32(0.018837398)), math.Atan2(float64(float32(-0.0079778135)), float64(float32(0.018837398))), 1},
+	{"atan2 zero", wasm.Atan2F32(wasm.ConstF32(0), wasm.ConstF32(-1)), math.Pi, 1},
+	{"atan2 origin", wasm.Atan2F32(wasm.ConstF32(0), wasm.ConstF32(0)), 0, 0},
+	{"exp", wasm.ExpF32(wasm.ConstF32(1)), math.E, 1},
+	{"exp negative", wasm.ExpF32(wasm.ConstF32(-20.5)), math.Exp(-20.5), 1},
+	{"exp subnormal", wasm.ExpF32(wasm.ConstF32(-95)), math.Exp(-95), 1},
+	{"exp overflow", wasm.ExpF32(wasm.ConstF32(100)), math.Inf(1), 0},
+	{"exp underflow", wasm.ExpF32(wasm.ConstF32(-200)), 0, 0},
+	{"log", wasm.LogF32(wasm.ConstF32(10)), math.Log(10), 1},
+	{"log small", wasm.LogF32(wasm.ConstF32(0.75)), math.Log(0.75), 1},
+	{"log subnormal", wasm.LogF32(wasm.ConstF32(1e-40)), math.Log(float64(float32(1e-40))), 1},
+	{"log zero", wasm.LogF32(wasm.ConstF32(0)), math.Inf(-1), 0},
+	{"log negative", wasm.LogF32(wasm.ConstF32(-1)), math.NaN(), 0},
+	{"log inf", wasm.LogF32(wasm.ConstF32(float32(math.Inf(1)))), math.Inf(1), 0},
+	{"pow", wasm.PowF32(wasm.ConstF32(2), wasm.ConstF32(0.5)), math.Sqrt2, 2},
+	{"pow negative odd", wasm.PowF32(wasm.ConstF32(-2), wasm.ConstF32(3)), -8, 4},
+	{"pow negative even", wasm.PowF32(wasm.ConstF32(-2), wasm.ConstF32(4)), 16, 4},
+	{"pow negative fraction", wasm.PowF32(wasm.ConstF32(-2), wasm.ConstF32(0.5)), math.NaN(), 0},
+	{"pow zero exponent", wasm.PowF32(wasm.ConstF32(0), wasm.ConstF32(0)), 1, 0},
+	{"pow zero base", wasm.PowF32(wasm.ConstF32(0), wasm.ConstF32(2)), 0, 0},
+	{"pow minus one inf", wasm.PowF32(wasm.ConstF32(-1), wasm.ConstF32(float32(math.Inf(1)))), 1, 0},
+	{"pow minus one negative inf", wasm.PowF32(wasm.ConstF32(-1), wasm.ConstF32(float32(math.Inf(-1)))), 1, 0},
+	{"pow one nan", wasm.PowF32(wasm.ConstF32(1), wasm.ConstF32(float32(math.NaN()))), 1, 0},
+	{"pow inf", wasm.PowF32(wasm.ConstF32(-2), wasm.ConstF32(float32(math.Inf(1)))), math.Inf(1), 0},
+	{"tanh", wasm.TanhF32(wasm.ConstF32(0.3)), math.Tanh(0.3), 2},
+	{"tanh large", wasm.TanhF32(wasm.ConstF32(-2)), math.Tanh(-2), 2},
+	{"tanh saturated", wasm.TanhF32(wasm.ConstF32(50)), 1, 0},
+	{"tanh negative zero", wasm.TanhF32(wasm.ConstF32(float32(math.Copysign(0, -1)))), math.Copysign(0, -1), 0},
+	{"sin of cos", wasm.SinF32(wasm.CosF32(wasm.ConstF32(1))), math.Sin(float64(float32(math.Cos(1)))), 1.5},
+}
+
+// withinULP returns whether got is within ulp units in the
+// last place of the float32 nearest to expect. Zeros must have
+// the sign of expect.
+func withinULP(got float32, expect float64, ulp float64) bool {
+	want := float32(expect)
+	if math.IsNaN(float64(want)) || math.IsInf(float64(want), 0) || want == 0 {
+		return math.Float32bits(got) == math.Float32bits(want) ||
+			math.IsNaN(float64(got)) && math.IsNaN(float64(want))
+	}
+	step := math.Nextafter32(want, float32(math.Inf(1))) - want
+	return math.Abs(float64(got-want)) <= ulp*float64(step)
 }
 
 var forRangeTests = []struct {
@@ -345,6 +1022,27 @@ var ifElseTests = []struct {
 	},
 }
 
+var ifTests = []struct {
+	what      string
+	condition wasm.Bool
+	expect    float32
+}{
+	{"true", wasm.ConstBool(true), 1},
+	{"false", wasm.ConstBool(false), -1},
+	{"eq", wasm.EqF32(wasm.ConstF32(2), wasm.ConstF32(2)), 1},
+	{"ne", wasm.NeF32(wasm.ConstF32(2), wasm.ConstF32(2)), -1},
+	{"lt", wasm.LtF32(wasm.ConstF32(-3), wasm.ConstF32(2)), 1},
+	{"gt", wasm.GtF32(wasm.ConstF32(-3), wasm.ConstF32(2)), -1},
+	{"le", wasm.LeF32(wasm.ConstF32(2), wasm.ConstF32(2)), 1},
+	{"ge", wasm.GeF32(wasm.ConstF32(1e30), wasm.ConstF32(2)), 1},
+	{"nan", wasm.GeF32(wasm.DivF32(wasm.ConstF32(0), wasm.ConstF32(0)), wasm.ConstF32(0)), -1},
+	{"and", wasm.And(wasm.ConstBool(true), wasm.ConstBool(false)), -1},
+	{"or", wasm.Or(wasm.ConstBool(true), wasm.ConstBool(false)), 1},
+	{"not", wasm.Not(wasm.LtI32(wasm.ConstI32(1), wasm.ConstI32(2))), -1},
+	{"i64", wasm.GtUI64(wasm.ConstI64(-1), wasm.ConstI64(0)), 1},
+	{"f64", wasm.LtF64(wasm.ConstF64(1), wasm.ConstF64(1)), -1},
+}
+
 type buildContext struct {
 	t     *testing.T
 	imp   *wasmer.ImportObject
@@ -453,8 +1151,7 @@ var tcs = []struct {
 			m.Export("main", f)
 			x := wasmer.NewGlobal(
 				b.store,
-				wasmer.NewGlobalType(
-					wasmer.NewValueType(wasmer.F32), wasmer.MUTABLE),
+				globalType(wasmer.F32, wasmer.MUTABLE),
 				wasmer.NewF32(float32(5)),
 			)
 			*b.data = x
@@ -757,8 +1454,7 @@ var tcs = []struct {
 			ptr := int64(10 << 32) // offset is zero, length is 10 floats
 			vecPtr := wasmer.NewGlobal(
 				ctx.store,
-				wasmer.NewGlobalType(
-					wasmer.NewValueType(wasmer.I64), wasmer.IMMUTABLE),
+				globalType(wasmer.I64, wasmer.IMMUTABLE),
 				wasmer.NewI64(ptr),
 			)
 			ctx.imp.Register("_sf32", map[string]wasmer.IntoExtern{
@@ -801,8 +1497,7 @@ var tcs = []struct {
 			ptr := int64(10 << 32) // offset is zero, length is 10 floats
 			vecPtr := wasmer.NewGlobal(
 				ctx.store,
-				wasmer.NewGlobalType(
-					wasmer.NewValueType(wasmer.I64), wasmer.IMMUTABLE),
+				globalType(wasmer.I64, wasmer.IMMUTABLE),
 				wasmer.NewI64(ptr),
 			)
 			ctx.imp.Register("_sf32", map[string]wasmer.IntoExtern{
@@ -856,8 +1551,7 @@ var tcs = []struct {
 			ptr := int64(10 << 32) // offset is zero, length is 10 floats
 			vecPtr := wasmer.NewGlobal(
 				ctx.store,
-				wasmer.NewGlobalType(
-					wasmer.NewValueType(wasmer.I64), wasmer.IMMUTABLE),
+				globalType(wasmer.I64, wasmer.IMMUTABLE),
 				wasmer.NewI64(ptr),
 			)
 			ctx.imp.Register("_sf32", map[string]wasmer.IntoExtern{
@@ -876,48 +1570,2097 @@ var tcs = []struct {
 			}
 		},
 	},
-}
-
-func TestWasm(t *testing.T) {
-	for _, tc := range tcs {
-		t.Run(tc.what, func(t *testing.T) {
-			var data interface{}
-			engine := wasmer.NewEngine()
-			store := wasmer.NewStore(engine)
-			importObject := wasmer.NewImportObject()
-			mod := tc.build(buildContext{
-				t:     t,
-				imp:   importObject,
-				data:  &data,
-				store: store,
+	{
+		what: "a typed function",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			add := m.TypedFunction(wasm.Signature{
+				Params:  []wasm.Type{wasm.TypeF32, wasm.TypeF32},
+				Results: []wasm.Type{wasm.TypeF32},
 			})
-			buf, err := mod.Compile()
+			add.Body(wasm.AddF32(add.ParamF32(0), add.ParamF32(1)))
+			m.Export("add", add)
+			f := m.Function()
+			f.Body(wasm.AssignF32(o, wasm.CallF32(add, wasm.ConstF32(3), o)))
+			m.Export("main", f)
+			return m
+		},
+		test: func(ctx testContext) {
+			add, _ := ctx.inst.Exports.GetFunction("add")
+			v, err := add(float32(2), float32(5))
 			if err != nil {
-				t.Error(err)
+				ctx.t.Fatal(err)
 			}
-			err = wasmer.ValidateModule(store, buf)
-			if err != nil {
-				t.Error(err)
+			if v.(float32) != 7 {
+				ctx.t.Errorf("expected %f, got %f", 7.0, v.(float32))
 			}
-			module, err := wasmer.NewModule(store, buf)
+			main, _ := ctx.inst.Exports.GetFunction("main")
+			main()
+			main()
+			res, _ := ctx.inst.Exports.GetGlobal("o")
+			rv, _ := res.Get()
+			if rv.(float32) != 6 {
+				ctx.t.Errorf("expected %f, got %f", 6.0, rv.(float32))
+			}
+		},
+	},
+	{
+		what: "a recursive typed function",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			fib := m.TypedFunction(wasm.Signature{
+				Params:  []wasm.Type{wasm.TypeF32},
+				Results: []wasm.Type{wasm.TypeF32},
+			})
+			n := fib.ParamF32(0)
+			fib.Body(
+				wasm.IfF32{
+					Condition: wasm.MaxF32(
+						wasm.SubF32(wasm.ConstF32(2), n),
+						wasm.ConstF32(0),
+					),
+					Then: []wasm.Instruction{wasm.ReturnValue(n)},
+				},
+				wasm.AddF32(
+					wasm.CallF32(fib, wasm.SubF32(n, wasm.ConstF32(1))),
+					wasm.CallF32(fib, wasm.SubF32(n, wasm.ConstF32(2))),
+				),
+			)
+			m.Export("fib", fib)
+			return m
+		},
+		test: func(ctx testContext) {
+			fib, _ := ctx.inst.Exports.GetFunction("fib")
+			v, err := fib(float32(10))
 			if err != nil {
-				t.Error(err)
+				ctx.t.Fatal(err)
 			}
-			if module == nil {
-				return
+			if v.(float32) != 55 {
+				ctx.t.Errorf("expected %f, got %f", 55.0, v.(float32))
 			}
-			inst, err := wasmer.NewInstance(module, importObject)
+		},
+	},
+	{
+		what: "a function with vec4f32 params",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			scale := m.TypedFunction(wasm.Signature{
+				Params:  []wasm.Type{wasm.TypeVec4F32, wasm.TypeVec4F32},
+				Results: []wasm.Type{wasm.TypeVec4F32},
+			})
+			loc := scale.LocalF32()
+			scale.Body(
+				wasm.AssignF32(loc, wasm.ConstF32(1)),
+				wasm.ReturnValue(wasm.MulVec4F32(
+					scale.ParamVec4F32(0),
+					scale.ParamVec4F32(1),
+				)),
+			)
+			body := make([]wasm.Instruction, 4)
+			for i := range body {
+				g := m.GlobalF32(0)
+				m.Export(fmt.Sprintf("o%d", i), g)
+				body[i] = wasm.AssignF32(g, wasm.ExtractLaneVec4F32(
+					wasm.CallVec4F32(scale,
+						wasm.ConstVec4F32{1, 2, 3, 4},
+						wasm.ConstVec4F32{2, 2, -1, 0.5},
+					), i))
+			}
+			f := m.Function()
+			f.Body(body...)
+			m.Export("main", f)
+			return m
+		},
+		test: func(ctx testContext) {
+			main, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := main(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			exp := [4]float32{2, 4, -3, 2}
+			for i := range exp {
+				g, _ := ctx.inst.Exports.GetGlobal(fmt.Sprintf("o%d", i))
+				v, _ := g.Get()
+				if v.(float32) != exp[i] {
+					ctx.t.Errorf("[%d] expected %f, got %f", i, exp[i], v.(float32))
+				}
+			}
+		},
+	},
+	{
+		what: "typed imported functions",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			log := m.ImportTypedFunction("env", "log", wasm.Signature{
+				Params: []wasm.Type{wasm.TypeF32},
+			})
+			noise := m.ImportTypedFunction("env", "noise", wasm.Signature{
+				Params:  []wasm.Type{wasm.TypeF32, wasm.TypeF32},
+				Results: []wasm.Type{wasm.TypeF32},
+			})
+			f := m.Function()
+			f.Body(
+				wasm.AssignF32(o, wasm.CallF32(noise, wasm.ConstF32(3), wasm.ConstF32(4))),
+				wasm.Call(log, o),
+				wasm.Call(noise, o, o),
+			)
+			m.Export("main", f)
+
+			logged := new([]float32)
+			*ctx.data = logged
+			f32 := wasmer.NewValueTypes(wasmer.F32)
+			f32f32 := wasmer.NewValueTypes(wasmer.F32, wasmer.F32)
+			ctx.imp.Register("env", map[string]wasmer.IntoExtern{
+				"log": wasmer.NewFunction(
+					ctx.store,
+					wasmer.NewFunctionType(f32, nil),
+					func(args []wasmer.Value) ([]wasmer.Value, error) {
+						*logged = append(*logged, args[0].F32())
+						return nil, nil
+					},
+				),
+				"noise": wasmer.NewFunction(
+					ctx.store,
+					wasmer.NewFunctionType(f32f32, f32),
+					func(args []wasmer.Value) ([]wasmer.Value, error) {
+						return []wasmer.Value{
+							wasmer.NewF32(args[0].F32() * args[1].F32()),
+						}, nil
+					},
+				),
+			})
+			return m
+		},
+		test: func(ctx testContext) {
+			main, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := main(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			res, _ := ctx.inst.Exports.GetGlobal("o")
+			v, _ := res.Get()
+			if v.(float32) != 12 {
+				ctx.t.Errorf("expected %f, got %f", 12.0, v.(float32))
+			}
+			logged := *(*ctx.data).(*[]float32)
+			if len(logged) != 1 || logged[0] != 12 {
+				ctx.t.Errorf("expected [12] to be logged, got %v", logged)
+			}
+		},
+	},
+	{
+		what: "exported memory",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			m.Export("memory", m.Memory(2, 4))
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			vec := m.ImportSliceF32("wowee")
+			f := m.Function()
+			f.Body(wasm.AssignF32(o, vec.IndexF32(wasm.ConstF32(2))))
+			m.Export("main", f)
+			ptr := int64(4<<32 | 8) // offset is 8 bytes, length is 4 floats
+			ctx.imp.Register("_sf32", map[string]wasmer.IntoExtern{
+				"wowee": wasmer.NewGlobal(
+					ctx.store,
+					globalType(wasmer.I64, wasmer.IMMUTABLE),
+					wasmer.NewI64(ptr),
+				),
+			})
+			return m
+		},
+		test: func(ctx testContext) {
+			mem, err := ctx.inst.Exports.GetMemory("memory")
 			if err != nil {
-				t.Error(err)
+				ctx.t.Fatal(err)
 			}
-			if tc.test != nil {
-				tc.test(testContext{
-					t:    t,
-					inst: inst,
-					imp:  importObject,
-					data: &data,
+			if mem.Size() != 2 {
+				ctx.t.Errorf("expected 2 pages, got %d", mem.Size())
+			}
+			arr := [6]float32{0, 0, 1, 2, 3, 4}
+			dst := bytes.NewBuffer(mem.Data()[:0])
+			binary.Write(dst, binary.LittleEndian, arr[:])
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			res, _ := ctx.inst.Exports.GetGlobal("o")
+			v, _ := res.Get()
+			if v.(float32) != 3 {
+				ctx.t.Errorf("expected %f, got %f", 3.0, v.(float32))
+			}
+		},
+	},
+	{
+		what: "imported memory with custom name",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			m.ImportMemory("host", "heap", 1, 2)
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			vec := m.ImportSliceF32("wowee")
+			f := m.Function()
+			f.Body(wasm.AssignF32(o, vec.LengthF32()))
+			m.Export("main", f)
+			limit, _ := wasmer.NewLimits(1, 2)
+			ctx.imp.Register("host", map[string]wasmer.IntoExtern{
+				"heap": wasmer.NewMemory(
+					ctx.store,
+					wasmer.NewMemoryType(limit),
+				),
+			})
+			ctx.imp.Register("_sf32", map[string]wasmer.IntoExtern{
+				"wowee": wasmer.NewGlobal(
+					ctx.store,
+					globalType(wasmer.I64, wasmer.IMMUTABLE),
+					wasmer.NewI64(int64(7<<32)),
+				),
+			})
+			return m
+		},
+		test: func(ctx testContext) {
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			res, _ := ctx.inst.Exports.GetGlobal("o")
+			v, _ := res.Get()
+			if v.(float32) != 7 {
+				ctx.t.Errorf("expected %f, got %f", 7.0, v.(float32))
+			}
+		},
+	},
+	{
+		what: "data segments",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			m.Export("memory", m.Memory(1, 0))
+			m.DataAt(4, []byte{1, 2, 3})
+			coef := m.DataF32([]float32{0.5, 1.5, 2.5, 3.5})
+			at := m.DataF32At(64, []float32{10, 20})
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			sum := m.GlobalF32(0)
+			m.Export("sum", sum)
+			f := m.Function()
+			f.Body(
+				wasm.AssignF32(o, wasm.AddF32(
+					coef.IndexF32(wasm.ConstF32(2)),
+					at.IndexF32(wasm.ConstF32(1)),
+				)),
+				wasm.SliceF32RangeF32{
+					Slice: coef,
+					Do: func(v wasm.F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignF32(sum, wasm.AddF32(sum, v)),
+						}
+					},
+				},
+			)
+			m.Export("main", f)
+			return m
+		},
+		test: func(ctx testContext) {
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			for name, exp := range map[string]float32{"o": 22.5, "sum": 8} {
+				g, _ := ctx.inst.Exports.GetGlobal(name)
+				v, _ := g.Get()
+				if v.(float32) != exp {
+					ctx.t.Errorf("%s: expected %f, got %f", name, exp, v.(float32))
+				}
+			}
+			mem, _ := ctx.inst.Exports.GetMemory("memory")
+			if got := mem.Data()[4:7]; !bytes.Equal(got, []byte{1, 2, 3}) {
+				ctx.t.Errorf("expected bytes at offset 4, got %v", got)
+			}
+		},
+	},
+	{
+		what: "mutable slice",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			m.Export("memory", m.Memory(1, 0))
+			vec := m.ImportSliceF32("wowee")
+			f := m.Function()
+			loc := f.LocalF32()
+			f.Body(
+				wasm.AssignF32(loc, wasm.ConstF32(2)),
+				wasm.MutableSliceF32RangeF32{
+					Slice: vec,
+					Begin: wasm.ConstF32(1),
+					Do: func(v wasm.MutableF32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignF32(v, wasm.MulF32(v, loc)),
+						}
+					},
+				},
+				vec.SetF32(wasm.ConstF32(0), wasm.ConstF32(-1)),
+			)
+			m.Export("main", f)
+			ptr := int64(4<<32 | 16) // offset is 16 bytes, length is 4 floats
+			ctx.imp.Register("_sf32", map[string]wasmer.IntoExtern{
+				"wowee": wasmer.NewGlobal(
+					ctx.store,
+					globalType(wasmer.I64, wasmer.IMMUTABLE),
+					wasmer.NewI64(ptr),
+				),
+			})
+			return m
+		},
+		test: func(ctx testContext) {
+			mem, _ := ctx.inst.Exports.GetMemory("memory")
+			arr := [6]float32{0, 0, 0, 0, 1, 2}
+			binary.Write(bytes.NewBuffer(mem.Data()[:0]), binary.LittleEndian, arr[:])
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			got := make([]float32, 8)
+			binary.Read(bytes.NewReader(mem.Data()), binary.LittleEndian, got)
+			exp := []float32{0, 0, 0, 0, -1, 4, 0, 0}
+			for i := range exp {
+				if got[i] != exp[i] {
+					ctx.t.Errorf("[%d] expected %f, got %f", i, exp[i], got[i])
+				}
+			}
+		},
+	},
+	{
+		what: "i32 ops",
+		build: func(b buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			out := m.GlobalI32(0)
+			m.Export("out", out)
+			for i, tc := range opi32Tests {
+				f := m.Function()
+				f.Body(wasm.AssignI32(out, tc.assign))
+				m.Export(fmt.Sprintf("f%d", i), f)
+			}
+			return m
+		},
+		test: func(ctx testContext) {
+			t := ctx.t
+			g, _ := ctx.inst.Exports.GetGlobal("out")
+			for i, tc := range opi32Tests {
+				f, _ := ctx.inst.Exports.GetFunction(fmt.Sprintf("f%d", i))
+				if _, err := f(); err != nil {
+					t.Errorf("failed to run i32op %q: %s", tc.what, err)
+				}
+				v, _ := g.Get()
+				if v.(int32) != tc.expect {
+					t.Errorf("%s: expected %d got %d", tc.what, tc.expect, v.(int32))
+				}
+			}
+		},
+	},
+	{
+		what: "i32 locals, imports and conversions",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			n := m.ImportI32("env", "n")
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			f := m.Function()
+			i := f.LocalI32()
+			f.Body(
+				wasm.AssignI32(i, wasm.ConstI32(0)),
+				wasm.ForRangeF32{
+					End: wasm.I32ToF32(n),
+					Do: func(wasm.F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignI32(i, wasm.AddI32(i, wasm.ConstI32(3))),
+						}
+					},
+				},
+				wasm.AssignI32(n, i),
+				wasm.AssignF32(o, wasm.AddF32(
+					wasm.UI32ToF32(i),
+					wasm.F32FromBits(wasm.ConstI32(0x3F000000)),
+				)),
+			)
+			m.Export("main", f)
+			x := wasmer.NewGlobal(
+				ctx.store,
+				globalType(wasmer.I32, wasmer.MUTABLE),
+				wasmer.NewI32(int32(4)),
+			)
+			*ctx.data = x
+			ctx.imp.Register("env", map[string]wasmer.IntoExtern{
+				"n": x,
+			})
+			return m
+		},
+		test: func(ctx testContext) {
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			res, _ := ctx.inst.Exports.GetGlobal("o")
+			v, _ := res.Get()
+			if v.(float32) != 12.5 {
+				ctx.t.Errorf("expected %f, got %f", 12.5, v.(float32))
+			}
+			n, _ := (*ctx.data).(*wasmer.Global).Get()
+			if n.(int32) != 12 {
+				ctx.t.Errorf("expected %d, got %d", 12, n.(int32))
+			}
+		},
+	},
+	{
+		what: "f64 ops",
+		build: func(b buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			out := m.GlobalF64(0)
+			m.Export("out", out)
+			for i, tc := range opf64Tests {
+				f := m.Function()
+				f.Body(wasm.AssignF64(out, tc.assign))
+				m.Export(fmt.Sprintf("f%d", i), f)
+			}
+			return m
+		},
+		test: func(ctx testContext) {
+			t := ctx.t
+			g, _ := ctx.inst.Exports.GetGlobal("out")
+			for i, tc := range opf64Tests {
+				f, _ := ctx.inst.Exports.GetFunction(fmt.Sprintf("f%d", i))
+				if _, err := f(); err != nil {
+					t.Errorf("failed to run f64op %q: %s", tc.what, err)
+				}
+				v, _ := g.Get()
+				if v.(float64) != tc.expect {
+					t.Errorf("%s: expected %v got %v", tc.what, tc.expect, v.(float64))
+				}
+			}
+		},
+	},
+	{
+		what: "f64 accumulation",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			step := m.ImportF64("env", "step")
+			sum := m.GlobalF64(0)
+			m.Export("sum", sum)
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			accumulate := m.TypedFunction(wasm.Signature{
+				Params:  []wasm.Type{wasm.TypeF64, wasm.TypeF32},
+				Results: []wasm.Type{wasm.TypeF64},
+			})
+			acc := accumulate.LocalF64()
+			accumulate.Body(
+				wasm.AssignF64(acc, accumulate.ParamF64(0)),
+				wasm.ForRangeF32{
+					End: accumulate.ParamF32(1),
+					Do: func(wasm.F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignF64(acc, wasm.AddF64(acc, step)),
+						}
+					},
+				},
+				acc,
+			)
+			f := m.Function()
+			f.Body(
+				wasm.AssignF64(sum, wasm.CallF64(accumulate, wasm.ConstF64(1e8), wasm.ConstF32(1000))),
+				wasm.AssignF32(o, wasm.F64ToF32(sum)),
+			)
+			m.Export("main", f)
+			ctx.imp.Register("env", map[string]wasmer.IntoExtern{
+				"step": wasmer.NewGlobal(
+					ctx.store,
+					globalType(wasmer.F64, wasmer.MUTABLE),
+					wasmer.NewF64(0.001),
+				),
+			})
+			return m
+		},
+		test: func(ctx testContext) {
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			sum, _ := ctx.inst.Exports.GetGlobal("sum")
+			v, _ := sum.Get()
+			if d := v.(float64) - (1e8 + 1); d > 1e-4 || d < -1e-4 {
+				ctx.t.Errorf("expected %f, got %f", 1e8+1, v.(float64))
+			}
+			o, _ := ctx.inst.Exports.GetGlobal("o")
+			ov, _ := o.Get()
+			if ov.(float32) != float32(1e8+1) {
+				ctx.t.Errorf("expected %f, got %f", float32(1e8+1), ov.(float32))
+			}
+		},
+	},
+	{
+		what: "i64 ops",
+		build: func(b buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			out := m.GlobalI64(0)
+			m.Export("out", out)
+			for i, tc := range opi64Tests {
+				f := m.Function()
+				f.Body(wasm.AssignI64(out, tc.assign))
+				m.Export(fmt.Sprintf("f%d", i), f)
+			}
+			return m
+		},
+		test: func(ctx testContext) {
+			t := ctx.t
+			g, _ := ctx.inst.Exports.GetGlobal("out")
+			for i, tc := range opi64Tests {
+				f, _ := ctx.inst.Exports.GetFunction(fmt.Sprintf("f%d", i))
+				if _, err := f(); err != nil {
+					t.Errorf("failed to run i64op %q: %s", tc.what, err)
+				}
+				v, _ := g.Get()
+				if v.(int64) != tc.expect {
+					t.Errorf("%s: expected %d got %d", tc.what, tc.expect, v.(int64))
+				}
+			}
+		},
+	},
+	{
+		what: "i64 hashing",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			seed := m.ImportI64("env", "seed")
+			// fnv-1a over the bytes 0..n-1
+			hash := m.TypedFunction(wasm.Signature{
+				Params:  []wasm.Type{wasm.TypeI64, wasm.TypeF32},
+				Results: []wasm.Type{wasm.TypeI64},
+			})
+			h := hash.LocalI64()
+			hash.Body(
+				wasm.AssignI64(h, hash.ParamI64(0)),
+				wasm.ForRangeF32{
+					End: hash.ParamF32(1),
+					Do: func(i wasm.F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignI64(h, wasm.MulI64(
+								wasm.XorI64(h, wasm.F32ToI64(i)),
+								wasm.ConstI64(1099511628211),
+							)),
+						}
+					},
+				},
+				wasm.ReturnValue(h),
+			)
+			m.Export("hash", hash)
+			out := m.GlobalI64(0)
+			m.Export("out", out)
+			f := m.Function()
+			f.Body(wasm.AssignI64(out, wasm.CallI64(hash, seed, wasm.ConstF32(4))))
+			m.Export("main", f)
+			ctx.imp.Register("env", map[string]wasmer.IntoExtern{
+				"seed": wasmer.NewGlobal(
+					ctx.store,
+					globalType(wasmer.I64, wasmer.MUTABLE),
+					wasmer.NewI64(int64(-3750763034362895579)),
+				),
+			})
+			return m
+		},
+		test: func(ctx testContext) {
+			h := uint64(14695981039346656037)
+			for i := uint64(0); i < 4; i++ {
+				h = (h ^ i) * 1099511628211
+			}
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			g, _ := ctx.inst.Exports.GetGlobal("out")
+			v, _ := g.Get()
+			if uint64(v.(int64)) != h {
+				ctx.t.Errorf("expected %x, got %x", h, uint64(v.(int64)))
+			}
+		},
+	},
+	{
+		what: "if tests",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			for i, tc := range ifTests {
+				f := m.Function()
+				f.Body(wasm.If{
+					Condition: tc.condition,
+					Then: []wasm.Instruction{
+						wasm.AssignF32(o, wasm.ConstF32(1)),
+					},
+					Else: []wasm.Instruction{
+						wasm.AssignF32(o, wasm.ConstF32(-1)),
+					},
+				})
+				m.Export(fmt.Sprintf("f%d", i), f)
+			}
+			b := m.GlobalI32(0)
+			m.Export("b", b)
+			f := m.Function()
+			f.Body(wasm.AssignI32(b, wasm.AddI32(
+				wasm.GtF32(wasm.ConstF32(2), wasm.ConstF32(1)),
+				wasm.ConstBool(true),
+			)))
+			m.Export("bools", f)
+			return m
+		},
+		test: func(ctx testContext) {
+			res, _ := ctx.inst.Exports.GetGlobal("o")
+			for i, tc := range ifTests {
+				ctx.t.Run(tc.what, func(t *testing.T) {
+					f, _ := ctx.inst.Exports.GetFunction(fmt.Sprintf("f%d", i))
+					if _, err := f(); err != nil {
+						t.Fatal(err)
+					}
+					v, _ := res.Get()
+					if v.(float32) != tc.expect {
+						t.Errorf("expected %f, got %f", tc.expect, v.(float32))
+					}
+				})
+			}
+			f, _ := ctx.inst.Exports.GetFunction("bools")
+			if _, err := f(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			b, _ := ctx.inst.Exports.GetGlobal("b")
+			if v, _ := b.Get(); v.(int32) != 2 {
+				ctx.t.Errorf("expected %d, got %d", 2, v.(int32))
+			}
+		},
+	},
+	{
+		what: "while and loop",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			// newton's method for sqrt(2)
+			root := m.GlobalF32(0)
+			m.Export("root", root)
+			newton := m.Function()
+			x := newton.LocalF32()
+			err := newton.LocalF32()
+			newton.Body(
+				wasm.AssignF32(x, wasm.ConstF32(1)),
+				wasm.AssignF32(err, wasm.ConstF32(1)),
+				wasm.While{
+					Condition: wasm.GtF32(err, wasm.ConstF32(1e-6)),
+					Do: []wasm.Instruction{
+						wasm.AssignF32(x, wasm.SubF32(x, wasm.DivF32(
+							wasm.SubF32(wasm.MulF32(x, x), wasm.ConstF32(2)),
+							wasm.MulF32(wasm.ConstF32(2), x),
+						))),
+						wasm.AssignF32(err, wasm.AbsF32(
+							wasm.SubF32(wasm.MulF32(x, x), wasm.ConstF32(2)))),
+					},
+				},
+				wasm.AssignF32(root, x),
+			)
+			m.Export("newton", newton)
+
+			// count to 5 with an unconditional loop
+			count := m.GlobalI32(0)
+			m.Export("count", count)
+			loop := m.Function()
+			loop.Body(
+				wasm.Loop{
+					Do: []wasm.Instruction{
+						wasm.AssignI32(count, wasm.AddI32(count, wasm.ConstI32(1))),
+						wasm.If{
+							Condition: wasm.GeI32(count, wasm.ConstI32(5)),
+							Then:      []wasm.Instruction{wasm.Break("")},
+						},
+					},
+				},
+			)
+			m.Export("loop", loop)
+
+			// nested loops with labels
+			pairs := m.GlobalI32(0)
+			m.Export("pairs", pairs)
+			nested := m.Function()
+			j := nested.LocalI32()
+			nested.Body(
+				wasm.ForRangeF32{
+					Label: "outer",
+					End:   wasm.ConstF32(5),
+					Do: func(i wasm.F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.If{
+								Condition: wasm.EqF32(i, wasm.ConstF32(3)),
+								Then:      []wasm.Instruction{wasm.Break("outer")},
+							},
+							wasm.AssignI32(j, wasm.ConstI32(0)),
+							wasm.While{
+								Condition: wasm.LtI32(j, wasm.ConstI32(10)),
+								Do: []wasm.Instruction{
+									wasm.AssignI32(j, wasm.AddI32(j, wasm.ConstI32(1))),
+									wasm.If{
+										Condition: wasm.GtI32(j, wasm.ConstI32(2)),
+										Then:      []wasm.Instruction{wasm.Continue("outer")},
+									},
+									wasm.AssignI32(pairs, wasm.AddI32(pairs, wasm.ConstI32(1))),
+								},
+							},
+							// never reached
+							wasm.AssignI32(pairs, wasm.AddI32(pairs, wasm.ConstI32(100))),
+						}
+					},
+				},
+			)
+			m.Export("nested", nested)
+
+			// sum of even numbers below 10
+			sum := m.GlobalF32(0)
+			m.Export("sum", sum)
+			evens := m.Function()
+			evens.Body(
+				wasm.ForRangeF32{
+					End: wasm.ConstF32(10),
+					Do: func(i wasm.F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.If{
+								Condition: wasm.NeF32(
+									wasm.FloorF32(wasm.DivF32(i, wasm.ConstF32(2))),
+									wasm.DivF32(i, wasm.ConstF32(2))),
+								Then: []wasm.Instruction{wasm.Continue("")},
+							},
+							wasm.AssignF32(sum, wasm.AddF32(sum, i)),
+						}
+					},
+				},
+			)
+			m.Export("evens", evens)
+			return m
+		},
+		test: func(ctx testContext) {
+			for name, global := range map[string]string{
+				"newton": "root",
+				"loop":   "count",
+				"nested": "pairs",
+				"evens":  "sum",
+			} {
+				f, _ := ctx.inst.Exports.GetFunction(name)
+				if _, err := f(); err != nil {
+					ctx.t.Fatalf("%s: %s", name, err)
+				}
+				g, _ := ctx.inst.Exports.GetGlobal(global)
+				v, _ := g.Get()
+				var ok bool
+				switch name {
+				case "newton":
+					d := v.(float32) - 1.4142135
+					ok = d < 1e-6 && d > -1e-6
+				case "loop":
+					ok = v.(int32) == 5
+				case "nested":
+					ok = v.(int32) == 6
+				case "evens":
+					ok = v.(float32) == 20
+				}
+				if !ok {
+					ctx.t.Errorf("%s: unexpected result %v", name, v)
+				}
+			}
+		},
+	},
+	{
+		what: "switch",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			o := m.GlobalF32(0)
+			m.Export("o", o)
+			add := func(v float32) []wasm.Instruction {
+				return []wasm.Instruction{
+					wasm.AssignF32(o, wasm.AddF32(o, wasm.ConstF32(v))),
+				}
+			}
+			// sums a different value for each index, and
+			// breaks out of the loop at index 4
+			f := m.Function()
+			f.Body(wasm.ForRangeF32{
+				End: wasm.ConstF32(6),
+				Do: func(i wasm.F32) []wasm.Instruction {
+					return []wasm.Instruction{
+						wasm.Switch{
+							Index: wasm.F32ToI32(i),
+							Cases: [][]wasm.Instruction{
+								add(1),
+								add(10),
+								add(100),
+								{wasm.Continue("")},
+								{wasm.Break("")},
+							},
+							Default: add(1000),
+						},
+						wasm.AssignF32(o, wasm.AddF32(o, wasm.ConstF32(0.5))),
+					}
+				},
+			})
+			m.Export("main", f)
+			g := m.Function()
+			g.Body(
+				wasm.AssignF32(o, wasm.ConstF32(0)),
+				wasm.SwitchF32{
+					Index: wasm.ConstF32(1.7),
+					Cases: [][]wasm.Instruction{add(1000), add(1)},
+				},
+				wasm.SwitchF32{
+					Index:   wasm.DivF32(wasm.ConstF32(0), wasm.ConstF32(0)),
+					Cases:   [][]wasm.Instruction{add(2), add(1000)},
+					Default: add(1000),
+				},
+				wasm.SwitchF32{
+					Index:   wasm.ConstF32(-1),
+					Cases:   [][]wasm.Instruction{add(4), add(1000)},
+					Default: add(1000),
+				},
+				wasm.SwitchF32{
+					Index: wasm.ConstF32(-0.5),
+					Cases: [][]wasm.Instruction{add(8), add(1000)},
+				},
+				wasm.SwitchF32{
+					Index: wasm.ConstF32(7),
+					Cases: [][]wasm.Instruction{add(1000), add(16)},
+				},
+				wasm.SwitchF32{
+					Index: wasm.DivF32(wasm.ConstF32(1), wasm.ConstF32(0)),
+					Cases: [][]wasm.Instruction{add(1000), add(1000), add(32)},
+				},
+				wasm.SwitchF32{
+					Index:   wasm.ConstF32(0),
+					Default: add(64),
+				},
+				wasm.Switch{
+					Index:   wasm.ConstI32(0),
+					Default: add(128),
+				},
+			)
+			m.Export("f32", g)
+			return m
+		},
+		test: func(ctx testContext) {
+			res, _ := ctx.inst.Exports.GetGlobal("o")
+			for name, exp := range map[string]float32{
+				"main": 112.5,
+				"f32":  255,
+			} {
+				res.Set(float32(0), wasmer.F32)
+				f, _ := ctx.inst.Exports.GetFunction(name)
+				if _, err := f(); err != nil {
+					ctx.t.Fatal(err)
+				}
+				v, _ := res.Get()
+				if v.(float32) != exp {
+					ctx.t.Errorf("%s: expected %f, got %f", name, exp, v.(float32))
+				}
+			}
+		},
+	},
+	{
+		what: "mutable vec4f32",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			// can't export vec4f32 from wasmer :(
+			vec := m.GlobalVec4F32([4]float32{1, 2, 3, 4})
+			step := m.Function()
+			l := step.LocalVec4F32()
+			step.Body(
+				wasm.AssignVec4F32(l, wasm.MulVec4F32(vec, wasm.ConstVec4F32{2, 2, 2, 2})),
+				wasm.AssignVec4F32(vec, wasm.AddVec4F32(l, wasm.ConstVec4F32{1, 1, 1, 1})),
+			)
+			m.Export("step", step)
+			body := make([]wasm.Instruction, 4)
+			for i := range body {
+				g := m.GlobalF32(0)
+				m.Export(fmt.Sprintf("o%d", i), g)
+				body[i] = wasm.AssignF32(g, wasm.ExtractLaneVec4F32(vec, i))
+			}
+			read := m.Function()
+			read.Body(body...)
+			m.Export("read", read)
+			return m
+		},
+		test: func(ctx testContext) {
+			step, _ := ctx.inst.Exports.GetFunction("step")
+			read, _ := ctx.inst.Exports.GetFunction("read")
+			step()
+			step()
+			if _, err := read(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			exp := [4]float32{7, 11, 15, 19}
+			for i := range exp {
+				g, _ := ctx.inst.Exports.GetGlobal(fmt.Sprintf("o%d", i))
+				v, _ := g.Get()
+				if v.(float32) != exp[i] {
+					ctx.t.Errorf("[%d] expected %f, got %f", i, exp[i], v.(float32))
+				}
+			}
+		},
+	},
+	{
+		what: "vec4 slice range",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			m.Export("memory", m.Memory(1, 0))
+			vec := m.ImportSliceF32("wowee")
+			f := m.Function()
+			f.Body(
+				wasm.MutableSliceF32RangeVec4F32{
+					Slice: vec,
+					Begin: wasm.ConstF32(1),
+					Do: func(v wasm.MutableVec4F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignVec4F32(v, wasm.MulVec4F32(v, wasm.SplatVec4F32(wasm.ConstF32(2)))),
+						}
+					},
+					Tail: func(v wasm.MutableF32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignF32(v, wasm.AddF32(v, wasm.ConstF32(100))),
+						}
+					},
+				},
+				// elements 7 to 10 are the last 4 of the slice
+				vec.SetVec4F32(wasm.ConstF32(7),
+					wasm.AddVec4F32(
+						vec.IndexVec4F32(wasm.ConstF32(0)),
+						wasm.ConstVec4F32{1, 1, 1, 1})),
+			)
+			m.Export("main", f)
+			ptr := int64(11<<32 | 0) // offset is 0 bytes, length is 11 floats
+			ctx.imp.Register("_sf32", map[string]wasmer.IntoExtern{
+				"wowee": wasmer.NewGlobal(
+					ctx.store,
+					globalType(wasmer.I64, wasmer.IMMUTABLE),
+					wasmer.NewI64(ptr),
+				),
+			})
+			return m
+		},
+		test: func(ctx testContext) {
+			mem, _ := ctx.inst.Exports.GetMemory("memory")
+			arr := [11]float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+			binary.Write(bytes.NewBuffer(mem.Data()[:0]), binary.LittleEndian, arr[:])
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			got := make([]float32, 16)
+			binary.Read(bytes.NewReader(mem.Data()), binary.LittleEndian, got)
+			// memory past the end of the slice is not written
+			exp := []float32{1, 4, 6, 8, 10, 12, 14, 2, 5, 7, 9, 0, 0, 0, 0, 0}
+			for i := range exp {
+				if got[i] != exp[i] {
+					ctx.t.Errorf("[%d] expected %f, got %f", i, exp[i], got[i])
+				}
+			}
+		},
+	},
+	{
+		what: "vec4 data slice sum",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			m.Memory(1, 0)
+			data := m.DataF32([]float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11})
+			out := m.GlobalF32(0)
+			m.Export("out", out)
+			f := m.Function()
+			acc := f.LocalVec4F32()
+			f.Body(
+				wasm.SliceF32RangeVec4F32{
+					Slice: data,
+					Do: func(v wasm.Vec4F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignVec4F32(acc, wasm.AddVec4F32(acc, v)),
+						}
+					},
+					Tail: func(v wasm.F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignF32(out, wasm.AddF32(out, v)),
+						}
+					},
+				},
+				wasm.AssignF32(out, wasm.AddF32(out, wasm.AddF32(
+					wasm.AddF32(wasm.ExtractLaneVec4F32(acc, 0), wasm.ExtractLaneVec4F32(acc, 1)),
+					wasm.AddF32(wasm.ExtractLaneVec4F32(acc, 2), wasm.ExtractLaneVec4F32(acc, 3)),
+				))),
+			)
+			m.Export("main", f)
+			brk := m.Function()
+			brk.Body(
+				wasm.AssignF32(out, wasm.ConstF32(0)),
+				wasm.SliceF32RangeVec4F32{
+					Label: "outer",
+					Slice: data,
+					Do: func(v wasm.Vec4F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.AssignF32(out, wasm.AddF32(out, wasm.ConstF32(1))),
+						}
+					},
+					Tail: func(v wasm.F32) []wasm.Instruction {
+						return []wasm.Instruction{
+							wasm.Loop{Do: []wasm.Instruction{
+								wasm.AssignF32(out, wasm.AddF32(out, v)),
+								wasm.Break("outer"),
+							}},
+						}
+					},
+				},
+			)
+			m.Export("break", brk)
+			return m
+		},
+		test: func(ctx testContext) {
+			out, _ := ctx.inst.Exports.GetGlobal("out")
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			if _, err := fn(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			if v, _ := out.Get(); v != float32(66) {
+				ctx.t.Errorf("expected 66, got %v", v)
+			}
+			brk, _ := ctx.inst.Exports.GetFunction("break")
+			if _, err := brk(); err != nil {
+				ctx.t.Fatal(err)
+			}
+			// two groups of 4, then break on the first tail element
+			if v, _ := out.Get(); v != float32(11) {
+				ctx.t.Errorf("expected 11, got %v", v)
+			}
+		},
+	},
+	{
+		what: "mat4 globals",
+		build: func(ctx buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			g := m.GlobalMat4F32([16]float32{
+				1, 0, 0, 0,
+				0, 1, 0, 0,
+				0, 0, 1, 0,
+				1, 2, 3, 1,
+			})
+			out := m.GlobalF32(0)
+			m.Export("out", out)
+			f := m.Function()
+			tmp := f.LocalMat4F32()
+			f.Body(
+				// translate twice
+				wasm.AssignMat4F32(tmp, g),
+				wasm.AssignMat4F32(g, wasm.MulMat4(g, tmp)),
+				wasm.AssignF32(out, wasm.ExtractLaneVec4F32(g.Column(3), 2)),
+			)
+			m.Export("main", f)
+			return m
+		},
+		test: func(ctx testContext) {
+			out, _ := ctx.inst.Exports.GetGlobal("out")
+			fn, _ := ctx.inst.Exports.GetFunction("main")
+			for _, exp := range []float32{6, 12} {
+				if _, err := fn(); err != nil {
+					ctx.t.Fatal(err)
+				}
+				if v, _ := out.Get(); v != exp {
+					ctx.t.Errorf("expected %v, got %v", exp, v)
+				}
+			}
+		},
+	},
+	{
+		what: "math",
+		build: func(b buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			out := m.GlobalF32(0)
+			m.Export("out", out)
+			for i, tc := range mathTests {
+				f := m.Function()
+				f.Body(wasm.AssignF32(out, tc.assign))
+				m.Export(fmt.Sprintf("f%d", i), f)
+			}
+			return m
+		},
+		test: func(ctx testContext) {
+			t := ctx.t
+			g, _ := ctx.inst.Exports.GetGlobal("out")
+			for i, tc := range mathTests {
+				f, _ := ctx.inst.Exports.GetFunction(fmt.Sprintf("f%d", i))
+				if _, err := f(); err != nil {
+					t.Errorf("failed to run %q: %s", tc.what, err)
+				}
+				v, _ := g.Get()
+				if vf := v.(float32); !withinULP(vf, tc.expect, tc.ulp) {
+					t.Errorf("%s: expected %g got %g", tc.what, tc.expect, vf)
+				}
+			}
+		},
+	},
+	{
+		what: "vec4 math",
+		build: func(b buildContext) *wasm.Module {
+			m := new(wasm.Module)
+			x := wasm.ConstVec4F32{0.5, -2.5, 4, 1000}
+			y := wasm.ConstVec4F32{2, 3, -1, 0.25}
+			for i, v := range []wasm.Vec4F32{
+				wasm.SinVec4F32(x),
+				wasm.CosVec4F32(x),
+				wasm.TanVec4F32(x),
+				wasm.Atan2Vec4F32(x, y),
+				wasm.ExpVec4F32(y),
+				wasm.LogVec4F32(x),
+				wasm.PowVec4F32(x, y),
+				wasm.TanhVec4F32(x),
+			} {
+				for j := 0; j < 4; j++ {
+					g := m.GlobalF32(0)
+					m.Export(fmt.Sprintf("o%d_%d", i, j), g)
+					f := m.Function()
+					f.Body(wasm.AssignF32(g, wasm.ExtractLaneVec4F32(v, j)))
+					m.Export(fmt.Sprintf("f%d_%d", i, j), f)
+				}
+			}
+			return m
+		},
+		test: func(ctx testContext) {
+			t := ctx.t
+			x := [4]float64{0.5, -2.5, 4, 1000}
+			y := [4]float64{2, 3, -1, 0.25}
+			for i, fn := range []struct {
+				what string
+				f    func(x, y float64) float64
+				ulp  float64
+			}{
+				{"sin", func(x, y float64) float64 { return math.Sin(x) }, 1.5},
+				{"cos", func(x, y float64) float64 { return math.Cos(x) }, 1.5},
+				{"tan", func(x, y float64) float64 { return math.Tan(x) }, 2.5},
+				{"atan2", math.Atan2, 2},
+				{"exp", func(x, y float64) float64 { return math.Exp(y) }, 1},
+				{"log", func(x, y float64) float64 { return math.Log(x) }, 1},
+				{"pow", math.Pow, 4},
+				{"tanh", func(x, y float64) float64 { return math.Tanh(x) }, 2},
+			} {
+				for j := 0; j < 4; j++ {
+					f, _ := ctx.inst.Exports.GetFunction(fmt.Sprintf("f%d_%d", i, j))
+					if _, err := f(); err != nil {
+						t.Errorf("failed to run %q: %s", fn.what, err)
+					}
+					g, _ := ctx.inst.Exports.GetGlobal(fmt.Sprintf("o%d_%d", i, j))
+					v, _ := g.Get()
+					expect := fn.f(x[j], y[j])
+					if vf := v.(float32); !withinULP(vf, expect, fn.ulp) {
+						t.Errorf("%s lane %d: expected %g got %g", fn.what, j, expect, vf)
+					}
+				}
+			}
+		},
+	},
+}
+
+// globalType returns a wasmer global type. wasmer-go leaves a
+// finalizer on the value type it passes to the global type,
+// which takes ownership of it, so the value type would be
+// freed twice.
+func globalType(kind wasmer.ValueKind, mutability wasmer.GlobalMutability) *wasmer.GlobalType {
+	valueType := wasmer.NewValueType(kind)
+	runtime.SetFinalizer(valueType, nil)
+	return wasmer.NewGlobalType(valueType, mutability)
+}
+
+func TestWasm(t *testing.T) {
+	for _, tc := range tcs {
+		t.Run(tc.what, func(t *testing.T) {
+			var data interface{}
+			engine := wasmer.NewEngine()
+			store := wasmer.NewStore(engine)
+			importObject := wasmer.NewImportObject()
+			mod := tc.build(buildContext{
+				t:     t,
+				imp:   importObject,
+				data:  &data,
+				store: store,
+			})
+			buf, err := mod.Compile()
+			if err != nil {
+				t.Error(err)
+			}
+			err = wasmer.ValidateModule(store, buf)
+			if err != nil {
+				t.Error(err)
+			}
+			module, err := wasmer.NewModule(store, buf)
+			if err != nil {
+				t.Error(err)
+			}
+			if module == nil {
+				return
+			}
+			inst, err := wasmer.NewInstance(module, importObject)
+			if err != nil {
+				t.Error(err)
+			}
+			if tc.test != nil {
+				tc.test(testContext{
+					t:    t,
+					inst: inst,
+					imp:  importObject,
+					data: &data,
 				})
 			}
 		})
 	}
 }
+
+func TestCallTypeMismatch(t *testing.T) {
+	for _, call := range []func(f wasm.Function) wasm.Instruction{
+		func(f wasm.Function) wasm.Instruction {
+			return wasm.Call(f)
+		},
+		func(f wasm.Function) wasm.Instruction {
+			return wasm.Call(f, wasm.ConstVec4F32{})
+		},
+		func(f wasm.Function) wasm.Instruction {
+			return wasm.CallVec4F32(f, wasm.ConstF32(1))
+		},
+	} {
+		m := new(wasm.Module)
+		f := m.TypedFunction(wasm.Signature{
+			Params:  []wasm.Type{wasm.TypeF32},
+			Results: []wasm.Type{wasm.TypeF32},
+		})
+		f.Body(f.ParamF32(0))
+		main := m.Function()
+		main.Body(call(f))
+		m.Export("main", main)
+		if _, err := m.Compile(); err == nil {
+			t.Errorf("expected error compiling bad call")
+		}
+	}
+}
+
+func TestDataOverlap(t *testing.T) {
+	m := new(wasm.Module)
+	m.Data([]byte{1, 2, 3, 4})
+	m.DataAt(2, []byte{5})
+	if _, err := m.Compile(); err == nil {
+		t.Errorf("expected error compiling overlapping data segments")
+	}
+}
+
+func TestBreakOutsideLoop(t *testing.T) {
+	for _, body := range []wasm.Instruction{
+		wasm.Break(""),
+		wasm.Continue(""),
+		wasm.Loop{Label: "a", Do: []wasm.Instruction{wasm.Break("b")}},
+	} {
+		m := new(wasm.Module)
+		f := m.Function()
+		f.Body(body)
+		m.Export("main", f)
+		if _, err := m.Compile(); err == nil {
+			t.Errorf("expected error compiling break outside of loop")
+		}
+	}
+}
+
+func TestSliceRangeVec4F32NoTail(t *testing.T) {
+	for _, body := range []func(s wasm.MutableSliceF32) wasm.Instruction{
+		func(s wasm.MutableSliceF32) wasm.Instruction {
+			return wasm.SliceF32RangeVec4F32{
+				Slice: s,
+				End:   wasm.ConstF32(11),
+				Do: func(v wasm.Vec4F32) []wasm.Instruction {
+					return nil
+				},
+			}
+		},
+		func(s wasm.MutableSliceF32) wasm.Instruction {
+			return wasm.MutableSliceF32RangeVec4F32{
+				Slice: s,
+				End:   wasm.ConstF32(11),
+				Do: func(v wasm.MutableVec4F32) []wasm.Instruction {
+					return nil
+				},
+			}
+		},
+	} {
+		m := new(wasm.Module)
+		m.Memory(1, 0)
+		f := m.Function()
+		// 11 values leave 3 for the missing Tail
+		f.Body(body(m.ImportSliceF32("s")))
+		m.Export("main", f)
+		if _, err := m.Compile(); err == nil {
+			t.Errorf("expected error compiling vec4 slice range without a tail")
+		}
+	}
+}
+
+func TestImportVec4F32(t *testing.T) {
+	m := new(wasm.Module)
+	in := m.ImportVec4F32("env", "in")
+	out := m.GlobalVec4F32([4]float32{})
+	f := m.TypedFunction(wasm.Signature{
+		Params: []wasm.Type{wasm.TypeVec4F32},
+	})
+	p := f.ParamVec4F32(0)
+	f.Body(
+		wasm.AssignVec4F32(p, wasm.AddVec4F32(p, in)),
+		wasm.AssignVec4F32(in, p),
+		wasm.AssignVec4F32(out, in),
+	)
+	m.Export("main", f)
+	buf, err := m.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wasmer.ValidateModule(wasmer.NewStore(wasmer.NewEngine()), buf); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDuplicateImport(t *testing.T) {
+	for _, tc := range []struct {
+		what          string
+		first, second func(m *wasm.Module)
+	}{
+		{
+			"globals",
+			func(m *wasm.Module) { m.ImportF32("env", "x") },
+			func(m *wasm.Module) { m.ImportI32("env", "x") },
+		},
+		{
+			"memory then global",
+			func(m *wasm.Module) { m.ImportMemory("env", "x", 1, 0) },
+			func(m *wasm.Module) { m.ImportF32("env", "x") },
+		},
+		{
+			"global then memory",
+			func(m *wasm.Module) { m.ImportF32("env", "x") },
+			func(m *wasm.Module) { m.ImportMemory("env", "x", 1, 0) },
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic importing env.x twice", tc.what)
+				}
+			}()
+			m := new(wasm.Module)
+			tc.first(m)
+			tc.second(m)
+		}()
+	}
+}
+
+func TestImportMat4F32(t *testing.T) {
+	m := new(wasm.Module)
+	in := m.ImportMat4F32("env", "in")
+	out := m.GlobalMat4F32([16]float32{})
+	f := m.Function()
+	f.Body(
+		wasm.AssignMat4F32(out, wasm.InverseMat4(in)),
+		wasm.AssignMat4F32(in, wasm.TransposeMat4(out)),
+	)
+	m.Export("main", f)
+	buf, err := m.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wasmer.ValidateModule(wasmer.NewStore(wasmer.NewEngine()), buf); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLaneOutOfRange(t *testing.T) {
+	for _, v := range []wasm.Instruction{
+		wasm.ExtractLaneVec4F32(wasm.ConstVec4F32{}, 4),
+		wasm.ReplaceLaneVec4F32(wasm.ConstVec4F32{}, -1, wasm.ConstF32(0)),
+		wasm.ShuffleVec4F32(wasm.ConstVec4F32{}, wasm.ConstVec4F32{}, [4]int{0, 1, 2, 8}),
+		wasm.ColumnMat4F32(wasm.IdentityMat4F32, 4),
+	} {
+		m := new(wasm.Module)
+		f := m.Function()
+		f.Body(v, wasm.Return)
+		m.Export("main", f)
+		if _, err := m.Compile(); err == nil {
+			t.Errorf("expected error compiling lane out of range")
+		}
+	}
+}
+
+func TestMathHelperShared(t *testing.T) {
+	compile := func(n int) int {
+		m := new(wasm.Module)
+		out := m.GlobalF32(0)
+		for i := 0; i < n; i++ {
+			f := m.Function()
+			f.Body(wasm.AssignF32(out, wasm.PowF32(out, wasm.ConstF32(float32(i)))))
+			m.Export(fmt.Sprintf("f%d", i), f)
+		}
+		buf, err := m.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(buf)
+	}
+	// each further call adds only its function, not
+	// another copy of the helpers.
+	if one, two := compile(1), compile(2); two-one > 32 {
+		t.Errorf("expected helpers to be shared, grew from %d to %d bytes", one, two)
+	}
+}
+
+// ulpError returns the distance from got to expect in units in
+// the last place of expect. Zeros, infinities and NaN must match
+// exactly.
+func ulpError(got float32, expect float64) float64 {
+	want := float32(expect)
+	if math.IsNaN(float64(want)) || math.IsInf(float64(want), 0) || want == 0 {
+		if math.Float32bits(got) == math.Float32bits(want) ||
+			math.IsNaN(float64(got)) && math.IsNaN(float64(want)) {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	_, e := math.Frexp(expect)
+	if e < -125 {
+		// subnormal results have the ulp of the smallest normal
+		e = -125
+	}
+	return math.Abs(float64(got)-expect) / math.Ldexp(1, e-24)
+}
+
+func TestMathAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	uniform := func(n int, lo, hi float64) []float32 {
+		xs := make([]float32, n)
+		for i := range xs {
+			xs[i] = float32(lo + rng.Float64()*(hi-lo))
+		}
+		return xs
+	}
+	// logUniform returns values with magnitudes from lo to hi,
+	// alternating in sign if signed is set
+	logUniform := func(n int, lo, hi float64, signed bool) []float32 {
+		xs := make([]float32, n)
+		for i := range xs {
+			xs[i] = float32(math.Exp(math.Log(lo) + rng.Float64()*(math.Log(hi)-math.Log(lo))))
+			if signed && i%2 == 1 {
+				xs[i] = -xs[i]
+			}
+		}
+		return xs
+	}
+	cat := func(xss ...[]float32) []float32 {
+		var all []float32
+		for _, xs := range xss {
+			all = append(all, xs...)
+		}
+		return all
+	}
+
+	// uniform samples below the limit of the fast reduction,
+	// samples of every magnitude beyond it, and the values
+	// closest to multiples of pi/2, where the reduction
+	// cancels the most bits
+	var pio2 []float32
+	for n := 1; n < 20000; n += 7 {
+		x := float32(float64(n) * math.Pi / 2)
+		pio2 = append(pio2, math.Nextafter32(x, 0), x, math.Nextafter32(x, math.MaxFloat32))
+	}
+	trig := cat(
+		uniform(8000, -4096, 4096),
+		logUniform(4000, 1, math.MaxFloat32, true),
+		pio2[:len(pio2)/4*4],
+	)
+	// atan2 is sampled in the unit square, at every magnitude,
+	// and on the unit circle, which crosses each reduction
+	// boundary
+	atan2Y := cat(uniform(8000, -1, 1), logUniform(4000, 1e-30, 1e30, true))
+	atan2X := cat(uniform(8000, -1, 1), logUniform(4000, 1e-30, 1e30, false))
+	for i := 0; i < 4000; i++ {
+		a := rng.Float64() * 2 * math.Pi
+		atan2Y = append(atan2Y, float32(math.Sin(a)))
+		atan2X = append(atan2X, float32(math.Cos(a)))
+	}
+	// y alternates in sign, so negate half of x to reach
+	// every quadrant
+	for i := 8000; i < 10000; i++ {
+		atan2X[i] = -atan2X[i]
+	}
+
+	// every function takes x and y, and the unary ones
+	// ignore y
+	unary := func(f func(wasm.F32) wasm.F32) func(x, y wasm.F32) wasm.F32 {
+		return func(x, y wasm.F32) wasm.F32 { return f(x) }
+	}
+	unaryVec := func(f func(wasm.Vec4F32) wasm.Vec4F32) func(x, y wasm.Vec4F32) wasm.Vec4F32 {
+		return func(x, y wasm.Vec4F32) wasm.Vec4F32 { return f(x) }
+	}
+	unaryRef := func(f func(float64) float64) func(x, y float64) float64 {
+		return func(x, y float64) float64 { return f(x) }
+	}
+	atan2F32 := func(x, y wasm.F32) wasm.F32 { return wasm.Atan2F32(y, x) }
+	atan2Vec := func(x, y wasm.Vec4F32) wasm.Vec4F32 { return wasm.Atan2Vec4F32(y, x) }
+	atan2Ref := func(x, y float64) float64 { return math.Atan2(y, x) }
+	fns := []struct {
+		name   string
+		f32    func(x, y wasm.F32) wasm.F32
+		vec    func(x, y wasm.Vec4F32) wasm.Vec4F32
+		ref    func(x, y float64) float64
+		ulp    float64
+		xs, ys []float32
+	}{
+		{"sin", unary(wasm.SinF32), unaryVec(wasm.SinVec4F32), unaryRef(math.Sin), 1.5, trig, nil},
+		{"cos", unary(wasm.CosF32), unaryVec(wasm.CosVec4F32), unaryRef(math.Cos), 1.5, trig, nil},
+		{"tan", unary(wasm.TanF32), unaryVec(wasm.TanVec4F32), unaryRef(math.Tan), 2.5, trig, nil},
+		{"atan2", atan2F32, atan2Vec, atan2Ref, 2, atan2X, atan2Y},
+		{
+			"exp", unary(wasm.ExpF32), unaryVec(wasm.ExpVec4F32), unaryRef(math.Exp), 1,
+			cat(uniform(8000, -104, 89), uniform(4000, -1, 1)), nil,
+		},
+		{
+			"log", unary(wasm.LogF32), unaryVec(wasm.LogVec4F32), unaryRef(math.Log), 1,
+			cat(logUniform(8000, 1e-45, math.MaxFloat32, false), uniform(4000, 0.5, 2)), nil,
+		},
+		{
+			"tanh", unary(wasm.TanhF32), unaryVec(wasm.TanhVec4F32), unaryRef(math.Tanh), 2,
+			cat(uniform(8000, -10, 10), logUniform(4000, 1e-30, 10, true)), nil,
+		},
+	}
+	m := new(wasm.Module)
+	for _, fn := range fns {
+		f := m.TypedFunction(wasm.Signature{
+			Params:  []wasm.Type{wasm.TypeF32, wasm.TypeF32},
+			Results: []wasm.Type{wasm.TypeF32},
+		})
+		f.Body(wasm.ReturnValue(fn.f32(f.ParamF32(0), f.ParamF32(1))))
+		m.Export(fn.name, f)
+		v := m.TypedFunction(wasm.Signature{
+			Params:  []wasm.Type{wasm.TypeVec4F32, wasm.TypeVec4F32},
+			Results: []wasm.Type{wasm.TypeVec4F32},
+		})
+		v.Body(wasm.ReturnValue(fn.vec(v.ParamVec4F32(0), v.ParamVec4F32(1))))
+		m.Export(fn.name+"Vec4", v)
+	}
+	buf, err := m.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := interp.Instantiate(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fn := range fns {
+		f, _ := inst.Function(fn.name)
+		v, _ := inst.Function(fn.name + "Vec4")
+		ys := fn.ys
+		if ys == nil {
+			ys = make([]float32, len(fn.xs))
+		}
+		for i := 0; i < len(fn.xs); i += 4 {
+			var xl, yl [4]float32
+			copy(xl[:], fn.xs[i:i+4])
+			copy(yl[:], ys[i:i+4])
+			res, err := v.Call(xl, yl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := res[0].([16]byte)
+			for j, x := range xl {
+				y := yl[j]
+				res, err := f.Call(x, y)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := res[0].(float32)
+				expect := fn.ref(float64(x), float64(y))
+				if e := ulpError(got, expect); e > fn.ulp {
+					t.Errorf("%s(%g, %g): expected %g within %g ulp, got %g (%.3f ulp)", fn.name, x, y, expect, fn.ulp, got, e)
+				}
+				if lane := binary.LittleEndian.Uint32(b[4*j:]); lane != math.Float32bits(got) {
+					t.Errorf("%sVec4 lane %d of %v, %v: expected %g, got %g", fn.name, j, xl, yl, got, math.Float32frombits(lane))
+				}
+			}
+		}
+	}
+}
+
+func TestInterp(t *testing.T) {
+	m := new(wasm.Module)
+	f32, i32 := m.GlobalF32(0), m.GlobalI32(0)
+	f64, i64 := m.GlobalF64(0), m.GlobalI64(0)
+	vec := m.GlobalVec4F32([4]float32{})
+	m.Export("f32", f32)
+	m.Export("i32", i32)
+	m.Export("f64", f64)
+	m.Export("i64", i64)
+	m.Export("vec", vec)
+
+	type check struct {
+		what   string
+		out    string
+		expect func(v interface{}) bool
+	}
+	var checks []check
+	add := func(what, out string, expect func(v interface{}) bool, body ...wasm.Instruction) {
+		f := m.Function()
+		f.Body(body...)
+		m.Export(fmt.Sprintf("check%d", len(checks)), f)
+		checks = append(checks, check{what, out, expect})
+	}
+	for _, tc := range opf32Tests {
+		tc := tc
+		add("f32 "+tc.what, "f32", func(v interface{}) bool {
+			return v.(float32) == tc.expect
+		}, wasm.AssignF32(f32, tc.assign))
+	}
+	for _, tc := range opi32Tests {
+		tc := tc
+		add("i32 "+tc.what, "i32", func(v interface{}) bool {
+			return v.(int32) == tc.expect
+		}, wasm.AssignI32(i32, tc.assign))
+	}
+	for _, tc := range opf64Tests {
+		tc := tc
+		add("f64 "+tc.what, "f64", func(v interface{}) bool {
+			return v.(float64) == tc.expect
+		}, wasm.AssignF64(f64, tc.assign))
+	}
+	for _, tc := range opi64Tests {
+		tc := tc
+		add("i64 "+tc.what, "i64", func(v interface{}) bool {
+			return v.(int64) == tc.expect
+		}, wasm.AssignI64(i64, tc.assign))
+	}
+	for _, tc := range opvec4f32Tests {
+		tc := tc
+		add("vec4f32 "+tc.what, "vec", func(v interface{}) bool {
+			b := v.([16]byte)
+			for i, e := range tc.expect {
+				if math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:])) != e {
+					return false
+				}
+			}
+			return true
+		}, wasm.AssignVec4F32(vec, tc.assign))
+	}
+	for _, tc := range mathTests {
+		tc := tc
+		add("math "+tc.what, "f32", func(v interface{}) bool {
+			return withinULP(v.(float32), tc.expect, tc.ulp)
+		}, wasm.AssignF32(f32, tc.assign))
+	}
+	for _, tc := range forRangeTests {
+		tc := tc
+		add("for range "+tc.what, "f32", func(v interface{}) bool {
+			return v.(float32) == tc.expect
+		}, wasm.AssignF32(f32, wasm.ConstF32(0)), tc.forRange(f32))
+	}
+	for _, tc := range ifElseTests {
+		tc := tc
+		add("if else "+tc.what, "f32", func(v interface{}) bool {
+			return v.(float32) == tc.expect
+		}, wasm.AssignF32(f32, wasm.ConstF32(0)), tc.ifElse(f32))
+	}
+	for _, tc := range ifTests {
+		tc := tc
+		add("if "+tc.what, "f32", func(v interface{}) bool {
+			return v.(float32) == tc.expect
+		}, wasm.If{
+			Condition: tc.condition,
+			Then:      []wasm.Instruction{wasm.AssignF32(f32, wasm.ConstF32(1))},
+			Else:      []wasm.Instruction{wasm.AssignF32(f32, wasm.ConstF32(-1))},
+		})
+	}
+
+	buf, err := m.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := interp.Instantiate(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range checks {
+		f, err := inst.Function(fmt.Sprintf("check%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Call(); err != nil {
+			t.Errorf("%s: %s", c.what, err)
+			continue
+		}
+		g, err := inst.Global(c.out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := g.Get(); !c.expect(v) {
+			t.Errorf("%s: unexpected result %v", c.what, v)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	m := new(wasm.Module)
+	m.Memory(1, 2)
+	m.DataAt(8, []byte{1, 2, 3})
+	x := m.ImportF32("env", "x")
+	o := m.GlobalI64(-5)
+	m.Export("o", o)
+	sq := m.TypedFunction(wasm.Signature{
+		Params:  []wasm.Type{wasm.TypeF32},
+		Results: []wasm.Type{wasm.TypeF32},
+	})
+	v := sq.LocalVec4F32()
+	sq.Body(
+		wasm.AssignVec4F32(v, wasm.SplatVec4F32(sq.ParamF32(0))),
+		wasm.ReturnValue(wasm.ExtractLaneVec4F32(wasm.MulVec4F32(v, v), 2)),
+	)
+	m.Export("sq", sq)
+	main := m.Function()
+	main.Body(wasm.AssignF32(x, wasm.CallF32(sq, x)))
+	m.Export("main", main)
+	buf, err := m.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := wasm.Parse(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bin.Imports) != 1 {
+		t.Fatalf("expected 1 import, got %d", len(bin.Imports))
+	}
+	if imp := bin.Imports[0]; imp.Module != "env" || imp.Name != "x" ||
+		imp.Kind != wasm.ExternGlobal || imp.Global != wasm.TypeF32 || !imp.Mutable {
+		t.Errorf("unexpected import %+v", imp)
+	}
+	if len(bin.Memories) != 1 || bin.Memories[0] != (wasm.Limits{Min: 1, Max: 2, HasMax: true}) {
+		t.Errorf("unexpected memories %+v", bin.Memories)
+	}
+	if len(bin.Globals) != 1 || bin.Globals[0].Type != wasm.TypeI64 ||
+		len(bin.Globals[0].Init) != 1 || int64(bin.Globals[0].Init[0].Bits) != -5 {
+		t.Errorf("unexpected globals %+v", bin.Globals)
+	}
+	if len(bin.Data) != 1 || !bin.Data[0].Active || !bytes.Equal(bin.Data[0].Init, []byte{1, 2, 3}) ||
+		bin.Data[0].Offset[0].String() != "i32.const 8" {
+		t.Errorf("unexpected data %+v", bin.Data)
+	}
+	exports := make(map[string]wasm.BinaryExport)
+	for _, e := range bin.Exports {
+		exports[e.Name] = e
+	}
+	if e := exports["o"]; e.Kind != wasm.ExternGlobal || e.Index != 1 {
+		t.Errorf("unexpected export %+v", e)
+	}
+	e, ok := exports["sq"]
+	if !ok || e.Kind != wasm.ExternFunction {
+		t.Fatalf("unexpected export %+v", e)
+	}
+	if got := bin.Types[bin.Functions[e.Index]].String(); got != "func (f32) -> (f32)" {
+		t.Errorf("unexpected type %s", got)
+	}
+	code := bin.Code[e.Index]
+	if len(code.Locals) != 1 || code.Locals[0] != wasm.TypeVec4F32 {
+		t.Errorf("unexpected locals %v", code.Locals)
+	}
+	var ops []string
+	for _, in := range code.Body {
+		ops = append(ops, in.String())
+	}
+	if got, expect := fmt.Sprint(ops), "[local.get 0 f32x4.splat local.set 1 "+
+		"local.get 1 local.get 1 f32x4.mul f32x4.extract_lane 2 return]"; got != expect {
+		t.Errorf("expected body %s, got %s", expect, got)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	header := []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}
+	module := func(b ...byte) []byte {
+		return append(append([]byte(nil), header...), b...)
+	}
+	// a type section with func () -> (), and a function section
+	// with one function of that type
+	fn := []byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00, 0x03, 0x02, 0x01, 0x00}
+	body := func(b ...byte) []byte {
+		code := append([]byte{0x0A, byte(len(b) + 3), 0x01, byte(len(b) + 1), 0x00}, b...)
+		return module(append(append([]byte(nil), fn...), code...)...)
+	}
+	for _, tc := range []struct {
+		what string
+		bin  []byte
+		err  string
+	}{
+		{"empty", nil, "not a version 1"},
+		{"truncated section", module(0x01, 0x05, 0x01), "unexpected end"},
+		{"unknown section", module(0x0D, 0x00), "unsupported section"},
+		{"sections out of order", module(0x03, 0x01, 0x00, 0x01, 0x01, 0x00), "out of order"},
+		{"long leb128", module(0x01, 0x06, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00), "integer too long"},
+		{"invalid value type", module(0x01, 0x04, 0x01, 0x60, 0x01, 0x00), "invalid value type"},
+		{"invalid name", module(0x00, 0x02, 0x01, 0xFF), "invalid UTF-8"},
+		{"missing code", module(fn...), "missing code"},
+		{"unknown instruction", body(0xFF, 0x0B), "unsupported instruction"},
+		{"undefined local", body(0x20, 0x00, 0x1A, 0x0B), "local 0 is not defined"},
+		{"undefined function", body(0x10, 0x01, 0x0B), "undefined function 1"},
+		{"undefined label", body(0x0C, 0x01, 0x0B), "undefined label 1"},
+		{"unclosed block", body(0x02, 0x40, 0x0B), "unexpected end"},
+		{"memory without memory", body(0x41, 0x00, 0x28, 0x02, 0x00, 0x1A, 0x0B), "without memory"},
+		{"trailing bytes", module(0x01, 0x02, 0x00, 0x00), "trailing bytes"},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			_, err := wasm.Decode(tc.bin)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected error containing %q, got %q", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCompileText(t *testing.T) {
+	m := new(wasm.Module)
+	x := m.ImportF32("env", "x")
+	scale := m.GlobalF32(2)
+	m.Export("scale", scale)
+	f := m.TypedFunction(wasm.Signature{
+		Params:  []wasm.Type{wasm.TypeF32},
+		Results: []wasm.Type{wasm.TypeF32},
+	})
+	v := f.LocalVec4F32()
+	f.Body(
+		wasm.AssignVec4F32(v, wasm.ReplaceLaneVec4F32(wasm.SplatVec4F32(f.ParamF32(0)), 3, scale)),
+		wasm.ReturnValue(wasm.ExtractLaneVec4F32(v, 1)),
+	)
+	m.Export("lane", f)
+	run := m.Function()
+	run.Body(wasm.If{
+		Condition: wasm.GtF32(x, wasm.ConstF32(0)),
+		Then:      []wasm.Instruction{wasm.AssignF32(x, wasm.CallF32(f, x))},
+		Else:      []wasm.Instruction{wasm.AssignF32(x, wasm.ConstF32(-0.5))},
+	})
+	m.Export("run", run)
+	out, err := m.CompileText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `(module
+  (type (;0;) (func (param f32) (result f32)))
+  (type (;1;) (func))
+  (import "env" "x" (global $env.x (mut f32)))
+  (func $lane (type 0) (param $p0 f32) (result f32)
+    (local $l0 v128)
+    (local.set $l0
+      (f32x4.replace_lane 3 (f32x4.splat (local.get $p0)) (global.get $scale)))
+    (return (f32x4.extract_lane 1 (local.get $l0))))
+  (func $run (type 1)
+    (if
+      (f32.gt (global.get $env.x) (f32.const 0))
+      (then
+        (global.set $env.x (call $lane (global.get $env.x))))
+      (else
+        (global.set $env.x (f32.const -0.5)))))
+  (global $scale (mut f32) (f32.const 2))
+  (export "lane" (func $lane))
+  (export "run" (func $run))
+  (export "scale" (global $scale)))
+`
+	if string(out) != expected {
+		t.Errorf("unexpected text format:\n%s", out)
+	}
+}
+
+func TestNameSection(t *testing.T) {
+	build := func(emit bool) *wasm.Module {
+		m := new(wasm.Module)
+		if emit {
+			m.EmitNames("kernels")
+		}
+		x := m.ImportF32("env", "x")
+		total := m.GlobalF32(0)
+		m.Name("total", total)
+		f := m.TypedFunction(wasm.Signature{
+			Params:  []wasm.Type{wasm.TypeF32},
+			Results: []wasm.Type{wasm.TypeF32},
+		})
+		a := f.ParamF32(0)
+		f.NameLocal("a", a)
+		b := f.LocalF32()
+		f.NameLocal("b", b)
+		mat := f.LocalMat4F32()
+		f.NameLocal("mat", mat)
+		f.Body(
+			wasm.AssignF32(b, wasm.CosF32(a)),
+			wasm.AssignF32(total, wasm.AddF32(total, b)),
+			wasm.ReturnValue(wasm.AddF32(b, x)),
+		)
+		m.Export("wave", f)
+		m.Export("sum", total)
+		return m
+	}
+
+	buf, err := build(false).Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := wasm.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bin.Custom) != 0 {
+		t.Errorf("unexpected custom sections %+v", bin.Custom)
+	}
+
+	m := build(true)
+	buf, err = m.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin, err = wasm.Decode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(bin.Custom) != 1 || bin.Custom[0].Name != "name" {
+		t.Fatalf("expected a name section, got %+v", bin.Custom)
+	}
+	x, err := interp.NewGlobal(interp.F32, true, float32(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := interp.Instantiate(buf, interp.Imports{"env": {"x": x}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wave, err := inst.Function("wave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := wave.Call(float32(0)); err != nil || out[0] != float32(2) {
+		t.Errorf("unexpected result %v, %v", out, err)
+	}
+
+	text, err := m.CompileText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"(module $kernels",
+		"(import \"env\" \"x\" (global $env.x (mut f32)))",
+		"(func $wave (type 0) (param $a f32) (result f32)",
+		"(local $b f32)",
+		"(local $mat.3 v128)",
+		"(global.set $total (f32.add (global.get $total) (local.get $b)))",
+		"(func $cosF32 (type 0) (param $p0 f32) (result f32)",
+		"(global $total (mut f32) (f32.const 0))",
+		"(export \"sum\" (global $total))",
+	} {
+		if !strings.Contains(string(text), s) {
+			t.Errorf("expected %q in text format:\n%s", s, text)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	header := []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}
+	// a type section with func () -> (), a function section
+	// with one function of that type, and the sections in
+	// globals before the code section with its body
+	module := func(globals []byte, b ...byte) []byte {
+		out := append([]byte(nil), header...)
+		out = append(out, 0x01, 0x04, 0x01, 0x60, 0x00, 0x00, 0x03, 0x02, 0x01, 0x00)
+		out = append(out, globals...)
+		out = append(out, 0x0A, byte(len(b)+3), 0x01, byte(len(b)+1), 0x00)
+		return append(out, b...)
+	}
+	body := func(b ...byte) []byte {
+		return module(nil, b...)
+	}
+	// an immutable i32 global, initialized to 0
+	global := []byte{0x06, 0x06, 0x01, 0x7F, 0x00, 0x41, 0x00, 0x0B}
+	for _, tc := range []struct {
+		what string
+		bin  []byte
+		err  string
+	}{
+		{"empty", body(0x0B), ""},
+		{"unreachable", body(0x00, 0x92, 0x1A, 0x0B), ""},
+		{"branch to loop", body(0x03, 0x40, 0x0C, 0x00, 0x0B, 0x0B), ""},
+		{"missing operand", body(0x1A, 0x0B), "function 0: instruction 0 (drop): missing operand"},
+		{"type mismatch",
+			body(0x41, 0x01, 0x43, 0x00, 0x00, 0x80, 0x3F, 0x92, 0x1A, 0x0B),
+			"instruction 2 (f32.add): expected f32 operand, found i32"},
+		{"extra value", body(0x41, 0x01, 0x0B), "end: 1 extra values on the stack at the end of the function"},
+		{"missing block result", body(0x02, 0x7D, 0x0B, 0x1A, 0x0B), "instruction 1 (end): missing f32 operand"},
+		{"if without else",
+			body(0x41, 0x01, 0x04, 0x7F, 0x41, 0x02, 0x0B, 0x1A, 0x0B),
+			"instruction 3 (end): if without else"},
+		{"select mismatch",
+			body(0x41, 0x01, 0x43, 0x00, 0x00, 0x80, 0x3F, 0x41, 0x01, 0x1B, 0x1A, 0x0B),
+			"instruction 3 (select): expected f32 operand, found i32"},
+		{"global.get", module(global, 0x23, 0x00, 0x1A, 0x0B), ""},
+		{"immutable global", module(global, 0x41, 0x01, 0x24, 0x00, 0x0B), "global 0 is immutable"},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			bin, err := wasm.Decode(tc.bin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = bin.Validate()
+			switch {
+			case tc.err == "" && err != nil:
+				t.Errorf("unexpected error %q", err)
+			case tc.err != "" && err == nil:
+				t.Error("expected error")
+			case tc.err != "" && !strings.Contains(err.Error(), tc.err):
+				t.Errorf("expected error containing %q, got %q", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		what  string
+		build func(m *wasm.Module)
+		err   string
+	}{
+		{"local of another function", func(m *wasm.Module) {
+			a, b := m.Function(), m.Function()
+			x := a.LocalF32()
+			a.Body(wasm.AssignF32(x, wasm.ConstF32(1)))
+			b.Body(wasm.AssignF32(x, wasm.ConstF32(2)))
+			m.Export("a", a)
+			m.Export("b", b)
+		}, `function 1 "b": local.set 0: local of function 0 "a" used in another function`},
+		{"function of another module", func(m *wasm.Module) {
+			other := new(wasm.Module).Function()
+			f := m.Function()
+			f.Body(wasm.Call(other))
+			m.Export("main", f)
+		}, `function 0 "main": call to func () -> () of another module`},
+		{"unbalanced stack", func(m *wasm.Module) {
+			f := m.Function()
+			f.Body(wasm.ConstF32(1))
+			m.Export("main", f)
+		}, `invalid module: function 0 "main": end: 1 extra values on the stack at the end of the function`},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			m := new(wasm.Module)
+			tc.build(m)
+			_, err := m.Compile()
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected error containing %q, got %q", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,607 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CompileText compiles the module into the WebAssembly text
+// format, as written by Binary.WriteWAT.
+func (m *Module) CompileText() ([]byte, error) {
+	b, err := m.Compile()
+	if err != nil {
+		return nil, err
+	}
+	bin, err := Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode compiled module: %s", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := bin.WriteWAT(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteWAT writes the module to w in the WebAssembly text
+// format. Function bodies are written as folded expressions.
+// The module, functions, globals and locals are named as in
+// the name section, if any. Other functions and globals are
+// named after their exports or imports, and other params and
+// locals by position.
+func (b *Binary) WriteWAT(w io.Writer) error {
+	p := &watPrinter{Binary: b}
+	p.names()
+	p.module()
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+type watPrinter struct {
+	*Binary
+	buf bytes.Buffer
+
+	// funcs holds the type index of every function, with
+	// imports first.
+	funcs []uint32
+	// funcNames and globalNames hold the identifiers of the
+	// functions and globals, or "" for unnamed ones.
+	funcNames   []string
+	globalNames []string
+	// nameSection holds the contents of the name section.
+	nameSection nameSection
+}
+
+// names assigns identifiers to the functions and globals.
+func (p *watPrinter) names() {
+	for _, imp := range p.Imports {
+		if imp.Kind == ExternFunction {
+			p.funcs = append(p.funcs, imp.Type)
+		}
+	}
+	p.funcs = append(p.funcs, p.Functions...)
+	for _, c := range p.Custom {
+		if c.Name == "name" {
+			p.nameSection, _ = decodeNames(c.Data)
+		}
+	}
+	p.funcNames, p.globalNames = p.Binary.names()
+	used := make(map[string]bool)
+	for _, names := range [][]string{p.funcNames, p.globalNames} {
+		for i, s := range names {
+			if s != "" {
+				names[i] = uniqueID(used, s)
+			}
+		}
+	}
+}
+
+// uniqueID returns an identifier for s that is not in used,
+// and adds it to used.
+func uniqueID(used map[string]bool, s string) string {
+	id := "$" + watID(s)
+	for i := 1; used[id]; i++ {
+		id = fmt.Sprintf("$%s.%d", watID(s), i)
+	}
+	used[id] = true
+	return id
+}
+
+// watID replaces the characters of s that may not appear in
+// an identifier.
+func watID(s string) string {
+	const chars = "!#$%&'*+-./:<=>?@\\^_`|~"
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			return r
+		case r < 0x80 && strings.ContainsRune(chars, r):
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// ref returns the identifier of a function or global, or its
+// index if it is unnamed.
+func ref(names []string, idx uint32) string {
+	if int(idx) < len(names) && names[idx] != "" {
+		return names[idx]
+	}
+	return strconv.Itoa(int(idx))
+}
+
+// decl returns the identifier of a function or global, or its
+// index in a comment if it is unnamed.
+func decl(names []string, idx uint32) string {
+	if names[idx] != "" {
+		return " " + names[idx]
+	}
+	return fmt.Sprintf(" (;%d;)", idx)
+}
+
+func (p *watPrinter) printf(indent int, format string, args ...interface{}) {
+	p.buf.WriteString(strings.Repeat("  ", indent))
+	fmt.Fprintf(&p.buf, format, args...)
+}
+
+func (p *watPrinter) module() {
+	p.printf(0, "(module")
+	if p.nameSection.module != "" {
+		p.printf(0, " %s", uniqueID(map[string]bool{}, p.nameSection.module))
+	}
+	for i, sig := range p.Types {
+		p.printf(0, "\n")
+		p.printf(1, "(type (;%d;) (func%s%s))", i, watTypes(" (param", sig.Params), watTypes(" (result", sig.Results))
+	}
+	var fi, gi uint32
+	for _, imp := range p.Imports {
+		var desc string
+		switch imp.Kind {
+		case ExternFunction:
+			desc = fmt.Sprintf("(func%s (type %d))", decl(p.funcNames, fi), imp.Type)
+			fi++
+		case ExternMemory:
+			desc = "(memory " + watLimits(imp.Memory) + ")"
+		case ExternGlobal:
+			desc = fmt.Sprintf("(global%s %s)", decl(p.globalNames, gi), watGlobalType(imp.Global, imp.Mutable))
+			gi++
+		}
+		p.printf(0, "\n")
+		p.printf(1, "(import %s %s %s)", watString([]byte(imp.Module)), watString([]byte(imp.Name)), desc)
+	}
+	for i, c := range p.Code {
+		p.function(fi+uint32(i), c)
+	}
+	for _, mem := range p.Memories {
+		p.printf(0, "\n")
+		p.printf(1, "(memory %s)", watLimits(mem))
+	}
+	for i, g := range p.Globals {
+		f := &watFunc{watPrinter: p}
+		p.printf(0, "\n")
+		p.printf(1, "(global%s %s %s)", decl(p.globalNames, gi+uint32(i)),
+			watGlobalType(g.Type, g.Mutable), f.inline(f.fold(g.Init)))
+	}
+	for _, e := range p.Exports {
+		idx := strconv.Itoa(int(e.Index))
+		switch e.Kind {
+		case ExternFunction:
+			idx = ref(p.funcNames, e.Index)
+		case ExternGlobal:
+			idx = ref(p.globalNames, e.Index)
+		}
+		p.printf(0, "\n")
+		p.printf(1, "(export %s (%v %s))", watString([]byte(e.Name)), e.Kind, idx)
+	}
+	if p.Start >= 0 {
+		p.printf(0, "\n")
+		p.printf(1, "(start %s)", ref(p.funcNames, uint32(p.Start)))
+	}
+	for _, d := range p.Data {
+		offset := ""
+		if d.Active {
+			f := &watFunc{watPrinter: p}
+			offset = " " + f.inline(f.fold(d.Offset))
+		}
+		p.printf(0, "\n")
+		p.printf(1, "(data%s %s)", offset, watString(d.Init))
+	}
+	p.printf(0, ")\n")
+}
+
+func (p *watPrinter) function(idx uint32, c BinaryCode) {
+	f := &watFunc{
+		watPrinter: p,
+		sig:        p.Types[p.funcs[idx]],
+	}
+	names := p.nameSection.locals[idx]
+	used := make(map[string]bool)
+	n := len(f.sig.Params) + len(c.Locals)
+	f.locals = make([]string, n)
+	for i := range f.locals {
+		if s, ok := names[uint32(i)]; ok {
+			f.locals[i] = uniqueID(used, s)
+		}
+	}
+	for i := range f.locals {
+		if f.locals[i] == "" && i < len(f.sig.Params) {
+			f.locals[i] = uniqueID(used, fmt.Sprintf("p%d", i))
+		} else if f.locals[i] == "" {
+			f.locals[i] = uniqueID(used, fmt.Sprintf("l%d", i-len(f.sig.Params)))
+		}
+	}
+	p.printf(0, "\n")
+	p.printf(1, "(func%s (type %d)", decl(p.funcNames, idx), p.funcs[idx])
+	for i, t := range f.sig.Params {
+		p.printf(0, " (param %s %s)", f.local(uint32(i)), watType(t))
+	}
+	p.printf(0, "%s", watTypes(" (result", f.sig.Results))
+	for i, t := range c.Locals {
+		p.printf(0, "\n")
+		p.printf(2, "(local %s %s)", f.local(uint32(len(f.sig.Params)+i)), watType(t))
+	}
+	f.labels = []int{len(f.sig.Results)}
+	for _, n := range f.fold(c.Body) {
+		p.printf(0, "\n")
+		f.node(2, n)
+	}
+	p.printf(0, ")")
+}
+
+// watNode is a folded instruction.
+type watNode struct {
+	in Instr
+	// results is the number of values pushed by the
+	// instruction.
+	results int
+	// args are the folded instructions pushing the operands.
+	args []*watNode
+	// body holds the instructions of a block or loop, or the
+	// then branch of an if, and orelse the else branch.
+	body, orelse []*watNode
+	hasElse      bool
+}
+
+func (n *watNode) isBlock() bool {
+	switch n.in.Op {
+	case 0x02, 0x03, 0x04:
+		return true
+	}
+	return false
+}
+
+// watFunc folds and prints the instructions of a function
+// body or constant expression.
+type watFunc struct {
+	*watPrinter
+	sig Signature
+	// labels holds the number of values taken by a branch to
+	// each enclosing label, innermost last.
+	labels []int
+	// locals holds the identifiers of the params and locals.
+	locals []string
+}
+
+// local returns the identifier of a param or local.
+func (f *watFunc) local(idx uint32) string {
+	return ref(f.locals, idx)
+}
+
+// fold folds a sequence of instructions.
+func (f *watFunc) fold(body []Instr) []*watNode {
+	var pos int
+	nodes, _ := f.foldBlock(body, &pos)
+	return nodes
+}
+
+// foldBlock folds the instructions from *pos up to the end or
+// else ending the current block, and returns the op that
+// ended it.
+func (f *watFunc) foldBlock(body []Instr, pos *int) ([]*watNode, Opcode) {
+	var nodes []*watNode
+	for *pos < len(body) {
+		in := body[*pos]
+		*pos++
+		if in.Op == 0x05 || in.Op == 0x0B {
+			return nodes, in.Op
+		}
+		n := &watNode{in: in}
+		params, results := f.arity(in)
+		n.results = results
+		if n.isBlock() {
+			arity := len(in.Block.Results)
+			if in.Op == 0x03 {
+				arity = len(in.Block.Params)
+			}
+			f.labels = append(f.labels, arity)
+			var end Opcode
+			n.body, end = f.foldBlock(body, pos)
+			if end == 0x05 {
+				n.hasElse = true
+				n.orelse, _ = f.foldBlock(body, pos)
+			}
+			f.labels = f.labels[:len(f.labels)-1]
+			if in.Op != 0x04 {
+				// the operands of a block or loop cannot
+				// be folded
+				params = 0
+			}
+		}
+		k := 0
+		for k < params && k < len(nodes) && nodes[len(nodes)-1-k].results == 1 {
+			k++
+		}
+		n.args = append(n.args, nodes[len(nodes)-k:]...)
+		nodes = append(nodes[:len(nodes)-k], n)
+	}
+	return nodes, 0x0B
+}
+
+// arity returns the number of values popped and pushed by in.
+func (f *watFunc) arity(in Instr) (params, results int) {
+	label := func(depth uint32) int {
+		if int(depth) < len(f.labels) {
+			return f.labels[len(f.labels)-1-int(depth)]
+		}
+		return 0
+	}
+	info := opcodes[in.Op]
+	if info.fixed {
+		return len(info.params), len(info.results)
+	}
+	switch in.Op {
+	case 0x02, 0x03:
+		return len(in.Block.Params), len(in.Block.Results)
+	case 0x04:
+		return len(in.Block.Params) + 1, len(in.Block.Results)
+	case 0x0C:
+		return label(in.Index), 0
+	case 0x0D:
+		n := label(in.Index)
+		return n + 1, n
+	case 0x0E:
+		return label(in.Labels[len(in.Labels)-1]) + 1, 0
+	case 0x0F:
+		return len(f.sig.Results), 0
+	case 0x10:
+		if int(in.Index) < len(f.funcs) {
+			sig := f.Types[f.funcs[in.Index]]
+			return len(sig.Params), len(sig.Results)
+		}
+	case 0x1A:
+		return 1, 0
+	case 0x1B, 0x1C:
+		return 3, 1
+	case 0x20, 0x23:
+		return 0, 1
+	case 0x21, 0x24:
+		return 1, 0
+	case 0x22:
+		return 1, 1
+	}
+	return 0, 0
+}
+
+// inline returns nodes on a single line.
+func (f *watFunc) inline(nodes []*watNode) string {
+	s := make([]string, len(nodes))
+	for i, n := range nodes {
+		s[i] = "(" + f.instr(n.in)
+		if len(n.args) > 0 {
+			s[i] += " " + f.inline(n.args)
+		}
+		s[i] += ")"
+	}
+	return strings.Join(s, " ")
+}
+
+func (f *watFunc) hasBlock(nodes []*watNode) bool {
+	for _, n := range nodes {
+		if n.isBlock() || f.hasBlock(n.args) {
+			return true
+		}
+	}
+	return false
+}
+
+// node prints n, on a single line if it is short enough.
+func (f *watFunc) node(indent int, n *watNode) {
+	nodes := []*watNode{n}
+	if line := f.inline(nodes); !f.hasBlock(nodes) && 2*indent+len(line) <= 80 {
+		f.printf(indent, "%s", line)
+		return
+	}
+	f.printf(indent, "(%s", f.instr(n.in))
+	for _, arg := range n.args {
+		f.printf(0, "\n")
+		f.node(indent+1, arg)
+	}
+	body := func(nodes []*watNode) {
+		for _, b := range nodes {
+			f.printf(0, "\n")
+			f.node(indent+2, b)
+		}
+	}
+	switch n.in.Op {
+	case 0x02, 0x03:
+		for _, b := range n.body {
+			f.printf(0, "\n")
+			f.node(indent+1, b)
+		}
+	case 0x04:
+		f.printf(0, "\n")
+		f.printf(indent+1, "(then")
+		body(n.body)
+		f.printf(0, ")")
+		if n.hasElse {
+			f.printf(0, "\n")
+			f.printf(indent+1, "(else")
+			body(n.orelse)
+			f.printf(0, ")")
+		}
+	}
+	f.printf(0, ")")
+}
+
+// instr returns in without its folded operands.
+func (f *watFunc) instr(in Instr) string {
+	info := opcodes[in.Op]
+	s := info.name
+	switch info.imm {
+	case immBlock:
+		s += watTypes(" (param", in.Block.Params) + watTypes(" (result", in.Block.Results)
+	case immSelect:
+		s += watTypes(" (result", in.Block.Results)
+	case immLabel:
+		s += fmt.Sprintf(" %d", in.Index)
+	case immLabels:
+		for _, l := range in.Labels {
+			s += fmt.Sprintf(" %d", l)
+		}
+	case immFunc:
+		s += " " + ref(f.funcNames, in.Index)
+	case immLocal:
+		s += " " + f.local(in.Index)
+	case immGlobal:
+		s += " " + ref(f.globalNames, in.Index)
+	case immMemarg:
+		s += watMemarg(in)
+	case immMemargLane:
+		s += watMemarg(in) + fmt.Sprintf(" %d", in.Lane)
+	case immLane:
+		s += fmt.Sprintf(" %d", in.Lane)
+	case immI32:
+		s += fmt.Sprintf(" %d", int32(in.Bits))
+	case immI64:
+		s += fmt.Sprintf(" %d", int64(in.Bits))
+	case immF32:
+		s += " " + watF32(uint32(in.Bits))
+	case immF64:
+		s += " " + watF64(in.Bits)
+	case immV128:
+		s += " i32x4"
+		for i := 0; i < 16; i += 4 {
+			s += fmt.Sprintf(" 0x%08x", binary.LittleEndian.Uint32(in.V128[i:]))
+		}
+	case immShuffle:
+		for _, l := range in.V128 {
+			s += fmt.Sprintf(" %d", l)
+		}
+	}
+	return s
+}
+
+// watMemarg returns the memory argument of a load or store,
+// omitting the defaults.
+func watMemarg(in Instr) string {
+	var s string
+	if in.Offset != 0 {
+		s += fmt.Sprintf(" offset=%d", in.Offset)
+	}
+	if in.Align != naturalAlign(opcodes[in.Op].name) {
+		s += fmt.Sprintf(" align=%d", uint64(1)<<in.Align)
+	}
+	return s
+}
+
+// naturalAlign returns the alignment exponent of the memory
+// access made by the load or store named name, such as
+// "i32.load16_u" or "v128.load32x2_s".
+func naturalAlign(name string) uint32 {
+	num := func(s string) (int, string) {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		n, _ := strconv.Atoi(s[:i])
+		return n, s[i:]
+	}
+	dot := strings.IndexByte(name, '.')
+	rest := strings.TrimPrefix(strings.TrimPrefix(name[dot+1:], "load"), "store")
+	bits, rest := num(rest)
+	if bits == 0 {
+		bits, _ = num(name[1:dot])
+	} else if strings.HasPrefix(rest, "x") {
+		n, _ := num(rest[1:])
+		bits *= n
+	}
+	var align uint32
+	for bits > 8 {
+		bits /= 2
+		align++
+	}
+	return align
+}
+
+func watType(t Type) string {
+	return t.valuetype().String()
+}
+
+// watTypes returns a param or result list opened by prefix,
+// or "" if ts is empty.
+func watTypes(prefix string, ts []Type) string {
+	if len(ts) == 0 {
+		return ""
+	}
+	s := prefix
+	for _, t := range ts {
+		s += " " + watType(t)
+	}
+	return s + ")"
+}
+
+func watGlobalType(t Type, mutable bool) string {
+	if mutable {
+		return "(mut " + watType(t) + ")"
+	}
+	return watType(t)
+}
+
+func watLimits(l Limits) string {
+	if l.HasMax {
+		return fmt.Sprintf("%d %d", l.Min, l.Max)
+	}
+	return strconv.Itoa(int(l.Min))
+}
+
+func watF32(bits uint32) string {
+	f := math.Float32frombits(bits)
+	switch {
+	case f != f:
+		return watNaN(bits>>31 != 0, uint64(bits&(1<<23-1)), 1<<22)
+	case math.IsInf(float64(f), 0):
+		return watInf(f < 0)
+	}
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func watF64(bits uint64) string {
+	f := math.Float64frombits(bits)
+	switch {
+	case f != f:
+		return watNaN(bits>>63 != 0, bits&(1<<52-1), 1<<51)
+	case math.IsInf(f, 0):
+		return watInf(f < 0)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func watNaN(neg bool, payload, canonical uint64) string {
+	s := "nan"
+	if payload != canonical {
+		s += fmt.Sprintf(":0x%x", payload)
+	}
+	if neg {
+		return "-" + s
+	}
+	return s
+}
+
+func watInf(neg bool) string {
+	if neg {
+		return "-inf"
+	}
+	return "inf"
+}
+
+// watString returns b as a string literal.
+func watString(b []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, c := range b {
+		if c >= 0x20 && c < 0x7F && c != '"' && c != '\\' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "\\%02x", c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}